@@ -0,0 +1,1628 @@
+// Package broker holds the engine's state and RPC and HTTP handlers, so the engine can be run in its own process or inside tests.
+package broker
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"os"
+	"sync"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/census"
+	"uk.ac.bris.cs/gameoflife/compute"
+	"uk.ac.bris.cs/gameoflife/cycle"
+	"uk.ac.bris.cs/gameoflife/heatmap"
+	"uk.ac.bris.cs/gameoflife/history"
+	"uk.ac.bris.cs/gameoflife/rule"
+	"uk.ac.bris.cs/gameoflife/stats"
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/transport"
+)
+
+// IP addresses for AWS instances for each of the workers to be run on
+// TODO: move everything to be run on AWS, change so the same port is being used and
+// change all of the IP addresses to match each of the AWS instances.
+var workerIPs = map[int]string{
+	0: "172.31.80.108:8050",
+	1: "172.31.92.159:8050",
+	2: "172.31.93.105:8050",
+	3: "172.31.89.131:8050",
+	4: "172.31.92.21:8050",
+	5: "172.31.86.7:8050",
+	6: "172.31.86.7:8050",
+	7: "172.31.85.232:8050",
+}
+
+// workerTransport : how the engine calls the workers in workerIPs
+var workerTransport transport.Transport = transport.TCP{}
+
+// StartLocalWorkers : serves the given number of workers inside the engine process on loopback ports
+// and uses them instead of the AWS instances in workerIPs
+func StartLocalWorkers(numWorkers int) error {
+	workerIPs = map[int]string{}
+	for i := 0; i < numWorkers; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return err
+		}
+		go compute.Serve(listener)
+		workerIPs[i] = listener.Addr().String()
+	}
+	return nil
+}
+
+// StartInProcessWorkers : registers the given number of workers on the in-process transport and calls them directly
+// instead of the AWS instances in workerIPs, so the engine and its workers can be run without any connections
+func StartInProcessWorkers(t *transport.InProcess, numWorkers int) {
+	workerIPs = map[int]string{}
+	for i := 0; i < numWorkers; i++ {
+		workerIPs[i] = fmt.Sprintf("worker%d", i)
+		t.Register(workerIPs[i], &compute.Worker{})
+	}
+	workerTransport = t
+}
+
+// Work : used to send work to the engine and to receive work from the engine
+type Work struct {
+	World [][]byte
+	Turn  int
+}
+
+// AliveCells : used to receive appropriate values and store them neatly in a struct from RPC calls made by the controller
+type AliveCells struct {
+	NumAliveCells  int
+	CompletedTurns int
+}
+
+// TopBottomRows : holds top and bottom rows that are sent back by the workers after they've computed one step, as many
+// of each as the range of the rule
+type TopBottomRows struct {
+	TopRows    [][]byte
+	BottomRows [][]byte
+	Hash       uint64
+	Stats      []stats.Row
+	Changes    []stubs.CellState
+}
+
+// HeatMap : heat map counters of the whole world, assembled from the workers, and the turn they're up to
+type HeatMap struct {
+	counters heatmap.Counters
+	turn     int
+}
+
+// WorkerWorld : struct to allow for neat creation of a slice of worlds of type [][]byte
+type WorkerWorld struct {
+	world [][]byte
+	top   int // row of the world the worker's part starts at
+}
+
+// seekRequest : turn to restore the session to, either given directly or as a number of turns to go back
+type seekRequest struct {
+	turn int
+	back int
+}
+
+// seekResult : turn the session was restored to and its alive cells, or why it couldn't be restored
+type seekResult struct {
+	aliveCells AliveCells
+	err        error
+}
+
+// setCellsResult : the cells that changed and the alive cells of the edited world, or why it couldn't be edited
+type setCellsResult struct {
+	cells      []stubs.CellState
+	aliveCells AliveCells
+	err        error
+}
+
+// WorkerResult : to allow for neat creation of slice containing each worker result and their ID
+type WorkerResult struct {
+	world    [][]byte
+	workerID int
+}
+
+// session : a single run of the Game of Life that any number of controllers can be attached to.
+// Completion is broadcast by closing done, and every change of state by closing stateChanged,
+// so every controller waiting on either is woken up.
+type session struct {
+	done        chan struct{}
+	result      Work
+	stopped     bool
+	controllers map[string]stubs.Role
+
+	mu           sync.Mutex
+	state        stubs.State
+	stateTurn    int
+	stateChanged chan struct{}
+	history      *history.History
+	stopOnCycle  bool
+	cycle        *stubs.Cycle
+	statsRegions int
+	stats        []stats.Turn
+	heatMap      bool
+	heat         HeatMap
+	rule         rule.Spec
+	topology     string
+	height       int // rows in each slice of the world if it's a volume
+	depth        int
+	seed         int64
+	err          error // why the session was stopped, if a worker failed
+	changes      int   // times the world has changed, by computing a turn, rewinding or setting cells
+
+	snapshotMu      sync.Mutex
+	snapshot        Work // the world the viewers were last sent, shared between all of them
+	snapshotChanges int  // changes the snapshot is up to
+}
+
+// newSession : creates a session, keeping recent turns within historyBudget bytes so they can be rewound to, with each
+// cell in one of the given grey levels of the states of the rule. History is disabled if historyBudget is 0.
+func newSession(historyBudget int, levels []byte) *session {
+	s := &session{
+		done:         make(chan struct{}),
+		controllers:  map[string]stubs.Role{},
+		state:        stubs.Executing,
+		stateChanged: make(chan struct{}),
+	}
+	if historyBudget > 0 {
+		s.history = history.New(historyBudget, keyframeInterval, levels)
+	}
+	return s
+}
+
+// workerRequest : builds the request handing a worker its part of the world after turn turns, along with the options
+// of the session
+func (s *session) workerRequest(workerWorld WorkerWorld, workerID, numWorkers, turn int) stubs.RequestStartWorker {
+	return stubs.RequestStartWorker{
+		WorkerWorld:  workerWorld.world,
+		WorkerID:     workerID,
+		NumWorkers:   numWorkers,
+		StatsRegions: s.statsRegions,
+		HeatMap:      s.heatMap,
+		Rule:         s.rule,
+		Topology:     s.topology,
+		Top:          workerWorld.top,
+		Height:       s.height,
+		Depth:        s.depth,
+		Seed:         s.seed,
+		Turn:         turn,
+		History:      s.keepsHistory(),
+	}
+}
+
+// runRule : gets the rule of the session, on the volume if the world is one
+func (s *session) runRule() rule.Rule {
+	r, _ := rule.New(s.rule) // checked when the session was started
+	r, _ = rule.InVolume(r, s.height, s.depth)
+	return r
+}
+
+// halo : gets the number of halo rows each worker needs above and below its part, which is the range of the rule, or
+// a whole slice of a volume
+func (s *session) halo() int {
+	return s.runRule().Halo()
+}
+
+// workerHeights : splits the rows of the world between the workers, in whole slices if the world is a volume
+func (s *session) workerHeights(numWorkers, worldHeight int) []int {
+	slice := rule.Slice(s.runRule())
+	workerHeights := makeWorkerHeights(numWorkers, worldHeight/slice)
+	for i := range workerHeights {
+		workerHeights[i] *= slice
+	}
+	return workerHeights
+}
+
+// keepsHistory : checks whether recent turns are kept for the session
+func (s *session) keepsHistory() bool {
+	return s.history != nil
+}
+
+// setCycle : stores the cycle the board has settled into, or clears it after the session has been rewound
+func (s *session) setCycle(c *stubs.Cycle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cycle = c
+}
+
+// changed : counts a change of the world, so the viewers know to take another snapshot of it
+func (s *session) changed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changes++
+}
+
+// getChanges : gets the number of times the world has changed
+func (s *session) getChanges() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.changes
+}
+
+// getCycle : gets the cycle the board has settled into, if it has been detected
+func (s *session) getCycle() *stubs.Cycle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cycle
+}
+
+// record : adds the world after the given turn to the history
+func (s *session) record(turn int, world [][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history.Record(turn, world)
+}
+
+// recordTurn : adds the world after the given turn to the history from the cells the workers changed on it, only
+// collecting the whole world from the workers when it has to be kept as a keyframe
+func (s *session) recordTurn(turn int, rows []TopBottomRows, workerClients []workerClient, numWorkers int) {
+	var changes []history.Cell
+	for _, r := range rows {
+		for _, c := range r.Changes {
+			changes = append(changes, history.Cell{X: c.X, Y: c.Y, Level: c.State})
+		}
+	}
+	s.mu.Lock()
+	recorded := s.history.RecordChanges(turn, changes)
+	s.mu.Unlock()
+	if !recorded {
+		s.record(turn, assembleWorkerParts(workerClients, numWorkers))
+	}
+}
+
+// retained : gets the world after the given turn from the history
+func (s *session) retained(turn int) (Work, error) {
+	if s.history == nil {
+		return Work{}, errNoHistory
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	world, ok := s.history.Get(turn)
+	if !ok {
+		return Work{}, errNotRetained
+	}
+	return Work{World: world, Turn: turn}, nil
+}
+
+// oldestRetained : gets the oldest turn still kept in the history
+func (s *session) oldestRetained() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.history.Oldest()
+}
+
+// truncate : discards every turn after the given turn from the history, once the session has been rewound to it
+func (s *session) truncate(turn int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history.Truncate(turn)
+}
+
+// addStats : adds the metrics of a turn to the series, dropping the oldest turn once maxStatsTurns are kept
+func (s *session) addStats(t stats.Turn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats = append(s.stats, t)
+	if len(s.stats) > maxStatsTurns {
+		s.stats = append([]stats.Turn(nil), s.stats[len(s.stats)-maxStatsTurns:]...)
+	}
+}
+
+// statsBetween : gets the metrics of the turns from from to to inclusive, up to the latest turn if to is 0
+func (s *session) statsBetween(from, to int) ([]stats.Turn, error) {
+	if s.statsRegions <= 0 {
+		return nil, errNoStats
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	turns := []stats.Turn{}
+	for _, t := range s.stats {
+		if t.CompletedTurns >= from && (to == 0 || t.CompletedTurns <= to) {
+			turns = append(turns, t)
+		}
+	}
+	return turns, nil
+}
+
+// truncateStats : discards the metrics of every turn after the given turn, once the session has been rewound to it
+func (s *session) truncateStats(turn int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.stats) > 0 && s.stats[len(s.stats)-1].CompletedTurns > turn {
+		s.stats = s.stats[:len(s.stats)-1]
+	}
+}
+
+// getState : gets the current state of the session
+func (s *session) getState() stubs.State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// setState : moves the session into a new state and wakes up every controller waiting for a state change
+func (s *session) setState(state stubs.State, turn int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == state {
+		return
+	}
+	s.state = state
+	s.stateTurn = turn
+	close(s.stateChanged)
+	s.stateChanged = make(chan struct{})
+}
+
+// waitStateChange : blocks until the session is no longer in the known state, then returns the new state
+// and the turn it changed on
+func (s *session) waitStateChange(known stubs.State) (stubs.State, int) {
+	for {
+		s.mu.Lock()
+		state, turn, changed := s.state, s.stateTurn, s.stateChanged
+		s.mu.Unlock()
+		if state != known {
+			return state, turn
+		}
+		<-changed
+	}
+}
+
+// fail : stops the session because a call to a worker failed, keeping the first error so it can be sent to the
+// controllers
+func (s *session) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// failure : gets why the session was stopped, if a worker failed
+func (s *session) failure() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// finish : stores the final world of the session and wakes up every controller waiting for it
+func (s *session) finish(result Work, stopped bool) {
+	s.result = result
+	s.stopped = stopped
+	s.setState(stubs.Quitting, result.Turn)
+	close(s.done)
+}
+
+const (
+	// ALIVE : pixel value for alive cells
+	ALIVE = 255
+
+	// DEAD : pixel value for dead cells
+	DEAD = 0
+)
+
+// keyframeInterval : a full board is kept in the history every keyframeInterval turns, the turns in between are kept as diffs
+const keyframeInterval = 64
+
+// maxStatsTurns : the metrics of at most maxStatsTurns recent turns are kept for GetStats
+const maxStatsTurns = 1 << 16
+
+// dialAttempts : the number of times each worker is dialled before giving up on it, waiting retryDelay after the first
+// failed attempt and twice as long after each one after that
+const (
+	dialAttempts = 4
+	retryDelay   = 250 * time.Millisecond
+)
+
+// maxCyclePeriod : cycles are only detected if they repeat within maxCyclePeriod turns, e.g. a glider crossing a 1024x1024 board
+const maxCyclePeriod = 4096
+
+const (
+	requestAliveCells = iota
+	requestPgm
+	requestHeatMap
+	requestPause
+	requestResume
+	requestRunFor
+	requestSeek
+	requestSetCells
+	requestStop
+	requestStopWorkers
+)
+
+// Errors returned to controllers, kept as values so the HTTP API can map them onto status codes
+var (
+	errNoWorld     = errors.New("a world must be specified")
+	errNotStarted  = errors.New("engine has not been started")
+	errFinished    = errors.New("the session has already finished")
+	errNotAttached = errors.New("controller is not attached to the current session")
+	errObserver    = errors.New("observers cannot control the session")
+	errStarting    = errors.New("another session is being started")
+	errNotPaused   = errors.New("the session must be paused to step through turns")
+	errTurns       = errors.New("the number of turns to run for must be positive")
+	errNoHistory   = errors.New("history is not kept for this session")
+	errNotRetained = errors.New("the turn is not retained in the history")
+	errNotEditable = errors.New("the session must be paused to set cells")
+	errCells       = errors.New("cells must be inside the world")
+	errNoStats     = errors.New("stats are not kept for this session")
+	errNoHeatMap   = errors.New("heat maps are not kept for this session")
+	errRule        = errors.New("the rule must be in B/S/C notation, e.g. B3/S23, in LtL notation or the name of a rule, e.g. Wireworld")
+	errHalo        = errors.New("each worker needs at least as many rows as the range of the rule")
+	errTopology    = errors.New("the topology must be square, hex or triangular, wrap round the world and suit the rule")
+	errVolume      = errors.New("a volume must have a whole number of slices and run a 3D rule, e.g. 3d:4555, which only runs on volumes")
+)
+
+func makeWorld(height, width int) [][]byte {
+	world := make([][]byte, height)
+	for i := range world {
+		world[i] = make([]byte, width)
+	}
+	return world
+}
+
+// numAliveCells : gets the number of alive cells from a given world
+func numAliveCells(world [][]byte) int {
+	aliveCells := 0
+	for y := range world {
+		for x := range world[y] {
+			if world[y][x] == ALIVE {
+				aliveCells++
+			}
+		}
+	}
+	return aliveCells
+}
+
+// makeWorkerHeights : calculate the worker heights for each of the worker and store in an array
+func makeWorkerHeights(numWorkers, worldHeight int) []int {
+	workerHeights := make([]int, numWorkers)
+	defaultHeight := worldHeight / numWorkers // floor division
+	for i := range workerHeights {
+		workerHeights[i] = defaultHeight
+	}
+
+	// In case of uneven division, add remaining height to the last worker
+	remainder := worldHeight % numWorkers
+	if remainder != 0 {
+		workerHeights[len(workerHeights)-1] += remainder
+	}
+	return workerHeights
+}
+
+// buildWorkerWorlds : takes in the number of workers and creates worlds for each of them to work on, with halo rows
+// above and below each of them, as many as the range of the rule
+func buildWorkerWorlds(workerHeights []int, world [][]byte, halo int) []WorkerWorld {
+	worldHeight := len(world)
+	workerWorlds := []WorkerWorld{}
+	currHeight := 0 // first row of the current worker's part
+	for _, workerHeight := range workerHeights {
+		paddedWorkerHeight := workerHeight + 2*halo // add extra top and bottom rows to account for halo rows
+		workerWorld := make([][]byte, paddedWorkerHeight)
+		for y := range workerWorld {
+			workerWorld[y] = world[((currHeight+y-halo)%worldHeight+worldHeight)%worldHeight]
+		}
+		workerWorlds = append(workerWorlds, WorkerWorld{world: workerWorld, top: currHeight})
+		currHeight += workerHeight
+	}
+	return workerWorlds
+}
+
+func assembleWorkerParts(workerClients []workerClient, numWorkers int) [][]byte {
+	// Store the worker result in a map, where the key is the worker ID of each worker with their corresponding world.
+	// Since maps are not ordered, the workerID has to be retrieved from the workers so we are 100% certain the key corresponds
+	// to their actual world.
+	workerParts := map[int][][]byte{}
+	for i := 0; i < numWorkers; i++ {
+		workerPartResult := requestWorkerResult(workerClients[i], numWorkers)
+		workerParts[workerPartResult.workerID] = workerPartResult.world
+	}
+
+	// Loop through like this rather than using range, as maps are unordered
+	workerResult := makeWorld(0, 0)
+	for i := 0; i < numWorkers; i++ {
+		part := workerParts[i]
+		workerResult = append(workerResult, part...)
+	}
+
+	return workerResult
+}
+
+// assembleHeatMaps : puts the heat map counters of each worker's part together, the same way as assembleWorkerParts
+func assembleHeatMaps(workerClients []workerClient, numWorkers int) heatmap.Counters {
+	workerParts := map[int]stubs.ResponseWorkerHeatMap{}
+	for i := 0; i < numWorkers; i++ {
+		part := requestWorkerHeatMap(workerClients[i])
+		workerParts[part.WorkerID] = part
+	}
+
+	counters := heatmap.Counters{}
+	for i := 0; i < numWorkers; i++ {
+		counters.Alive = append(counters.Alive, workerParts[i].Alive...)
+		counters.Flips = append(counters.Flips, workerParts[i].Flips...)
+	}
+	return counters
+}
+
+// worldHash : combines the hashes of each worker's part, in order, into a hash of the whole world
+func worldHash(partHashes []uint64) uint64 {
+	buf := make([]byte, 8*len(partHashes))
+	for i, hash := range partHashes {
+		binary.BigEndian.PutUint64(buf[8*i:], hash)
+	}
+	return cycle.Hash([][]byte{buf})
+}
+
+// partHashes : hashes each worker's part of a world the same way the workers do
+func partHashes(workerHeights []int, world [][]byte) []uint64 {
+	hashes := make([]uint64, len(workerHeights))
+	y := 0
+	for i, height := range workerHeights {
+		hashes[i] = cycle.Hash(world[y : y+height])
+		y += height
+	}
+	return hashes
+}
+
+// combineStats : combines the metrics of each worker's rows, in order, into the metrics of the whole world
+func combineStats(turn int, workerRows []TopBottomRows, width, regions int) stats.Turn {
+	rows := []stats.Row{}
+	for _, r := range workerRows {
+		rows = append(rows, r.Stats...)
+	}
+	return stats.Combine(turn, rows, width, regions)
+}
+
+// workerClient : a client of one of the workers of a session. Once a call to any of the workers fails the session is
+// stopped, so the calls after it aren't made and leave their responses empty.
+type workerClient struct {
+	transport.Client
+	session *session
+	id      int
+}
+
+// call : calls the worker, unless a call to one of the workers has already failed
+func (w workerClient) call(serviceMethod string, args interface{}, reply interface{}) {
+	if w.session.failure() != nil {
+		return
+	}
+	if err := w.Client.Call(serviceMethod, args, reply); err != nil {
+		w.session.fail(fmt.Errorf("worker %v failed %v: %v", w.id, serviceMethod, err))
+	}
+}
+
+// dialWorkers : connects to the given number of workers, trying each of them again with a growing delay if it can't be
+// reached
+func dialWorkers(s *session, numWorkers int) ([]workerClient, error) {
+	workerClients := make([]workerClient, numWorkers)
+	for i := range workerClients {
+		delay := retryDelay
+		for attempt := 1; ; attempt++ {
+			client, err := workerTransport.Dial(workerIPs[i])
+			if err == nil {
+				workerClients[i] = workerClient{Client: client, session: s, id: i}
+				fmt.Println("Connected to worker: ", workerIPs[i])
+				break
+			}
+			if attempt == dialAttempts {
+				for _, w := range workerClients[:i] {
+					w.Close()
+				}
+				return nil, fmt.Errorf("can't reach worker %v at %v: %v", i, workerIPs[i], err)
+			}
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return workerClients, nil
+}
+
+/* RCP calls */
+
+func requestStartWorker(client workerClient, request stubs.RequestStartWorker) TopBottomRows {
+	response := new(stubs.ResponseRows)
+	client.call(stubs.StartWorkerHandler, request, response)
+	return TopBottomRows{TopRows: response.TopRows, BottomRows: response.BottomRows, Hash: response.Hash, Stats: response.Stats, Changes: response.Changes}
+}
+
+func requestLoadWorker(client workerClient, request stubs.RequestStartWorker) TopBottomRows {
+	response := new(stubs.ResponseRows)
+	client.call(stubs.LoadWorkerHandler, request, response)
+	return TopBottomRows{TopRows: response.TopRows, BottomRows: response.BottomRows, Hash: response.Hash}
+}
+
+func requestNextState(client workerClient, topBottomRows TopBottomRows) TopBottomRows {
+	request := stubs.RequestNextState{TopRows: topBottomRows.TopRows, BottomRows: topBottomRows.BottomRows}
+	response := new(stubs.ResponseRows)
+	client.call(stubs.NextStateHandler, request, response)
+	return TopBottomRows{TopRows: response.TopRows, BottomRows: response.BottomRows, Hash: response.Hash, Stats: response.Stats, Changes: response.Changes}
+}
+
+func requestWorkerResult(client workerClient, numWorkers int) WorkerResult {
+	request := stubs.RequestWorkerResult{NumWorkers: numWorkers}
+	response := new(stubs.ResponseWorkerResult)
+	client.call(stubs.WorkerResultHandler, request, response)
+	return WorkerResult{world: response.WorkerWorldPart, workerID: response.WorkerID}
+}
+
+func requestWorkerPGM(client workerClient) WorkerResult {
+	request := stubs.RequestPGM{}
+	response := new(stubs.ResponseWorkerResult)
+	client.call(stubs.WorkerPGMHandler, request, response)
+	return WorkerResult{world: response.WorkerWorldPart, workerID: response.WorkerID}
+}
+
+func requestWorkerHeatMap(client workerClient) stubs.ResponseWorkerHeatMap {
+	request := stubs.RequestHeatMap{}
+	response := new(stubs.ResponseWorkerHeatMap)
+	client.call(stubs.WorkerHeatMapHandler, request, response)
+	return *response
+}
+
+func requestStopWorker(client workerClient) {
+	request := stubs.RequestStopWorker{}
+	response := new(stubs.ResponseStopWorker)
+	client.call(stubs.StopWorkerHandler, request, response)
+	return
+}
+
+// Evolves the Game of Life for a given number of turns and a given world
+func (e *Engine) gameOfLife(s *session, workerClients []workerClient, numWorkers, start, turns int, world [][]byte) {
+
+	// Initiate each worker with their worker worlds.
+	// This has to be done before the loop, because we want to hand the worlds over to each worker in a RPC call before we can
+	// loop through each turn and make them calculate the next state.
+	topBottomRows := make([]TopBottomRows, numWorkers)
+	halo := s.halo()
+	hashes := make([]uint64, numWorkers) // hash of each worker's part after the last computed turn
+	if turns > start {
+		if numWorkers != 1 {
+			workerHeights := s.workerHeights(numWorkers, len(world))
+			workerWorlds := buildWorkerWorlds(workerHeights, world, halo)
+			for i := range workerWorlds {
+				rows := requestStartWorker(workerClients[i], s.workerRequest(workerWorlds[i], i, numWorkers, start))
+				topBottomRows[i].TopRows = rows.TopRows
+				topBottomRows[i].BottomRows = rows.BottomRows
+				topBottomRows[i].Stats = rows.Stats
+				topBottomRows[i].Changes = rows.Changes
+				hashes[i] = rows.Hash
+			}
+		} else {
+			// just start computation with one worker on the original world
+			rows := requestStartWorker(workerClients[0], s.workerRequest(WorkerWorld{world: world}, 0, numWorkers, start))
+			topBottomRows[0].Stats = rows.Stats
+			topBottomRows[0].Changes = rows.Changes
+			hashes[0] = rows.Hash
+		}
+		if s.statsRegions > 0 {
+			s.addStats(combineStats(start+1, topBottomRows, len(world[0]), s.statsRegions))
+		}
+	}
+
+	turn := start + 1 // first turn was computed when the workers started
+	stepsLeft := 0    // turns left to compute while paused, after a controller asked to step through turns
+
+	// Keep the initial world and the turn computed when the workers started, so the session can be rewound to them
+	if s.keepsHistory() {
+		s.record(start, world)
+		if turns > start {
+			s.recordTurn(turn, topBottomRows, workerClients, numWorkers)
+		}
+	}
+
+	// Hash every turn to find out when the board settles into a still life or an oscillator. The workers send back a hash
+	// of their part with every turn, so the world doesn't have to be collected to do this.
+	detector := cycle.New(maxCyclePeriod)
+	if turns > start {
+		detector.Add(start, worldHash(partHashes(s.workerHeights(numWorkers, len(world)), world)))
+		detector.Add(turn, worldHash(hashes))
+	}
+
+	// Hands a world restored from the history over to the workers, in place of the world they were working on
+	loadWorkers := func(world [][]byte, turn int) {
+		if numWorkers != 1 {
+			workerHeights := s.workerHeights(numWorkers, len(world))
+			workerWorlds := buildWorkerWorlds(workerHeights, world, halo)
+			for i := range workerWorlds {
+				topBottomRows[i] = requestLoadWorker(workerClients[i], s.workerRequest(workerWorlds[i], i, numWorkers, turn))
+			}
+		} else {
+			_ = requestLoadWorker(workerClients[0], s.workerRequest(WorkerWorld{world: world}, 0, numWorkers, turn))
+		}
+	}
+
+	// Handles a command from the controllers. Queries are answered in every state, so the session can still be
+	// observed while it's paused.
+	handleCommand := func(cmd int) {
+		switch cmd {
+		case requestAliveCells:
+			// Query workers to send number of alive cells of their part (excl. halo rows) back
+			tempWorld := assembleWorkerParts(workerClients, numWorkers)
+			e.aliveCellsChan <- AliveCells{NumAliveCells: numAliveCells(tempWorld), CompletedTurns: turn}
+		case requestPgm:
+			// Query workers to send back their part back without halo rows
+			workerPGMResults := map[int][][]byte{}
+			for i := range workerClients {
+				result := requestWorkerPGM(workerClients[i])
+				workerPGMResults[result.workerID] = result.world
+			}
+
+			// Put parts together
+			pgmWorld := makeWorld(0, 0)
+			for i := 0; i < numWorkers; i++ {
+				part := workerPGMResults[i]
+				pgmWorld = append(pgmWorld, part...)
+			}
+			e.workChan <- Work{World: pgmWorld, Turn: turn}
+		case requestHeatMap:
+			e.heatChan <- HeatMap{counters: assembleHeatMaps(workerClients, numWorkers), turn: turn}
+		case requestPause:
+			if s.getState() == stubs.Executing {
+				s.setState(stubs.Paused, turn)
+				e.responseMsgChan <- fmt.Sprintf("Pausing on turn %d", turn)
+			} else {
+				e.responseMsgChan <- fmt.Sprintf("Already paused on turn %d", turn)
+			}
+		case requestResume:
+			if s.getState() == stubs.Paused {
+				s.setState(stubs.Executing, turn)
+				e.responseMsgChan <- "Continuing"
+			} else {
+				e.responseMsgChan <- "Not paused"
+			}
+		case requestRunFor:
+			n := <-e.runForChan
+			if s.getState() == stubs.Paused {
+				stepsLeft = n
+			} else {
+				e.stepDoneChan <- AliveCells{NumAliveCells: -1, CompletedTurns: turn}
+			}
+		case requestSeek:
+			req := <-e.seekChan
+			target := req.turn
+			if req.back > 0 {
+				// Rewinding further back than the history goes stops at the oldest retained turn
+				target = turn - req.back
+				if s.keepsHistory() && target < s.oldestRetained() {
+					target = s.oldestRetained()
+				}
+			}
+			if s.getState() != stubs.Paused {
+				e.seekDoneChan <- seekResult{err: errNotPaused}
+				break
+			}
+			if target > turn {
+				e.seekDoneChan <- seekResult{err: errNotRetained}
+				break
+			}
+			restored, err := s.retained(target)
+			if err != nil {
+				e.seekDoneChan <- seekResult{err: err}
+				break
+			}
+			loadWorkers(restored.World, target)
+			turn = target
+			s.truncate(turn)
+			s.truncateStats(turn)
+			detector.Truncate(turn)
+			s.setCycle(nil)
+			s.changed()
+			fmt.Print("Rewound to turn ", turn, "\n\n")
+			e.seekDoneChan <- seekResult{aliveCells: AliveCells{NumAliveCells: numAliveCells(restored.World), CompletedTurns: turn}}
+		case requestSetCells:
+			cells := <-e.setCellsChan
+			if s.getState() != stubs.Paused {
+				e.setCellsDoneChan <- setCellsResult{err: errNotEditable}
+				break
+			}
+			edited := assembleWorkerParts(workerClients, numWorkers)
+			set, err := setCells(edited, cells, s.runRule())
+			if err != nil {
+				e.setCellsDoneChan <- setCellsResult{err: err}
+				break
+			}
+			// The history and cycle detection carry on from the edited world, in place of the one computed on this turn
+			loadWorkers(edited, turn)
+			if s.keepsHistory() {
+				s.record(turn, edited)
+			}
+			detector.Truncate(turn - 1)
+			detector.Add(turn, worldHash(partHashes(s.workerHeights(numWorkers, len(edited)), edited)))
+			s.setCycle(nil)
+			s.changed()
+			fmt.Print("Changed ", len(set), " cells on turn ", turn, "\n\n")
+			e.setCellsDoneChan <- setCellsResult{cells: set, aliveCells: AliveCells{NumAliveCells: numAliveCells(edited), CompletedTurns: turn}}
+		case requestStop:
+			fmt.Print("Stopping computation\n\n")
+			s.setState(stubs.Stopping, turn)
+			e.setRunning(false)
+		case requestStopWorkers:
+			s.setState(stubs.Quitting, turn)
+			for i := 0; i < numWorkers; i++ {
+				requestStopWorker(workerClients[i])
+			}
+			fmt.Print("Stopping computation\n\n")
+			e.okChan <- true
+			time.Sleep(2 * time.Second)
+			os.Exit(0)
+		}
+	}
+
+	for (turn < turns) && e.isRunning() && s.failure() == nil {
+		if s.getState() == stubs.Paused && stepsLeft == 0 {
+			// Block rather than spin until a command arrives, nothing has to be computed while paused
+			handleCommand(<-e.cmdChan)
+			continue
+		}
+		select {
+		case cmd := <-e.cmdChan:
+			handleCommand(cmd)
+			if !e.isRunning() || (s.getState() == stubs.Paused && stepsLeft == 0) {
+				continue
+			}
+		default:
+		}
+
+		// Calculate the next state and communicate the halo rows in between the workers
+		tempTopBottomRows := make([]TopBottomRows, numWorkers)
+		for i := 0; i < numWorkers; i++ {
+			if numWorkers != 2 {
+				newTopRows := topBottomRows[(i+numWorkers-1)%numWorkers].BottomRows
+				newBottomRows := topBottomRows[(i+1)%numWorkers].TopRows
+				tempTopBottomRows[i] = requestNextState(workerClients[i], TopBottomRows{TopRows: newTopRows, BottomRows: newBottomRows})
+			} else if numWorkers == 1 {
+				_ = requestNextState(workerClients[0], TopBottomRows{TopRows: nil, BottomRows: nil})
+			} else {
+				newTopRows := topBottomRows[(i+1)%numWorkers].BottomRows
+				newBottomRows := topBottomRows[(i+1)%numWorkers].TopRows
+				tempTopBottomRows[i] = requestNextState(workerClients[i], TopBottomRows{TopRows: newTopRows, BottomRows: newBottomRows})
+			}
+		}
+
+		// Stop if a worker failed, letting the controller that asked to step know as well
+		if s.failure() != nil {
+			if stepsLeft > 0 {
+				e.stepDoneChan <- AliveCells{CompletedTurns: turn}
+			}
+			break
+		}
+
+		// Update the top and bottom rows for each of the worker worlds after the next state has been calculated for all of them
+		for i := range topBottomRows {
+			topBottomRows[i] = tempTopBottomRows[i]
+			hashes[i] = tempTopBottomRows[i].Hash
+		}
+
+		if turn%10 == 0 && turn != 0 {
+			fmt.Println("Turn ", turn, " computed")
+		}
+		turn++
+		s.changed()
+		if s.statsRegions > 0 {
+			s.addStats(combineStats(turn, tempTopBottomRows, len(world[0]), s.statsRegions))
+		}
+		if s.keepsHistory() {
+			s.recordTurn(turn, tempTopBottomRows, workerClients, numWorkers)
+		}
+		stopEarly := false
+		if s.getCycle() == nil {
+			if found, ok := detector.Add(turn, worldHash(hashes)); ok {
+				s.setCycle(&stubs.Cycle{Start: found.Start, Period: found.Period, Turn: turn})
+				fmt.Print("Settled into a cycle of period ", found.Period, " from turn ", found.Start, "\n\n")
+				stopEarly = s.stopOnCycle
+			}
+		}
+
+		// Let the controller that asked to step know once all the turns have been computed
+		if stepsLeft > 0 {
+			stepsLeft--
+			if stepsLeft == 0 || turn >= turns || stopEarly {
+				stepsLeft = 0
+				tempWorld := assembleWorkerParts(workerClients, numWorkers)
+				e.stepDoneChan <- AliveCells{NumAliveCells: numAliveCells(tempWorld), CompletedTurns: turn}
+			}
+		}
+		if stopEarly {
+			break
+		}
+	}
+
+	var newWorld [][]byte
+	if turns > start { // this is for the testing framework, if no turns have to be computed we don't want to request the workers for their results
+		newWorld = assembleWorkerParts(workerClients, numWorkers)
+	}
+
+	// Broadcast the results to every attached controller, including when the computation has been stopped early
+	// running is cleared before finishing, so a new session started as soon as this one is done isn't marked as stopped
+	stopped := !e.isRunning()
+	if !stopped {
+		fmt.Print("Sending world back\n\n")
+	}
+	e.setRunning(false)
+	if s.heatMap {
+		// Keep the final heat maps, as the workers can't be asked for them once the session has finished
+		if turns > start {
+			s.heat = HeatMap{counters: assembleHeatMaps(workerClients, numWorkers), turn: turn}
+		} else {
+			s.heat = HeatMap{counters: heatmap.New(len(world), len(world[0]))}
+		}
+	}
+	if turns > start {
+		s.finish(Work{World: newWorld, Turn: turn}, stopped)
+	} else {
+		// This is for the testing framework, since the first step is calculated as a way of initialising the workers we don't want to send back a world
+		// that which the next state has been calculated, if the number of turns specified by the testing framework is 0. So send back the old world
+		s.finish(Work{World: world, Turn: start}, stopped)
+	}
+
+	for i := 0; i < numWorkers; i++ {
+		workerClients[i].Close()
+	}
+}
+
+// Waits for the session to finish and gets its results
+func getResults(s *session) (Work, bool) {
+	<-s.done
+	return Work{World: s.result.World, Turn: s.result.Turn}, s.stopped
+}
+
+// Gets the number of alive cells and number of completed turns from the alive cells channel,
+// or from the final world if the session has already finished
+func getAliveCells(s *session, aliveCellsChan chan AliveCells, cmdChan chan int) AliveCells {
+	select {
+	case cmdChan <- requestAliveCells:
+		aliveCells := <-aliveCellsChan
+		return AliveCells{NumAliveCells: aliveCells.NumAliveCells, CompletedTurns: aliveCells.CompletedTurns}
+	case <-s.done:
+		return AliveCells{NumAliveCells: numAliveCells(s.result.World), CompletedTurns: s.result.Turn}
+	}
+}
+
+// Gets the board state, or the final world if the session has already finished
+func getPGM(s *session, workChan chan Work, cmdChan chan int) Work {
+	select {
+	case cmdChan <- requestPgm:
+		work := <-workChan
+		return Work{work.World, work.Turn}
+	case <-s.done:
+		return Work{s.result.World, s.result.Turn}
+	}
+}
+
+// Gets the heat map counters so far, or the final ones if the session has already finished
+func getHeatMap(s *session, heatChan chan HeatMap, cmdChan chan int) HeatMap {
+	select {
+	case cmdChan <- requestHeatMap:
+		return <-heatChan
+	case <-s.done:
+		return s.heat
+	}
+}
+
+// Sends pause command to current process, unless it has already finished
+func pause(s *session, cmdChan chan int, responseMsgChan chan string) (string, error) {
+	return command(s, requestPause, cmdChan, responseMsgChan)
+}
+
+// Sends resume command to current process, unless it has already finished
+func resume(s *session, cmdChan chan int, responseMsgChan chan string) (string, error) {
+	return command(s, requestResume, cmdChan, responseMsgChan)
+}
+
+// Sends a command answered with a message to the session, giving up once the session has finished, as nothing
+// receives commands after that
+func command(s *session, cmd int, cmdChan chan int, responseMsgChan chan string) (string, error) {
+	if s.getState() == stubs.Quitting {
+		return "", errFinished
+	}
+	select {
+	case cmdChan <- cmd:
+		return <-responseMsgChan, nil
+	case <-s.done:
+		return "", errFinished
+	}
+}
+
+// Computes the given number of turns while paused and waits for them to be completed
+func runFor(s *session, turns int, cmdChan chan int, runForChan chan int, stepDoneChan chan AliveCells) (AliveCells, error) {
+	if s.getState() != stubs.Paused {
+		return AliveCells{}, errNotPaused
+	}
+	select {
+	case cmdChan <- requestRunFor:
+	case <-s.done:
+		return AliveCells{}, errNotPaused
+	}
+	runForChan <- turns
+	aliveCells := <-stepDoneChan
+	if aliveCells.NumAliveCells < 0 {
+		return AliveCells{}, errNotPaused
+	}
+	return aliveCells, nil
+}
+
+// Restores the session to a turn kept in the history while paused
+func seek(s *session, req seekRequest, cmdChan chan int, seekChan chan seekRequest, seekDoneChan chan seekResult) (AliveCells, error) {
+	if s.getState() != stubs.Paused {
+		return AliveCells{}, errNotPaused
+	}
+	select {
+	case cmdChan <- requestSeek:
+	case <-s.done:
+		return AliveCells{}, errNotPaused
+	}
+	seekChan <- req
+	result := <-seekDoneChan
+	return result.aliveCells, result.err
+}
+
+// Sets cells of the session's world while paused
+func editCells(s *session, cells []stubs.CellState, cmdChan chan int, setCellsChan chan []stubs.CellState, setCellsDoneChan chan setCellsResult) setCellsResult {
+	if s.getState() != stubs.Paused {
+		return setCellsResult{err: errNotEditable}
+	}
+	select {
+	case cmdChan <- requestSetCells:
+	case <-s.done:
+		return setCellsResult{err: errNotEditable}
+	}
+	setCellsChan <- cells
+	return <-setCellsDoneChan
+}
+
+// Sets cells of a world to grey levels snapped onto the states of the rule, giving back the cells that changed.
+// Nothing is set if any of the cells is outside the world.
+func setCells(world [][]byte, cells []stubs.CellState, r rule.Rule) ([]stubs.CellState, error) {
+	for _, cell := range cells {
+		if cell.Y < 0 || cell.Y >= len(world) || cell.X < 0 || cell.X >= len(world[cell.Y]) {
+			return nil, errCells
+		}
+	}
+	var changed []stubs.CellState
+	for _, cell := range cells {
+		cell.State = r.Quantise(cell.State)
+		if world[cell.Y][cell.X] != cell.State {
+			world[cell.Y][cell.X] = cell.State
+			changed = append(changed, cell)
+		}
+	}
+	return changed, nil
+}
+
+// Commands the engine to stop processing the game, unless it has already finished
+func stop(s *session, cmdChan chan int) string {
+	select {
+	case cmdChan <- requestStop:
+		return "Stopping engine"
+	case <-s.done:
+		return "Engine is not running"
+	}
+}
+
+// String to send back to controller when it's been connected to the engine
+func reconnect(role stubs.Role) string {
+	if role == stubs.Observer {
+		return "Controller reconnected to engine as an observer"
+	}
+	return "Controller reconnected to engine as an operator"
+}
+
+// Engine : used to run functions that respond to requests made by the controller.
+// 			Can communicate the work that's being done using a channel
+type Engine struct {
+	workChan         chan Work
+	aliveCellsChan   chan AliveCells
+	cmdChan          chan int
+	responseMsgChan  chan string
+	okChan           chan bool
+	runForChan       chan int
+	stepDoneChan     chan AliveCells
+	seekChan         chan seekRequest
+	seekDoneChan     chan seekResult
+	setCellsChan     chan []stubs.CellState
+	setCellsDoneChan chan setCellsResult
+	heatChan         chan HeatMap
+
+	mu       sync.Mutex
+	session  *session
+	running  bool // whether a session is computing turns, until it finishes or is stopped
+	starting bool // whether a session is being started, so only one start replaces the current session at a time
+}
+
+// setRunning : marks whether a session is computing turns
+func (e *Engine) setRunning(running bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.running = running
+}
+
+// isRunning : checks whether a session is computing turns
+func (e *Engine) isRunning() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.running
+}
+
+// attach : registers a controller with the given role on the session and returns its ID. IDs are random, as holding
+// the ID of an operator is what lets a controller operate the session, so they mustn't be guessable from other IDs.
+func (e *Engine) attach(s *session, role stubs.Role) string {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		panic(err)
+	}
+	id := hex.EncodeToString(random)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s.controllers[id] = role
+	return id
+}
+
+// currentSession : gets the session that's currently attached to the engine, if any
+func (e *Engine) currentSession() (*session, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.session == nil {
+		return nil, errNotStarted
+	}
+	return e.session, nil
+}
+
+// authorise : checks that the controller is attached to the current session as an operator
+func (e *Engine) authorise(controllerID string) error {
+	s, err := e.currentSession()
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return operatorOf(s, controllerID)
+}
+
+// operatorOf : checks that the controller is attached to the session as an operator, with the engine's mutex held
+func operatorOf(s *session, controllerID string) error {
+	role, ok := s.controllers[controllerID]
+	if !ok {
+		return errNotAttached
+	}
+	if role != stubs.Operator {
+		return errObserver
+	}
+	return nil
+}
+
+// claim : reserves the engine for starting a new session and returns the session it replaces, if any. A running
+// session can only be replaced by one of its operators, and only one session is started at a time, so the check and
+// the reservation are made together.
+func (e *Engine) claim(controllerID string) (*session, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.starting {
+		return nil, errStarting
+	}
+	if e.running {
+		if err := operatorOf(e.session, controllerID); err != nil {
+			return nil, err
+		}
+	}
+	e.starting = true
+	return e.session, nil
+}
+
+// release : lets another session be started, once the one that claimed the engine has started or failed to
+func (e *Engine) release() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.starting = false
+}
+
+// GameOfLife : runs the game of life after getting a request from the controller
+func (e *Engine) GameOfLife(req stubs.RequestStart, res *stubs.ResponseStart) (err error) {
+	if len(req.World) == 0 || len(req.World[0]) == 0 {
+		err = errNoWorld
+		res.Message = "invalid world"
+		return
+	}
+	r, err := rule.New(req.Rule)
+	if err != nil {
+		fmt.Println(err)
+		err = errRule
+		res.Message = "invalid rule"
+		return
+	}
+	height := len(req.World)
+	if req.Depth > 1 {
+		height /= req.Depth
+	}
+	if req.Depth > 1 && len(req.World)%req.Depth != 0 {
+		err = errVolume
+		res.Message = "invalid depth"
+		return
+	}
+	if r, err = rule.InVolume(r, height, req.Depth); err != nil {
+		fmt.Println(err)
+		err = errVolume
+		res.Message = "invalid depth"
+		return
+	}
+	topology, err := rule.ParseTopology(req.Topology)
+	if err == nil {
+		err = topology.Check(len(req.World[0]), len(req.World))
+	}
+	if err == nil {
+		_, err = rule.OnTopology(r, topology)
+	}
+	if err != nil {
+		fmt.Println(err)
+		err = errTopology
+		res.Message = "invalid topology"
+		return
+	}
+	// Workers only swap halo rows with the workers next to them, so each of them needs enough rows to fill their halos
+	if req.NumWorkers > 1 && len(req.World)/req.NumWorkers < r.Halo() {
+		err = errHalo
+		res.Message = "too many workers for the range of the rule"
+		return
+	}
+	// Only one session runs at a time, so stop the previous one and wait for it to finish
+	previous, err := e.claim(req.ControllerID)
+	if err != nil {
+		res.Message = "cannot replace the running session"
+		return
+	}
+	defer e.release()
+	if previous != nil {
+		stop(previous, e.cmdChan)
+		<-previous.done
+	}
+
+	fmt.Println("Starting game of life")
+	s := newSession(req.HistoryBudget, r.Levels())
+	s.stopOnCycle = req.StopOnCycle
+	s.statsRegions = req.StatsRegions
+	s.heatMap = req.HeatMap
+	s.rule = req.Rule
+	s.topology = req.Topology
+	s.height = height
+	s.depth = req.Depth
+	s.seed = req.Seed
+	fmt.Println()
+	workerClients, err := dialWorkers(s, req.NumWorkers)
+	if err != nil {
+		fmt.Print(err, "\n\n")
+		res.Message = "workers unreachable"
+		return
+	}
+	fmt.Println()
+	// The session is marked as running as soon as it's started, rather than once the workers have computed its first turn
+	e.mu.Lock()
+	e.session = s
+	e.running = true
+	e.mu.Unlock()
+	res.ControllerID = e.attach(s, stubs.Operator)
+	go e.gameOfLife(s, workerClients, req.NumWorkers, req.Turn, req.Turns, req.World)
+	res.Message = "received world"
+	return
+}
+
+// GetResults : gets the result after all turns have been computed. Any number of controllers can wait for the results at once.
+func (e *Engine) GetResults(req stubs.RequestResult, res *stubs.ResponseResult) (err error) {
+	s, err := e.currentSession()
+	if err != nil {
+		return
+	}
+	result, stopped := getResults(s)
+	if err = s.failure(); err != nil {
+		return
+	}
+	res.World = result.World
+	res.Turn = result.Turn
+	res.Stopped = stopped
+	res.Cycle = s.getCycle()
+	return
+}
+
+// AliveCells : gets the number of alive cells when requested by the controller
+func (e *Engine) AliveCells(req stubs.RequestAliveCells, res *stubs.ResponseAliveCells) (err error) {
+	s, err := e.currentSession()
+	if err != nil {
+		return
+	}
+	aliveCells := getAliveCells(s, e.aliveCellsChan, e.cmdChan)
+	if err = s.failure(); err != nil {
+		return
+	}
+	res.NumAliveCells = aliveCells.NumAliveCells
+	res.CompletedTurns = aliveCells.CompletedTurns
+	res.Cycle = s.getCycle()
+	return
+}
+
+// GetPGM : gets the board state so it can be sent to the controller to be saved as a PGM image
+func (e *Engine) GetPGM(req stubs.RequestPGM, res *stubs.ResponsePGM) (err error) {
+	s, err := e.currentSession()
+	if err != nil {
+		return
+	}
+	boardState := getPGM(s, e.workChan, e.cmdChan)
+	if err = s.failure(); err != nil {
+		return
+	}
+	res.World = boardState.World
+	res.Turn = boardState.Turn
+	return
+}
+
+// Census : counts the still lifes, oscillators and spaceships on the board
+func (e *Engine) Census(req stubs.RequestCensus, res *stubs.ResponseCensus) (err error) {
+	s, err := e.currentSession()
+	if err != nil {
+		return
+	}
+	boardState := getPGM(s, e.workChan, e.cmdChan)
+	if err = s.failure(); err != nil {
+		return
+	}
+	res.Objects = census.Take(boardState.World)
+	res.CompletedTurns = boardState.Turn
+	return
+}
+
+// GetStats : gets the population and activity metrics of a range of turns, so the controller can write them out as it goes
+func (e *Engine) GetStats(req stubs.RequestStats, res *stubs.ResponseStats) (err error) {
+	s, err := e.currentSession()
+	if err != nil {
+		return
+	}
+	res.Turns, err = s.statsBetween(req.From, req.To)
+	return
+}
+
+// GetHeatMap : gets how often each cell has been alive and flipped so far, assembled from the counters the workers keep for their part
+func (e *Engine) GetHeatMap(req stubs.RequestHeatMap, res *stubs.ResponseHeatMap) (err error) {
+	s, err := e.currentSession()
+	if err != nil {
+		return
+	}
+	if !s.heatMap {
+		return errNoHeatMap
+	}
+	heat := getHeatMap(s, e.heatChan, e.cmdChan)
+	if err = s.failure(); err != nil {
+		return
+	}
+	res.Alive = heat.counters.Alive
+	res.Flips = heat.counters.Flips
+	res.Turn = heat.turn
+	return
+}
+
+// SoupSearch : runs random soups on the workers until they settle and censuses the ash, responding once they've all been run.
+// Soups don't use the worker worlds, so a search can be run alongside a session.
+func (e *Engine) SoupSearch(req stubs.RequestSoupSearch, res *stubs.ResponseSoupSearch) (err error) {
+	*res, err = searchSoups(req)
+	return
+}
+
+// Pause : pauses the computation
+func (e *Engine) Pause(req stubs.RequestPause, res *stubs.ResponsePause) (err error) {
+	if err = e.authorise(req.ControllerID); err != nil {
+		return
+	}
+	s, err := e.currentSession()
+	if err != nil {
+		return
+	}
+	res.Message, err = pause(s, e.cmdChan, e.responseMsgChan)
+	return
+}
+
+// Resume : resumes the computation after it's been paused
+func (e *Engine) Resume(req stubs.RequestResume, res *stubs.ResponseResume) (err error) {
+	if err = e.authorise(req.ControllerID); err != nil {
+		return
+	}
+	s, err := e.currentSession()
+	if err != nil {
+		return
+	}
+	res.Message, err = resume(s, e.cmdChan, e.responseMsgChan)
+	return
+}
+
+// Step : computes a single turn while paused
+func (e *Engine) Step(req stubs.RequestStep, res *stubs.ResponseStep) (err error) {
+	return e.RunFor(stubs.RequestRunFor{ControllerID: req.ControllerID, Turns: 1}, res)
+}
+
+// RunFor : computes the given number of turns while paused, responding once they've all been computed
+func (e *Engine) RunFor(req stubs.RequestRunFor, res *stubs.ResponseStep) (err error) {
+	if err = e.authorise(req.ControllerID); err != nil {
+		return
+	}
+	if req.Turns <= 0 {
+		return errTurns
+	}
+	s, err := e.currentSession()
+	if err != nil {
+		return
+	}
+	aliveCells, err := runFor(s, req.Turns, e.cmdChan, e.runForChan, e.stepDoneChan)
+	if err == nil {
+		err = s.failure()
+	}
+	if err != nil {
+		return
+	}
+	res.CompletedTurns = aliveCells.CompletedTurns
+	res.NumAliveCells = aliveCells.NumAliveCells
+	return
+}
+
+// Rewind : goes back the given number of turns while paused, or as far back as the history goes
+func (e *Engine) Rewind(req stubs.RequestRewind, res *stubs.ResponseStep) (err error) {
+	if err = e.authorise(req.ControllerID); err != nil {
+		return
+	}
+	if req.Turns <= 0 {
+		return errTurns
+	}
+	return e.seek(seekRequest{back: req.Turns}, res)
+}
+
+// Seek : goes back to the given turn while paused, if it's still kept in the history
+func (e *Engine) Seek(req stubs.RequestSeek, res *stubs.ResponseStep) (err error) {
+	if err = e.authorise(req.ControllerID); err != nil {
+		return
+	}
+	return e.seek(seekRequest{turn: req.Turn}, res)
+}
+
+// seek : restores the current session to a retained turn and reports its alive cells
+func (e *Engine) seek(req seekRequest, res *stubs.ResponseStep) (err error) {
+	s, err := e.currentSession()
+	if err != nil {
+		return
+	}
+	aliveCells, err := seek(s, req, e.cmdChan, e.seekChan, e.seekDoneChan)
+	if err == nil {
+		err = s.failure()
+	}
+	if err != nil {
+		return
+	}
+	res.CompletedTurns = aliveCells.CompletedTurns
+	res.NumAliveCells = aliveCells.NumAliveCells
+	return
+}
+
+// SetCells : sets cells of the world to grey levels while paused, snapped onto the states of the rule, and sends back
+// the cells that changed
+func (e *Engine) SetCells(req stubs.RequestSetCells, res *stubs.ResponseSetCells) (err error) {
+	if err = e.authorise(req.ControllerID); err != nil {
+		return
+	}
+	s, err := e.currentSession()
+	if err != nil {
+		return
+	}
+	result := editCells(s, req.Cells, e.cmdChan, e.setCellsChan, e.setCellsDoneChan)
+	if err = result.err; err == nil {
+		err = s.failure()
+	}
+	if err != nil {
+		return
+	}
+	res.Cells = result.cells
+	res.CompletedTurns = result.aliveCells.CompletedTurns
+	res.NumAliveCells = result.aliveCells.NumAliveCells
+	return
+}
+
+// GetTurn : gets the board state after a turn kept in the history, so it can be saved as a PGM image without rewinding
+func (e *Engine) GetTurn(req stubs.RequestTurn, res *stubs.ResponsePGM) (err error) {
+	s, err := e.currentSession()
+	if err != nil {
+		return
+	}
+	work, err := s.retained(req.Turn)
+	if err != nil {
+		return
+	}
+	res.World = work.World
+	res.Turn = work.Turn
+	return
+}
+
+// WaitStateChange : blocks until the state of the session differs from the state known by the controller.
+// Controllers call this in a loop to be told about every change of state, e.g. when another controller pauses.
+func (e *Engine) WaitStateChange(req stubs.RequestStateChange, res *stubs.ResponseStateChange) (err error) {
+	s, err := e.currentSession()
+	if err != nil {
+		return
+	}
+	res.State, res.CompletedTurns = s.waitStateChange(req.Known)
+	return
+}
+
+// Stop : stops the computation
+func (e *Engine) Stop(req stubs.RequestStop, res *stubs.ResponseStop) (err error) {
+	if err = e.authorise(req.ControllerID); err != nil {
+		return
+	}
+	s, err := e.currentSession()
+	if err != nil {
+		return
+	}
+	res.Message = stop(s, e.cmdChan)
+	return
+}
+
+// Status : checks if engine is already running
+func (e *Engine) Status(req stubs.RequestStatus, res *stubs.ResponseStatus) (err error) {
+	res.Running = e.isRunning()
+	return
+}
+
+// Reconnect : attaches another controller to the engine while it's processing work, either as an operator or an observer.
+// Only operators can attach another operator, by handing on their ID, so observers can't give themselves more rights.
+func (e *Engine) Reconnect(req stubs.RequestReconnect, res *stubs.ResponseReconnect) (err error) {
+	s, err := e.currentSession()
+	if err != nil {
+		return
+	}
+	role := stubs.Observer
+	if req.Role != stubs.Observer {
+		if err = e.authorise(req.ControllerID); err != nil {
+			return
+		}
+		role = stubs.Operator
+	}
+	res.ControllerID = e.attach(s, role)
+	res.Message = reconnect(role)
+	return
+}
+
+// StopWorkers : commands the engine to send out requests to workers to be stopped
+func (e *Engine) StopWorkers(req stubs.RequestStopWorkers, res *stubs.ResponseStopWorkers) (err error) {
+	if err = e.authorise(req.ControllerID); err != nil {
+		return
+	}
+	s, err := e.currentSession()
+	if err != nil {
+		return
+	}
+	select {
+	case e.cmdChan <- requestStopWorkers:
+		res.OK = <-e.okChan
+	case <-s.done:
+		err = errFinished
+	}
+	return
+}
+
+// NewEngine : makes an engine waiting for a controller to start a game
+func NewEngine() *Engine {
+	return &Engine{
+		workChan:         make(chan Work),
+		aliveCellsChan:   make(chan AliveCells),
+		cmdChan:          make(chan int),
+		responseMsgChan:  make(chan string),
+		okChan:           make(chan bool),
+		runForChan:       make(chan int),
+		stepDoneChan:     make(chan AliveCells),
+		seekChan:         make(chan seekRequest),
+		seekDoneChan:     make(chan seekResult),
+		setCellsChan:     make(chan []stubs.CellState),
+		setCellsDoneChan: make(chan setCellsResult),
+		heatChan:         make(chan HeatMap),
+	}
+}
+
+// Serve : registers the engine on its own RPC server and serves it on the listener until the listener is closed
+func Serve(listener net.Listener, e *Engine) {
+	server := rpc.NewServer()
+	server.Register(e)
+	server.Accept(listener)
+}
@@ -0,0 +1,617 @@
+package broker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"uk.ac.bris.cs/gameoflife/heatmap"
+	"uk.ac.bris.cs/gameoflife/rule"
+	"uk.ac.bris.cs/gameoflife/stats"
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+// ServeHTTP : serves a HTTP/JSON API next to net/rpc, so clients that don't speak gob can control the engine.
+// Every endpoint calls the same method as the matching RPC handler in stubs.
+func ServeHTTP(addr string, e *Engine) {
+	fmt.Println("HTTP API listening on", addr)
+	err := http.ListenAndServe(addr, Handler(e))
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// Handler : routes the HTTP/JSON API and the live viewer onto the engine, as served by ServeHTTP
+func Handler(e *Engine) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/runs", allowMethod(http.MethodPost, e.handleStart))
+	mux.HandleFunc("/api/soups", allowMethod(http.MethodPost, e.handleSoupSearch))
+	mux.HandleFunc("/api/status", allowMethod(http.MethodGet, e.handleStatus))
+	mux.HandleFunc("/api/controllers", allowMethod(http.MethodPost, e.handleAttach))
+	mux.HandleFunc("/api/alive", allowMethod(http.MethodGet, e.handleAliveCells))
+	mux.HandleFunc("/api/snapshot", allowMethod(http.MethodGet, e.handleSnapshot))
+	mux.HandleFunc("/api/census", allowMethod(http.MethodGet, e.handleCensus))
+	mux.HandleFunc("/api/stats", allowMethod(http.MethodGet, e.handleStats))
+	mux.HandleFunc("/api/heatmap", allowMethod(http.MethodGet, e.handleHeatMap))
+	mux.HandleFunc("/api/results", allowMethod(http.MethodGet, e.handleResults))
+	mux.HandleFunc("/api/pause", allowMethod(http.MethodPost, e.handlePause))
+	mux.HandleFunc("/api/resume", allowMethod(http.MethodPost, e.handleResume))
+	mux.HandleFunc("/api/step", allowMethod(http.MethodPost, e.handleStep))
+	mux.HandleFunc("/api/rewind", allowMethod(http.MethodPost, e.handleRewind))
+	mux.HandleFunc("/api/seek", allowMethod(http.MethodPost, e.handleSeek))
+	mux.HandleFunc("/api/cells", allowMethod(http.MethodPost, e.handleSetCells))
+	mux.HandleFunc("/api/history", allowMethod(http.MethodGet, e.handleHistory))
+	mux.HandleFunc("/api/stop", allowMethod(http.MethodPost, e.handleStop))
+	mux.HandleFunc("/api/workers/stop", allowMethod(http.MethodPost, e.handleStopWorkers))
+	mux.HandleFunc("/api/openapi.json", allowMethod(http.MethodGet, handleOpenAPI))
+	mux.HandleFunc("/ws", e.handleViewerSocket)
+	mux.HandleFunc("/", allowMethod(http.MethodGet, handleViewerPage))
+	return mux
+}
+
+// allowMethod : rejects requests that don't use the given method with 405 Method Not Allowed
+func allowMethod(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// writeJSON : writes a response struct from stubs as the JSON body
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// writeError : writes an error as a JSON body of the form {"error": "..."}
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// errorStatus : maps errors returned by the engine onto HTTP status codes
+func errorStatus(err error) int {
+	switch err {
+	case errNoWorld:
+		return http.StatusBadRequest
+	case errTurns, errSoups, errWorkers, errRule, errHalo, errTopology, errVolume, errCells:
+		return http.StatusBadRequest
+	case errNotStarted, errStarting, errFinished, errNotPaused, errNotEditable, errNoHistory, errNoStats, errNoHeatMap:
+		return http.StatusConflict
+	case errNotRetained:
+		return http.StatusNotFound
+	case errNotAttached:
+		return http.StatusUnauthorized
+	case errObserver:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// intParam : reads an integer query parameter, falling back to def if it's missing
+func intParam(r *http.Request, name string, def int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer", name)
+	}
+	return n, nil
+}
+
+// controllerParam : reads the ID of the controller making an operator request
+func controllerParam(r *http.Request) (string, error) {
+	id := r.URL.Query().Get("controller")
+	if id == "" {
+		return "", errors.New("controller must be specified")
+	}
+	return id, nil
+}
+
+// decodePGM : decodes a binary (P5) PGM image into a world
+func decodePGM(data []byte) ([][]byte, error) {
+	var fields []int
+	i := 2
+	if len(data) < 2 || string(data[:2]) != "P5" {
+		return nil, errors.New("not a pgm file")
+	}
+	for len(fields) < 3 {
+		for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\r' || data[i] == '\n') {
+			i++
+		}
+		start := i
+		for i < len(data) && data[i] >= '0' && data[i] <= '9' {
+			i++
+		}
+		if start == i {
+			return nil, errors.New("malformed pgm header")
+		}
+		n, _ := strconv.Atoi(string(data[start:i]))
+		fields = append(fields, n)
+	}
+	width, height, maxval := fields[0], fields[1], fields[2]
+	if maxval != 255 {
+		return nil, errors.New("incorrect maxval/bit depth")
+	}
+	i++ // single whitespace byte after the header
+	if width <= 0 || height <= 0 || len(data)-i < width*height {
+		return nil, errors.New("pgm image data is too short")
+	}
+	world := makeWorld(height, width)
+	for y := range world {
+		copy(world[y], data[i+y*width:i+(y+1)*width])
+	}
+	return world, nil
+}
+
+// writeImage : writes a world as a PGM or PNG image depending on the format query parameter
+func writeImage(w http.ResponseWriter, r *http.Request, work Work) {
+	if len(work.World) == 0 {
+		writeError(w, http.StatusConflict, errors.New("no world available"))
+		return
+	}
+	height := len(work.World)
+	width := len(work.World[0])
+	var body bytes.Buffer
+	switch r.URL.Query().Get("format") {
+	case "", "pgm":
+		w.Header().Set("Content-Type", "image/x-portable-graymap")
+		fmt.Fprintf(&body, "P5\n%d %d\n255\n", width, height)
+		for y := range work.World {
+			body.Write(work.World[y])
+		}
+	case "png":
+		w.Header().Set("Content-Type", "image/png")
+		img := image.NewGray(image.Rect(0, 0, width, height))
+		for y := range work.World {
+			copy(img.Pix[y*img.Stride:], work.World[y])
+		}
+		if err := png.Encode(&body, img); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	default:
+		writeError(w, http.StatusBadRequest, errors.New("format must be pgm or png"))
+		return
+	}
+	w.Header().Set("X-Completed-Turns", strconv.Itoa(work.Turn))
+	_, _ = w.Write(body.Bytes())
+}
+
+// handleStart : POST /api/runs?controller=&turns=&workers=&history=&stopOnCycle=&regions=&heatmap=&rule=&topology=&depth=&seed= with a PGM
+// image as the body, history being the budget in MB. Stats are kept for the run if regions is set, the rule is in B/S/C or LtL
+// notation or the name of a rule, e.g. Wireworld, 3d:4555 or stochastic:B3/S23,birth=0.9, Life by default, and the topology is
+// square, hex or triangular, square by default. A volume for a 3D rule is sent as its depth slices stacked one after the
+// other in the image, and stochastic rules draw their random numbers from the seed, 0 by default. Starting a run stops the
+// one that's running, so the controller has to be one of its operators if there is one.
+func (e *Engine) handleStart(w http.ResponseWriter, r *http.Request) {
+	turns, err := intParam(r, "turns", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	workers, err := intParam(r, "workers", 1)
+	if err != nil || workers < 1 || workers > len(workerIPs) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("workers must be between 1 and %d", len(workerIPs)))
+		return
+	}
+	historyMB, err := intParam(r, "history", 0)
+	if err != nil || historyMB < 0 {
+		writeError(w, http.StatusBadRequest, errors.New("history must be a non-negative number of MB"))
+		return
+	}
+	stopOnCycle := r.URL.Query().Get("stopOnCycle") == "true"
+	regions, err := intParam(r, "regions", 0)
+	if err != nil || regions < 0 {
+		writeError(w, http.StatusBadRequest, errors.New("regions must be a non-negative integer"))
+		return
+	}
+	heatMap := r.URL.Query().Get("heatmap") == "true"
+	depth, err := intParam(r, "depth", 0)
+	if err != nil || depth < 0 {
+		writeError(w, http.StatusBadRequest, errors.New("depth must be a non-negative number of slices"))
+		return
+	}
+	seed, err := intParam(r, "seed", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("seed must be an integer"))
+		return
+	}
+	runRule, err := rule.Parse(r.URL.Query().Get("rule"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	world, err := decodePGM(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	for y := range world {
+		for x := range world[y] {
+			world[y][x] = runRule.Quantise(world[y][x])
+		}
+	}
+
+	res := new(stubs.ResponseStart)
+	if err = e.GameOfLife(stubs.RequestStart{ControllerID: r.URL.Query().Get("controller"), World: world, Turns: turns, NumWorkers: workers, HistoryBudget: historyMB * 1024 * 1024, StopOnCycle: stopOnCycle, StatsRegions: regions, HeatMap: heatMap, Rule: runRule.Spec(), Topology: r.URL.Query().Get("topology"), Depth: depth, Seed: int64(seed)}, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, res)
+}
+
+// handleSoupSearch : POST /api/soups?soups=&size=&density=&turns=&workers=&seed= runs a soup search and responds with its results
+func (e *Engine) handleSoupSearch(w http.ResponseWriter, r *http.Request) {
+	var req stubs.RequestSoupSearch
+	var err error
+	for _, param := range []struct {
+		name  string
+		value *int
+		def   int
+	}{{"soups", &req.Soups, 0}, {"size", &req.Size, defaultSoupSize}, {"turns", &req.MaxTurns, maxSoupTurns}, {"workers", &req.NumWorkers, 1}} {
+		if *param.value, err = intParam(r, param.name, param.def); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if density := r.URL.Query().Get("density"); density != "" {
+		if req.Density, err = strconv.ParseFloat(density, 64); err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("density must be a number"))
+			return
+		}
+	}
+	if seed := r.URL.Query().Get("seed"); seed != "" {
+		if req.Seed, err = strconv.ParseInt(seed, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("seed must be an integer"))
+			return
+		}
+	}
+	res := new(stubs.ResponseSoupSearch)
+	if err = e.SoupSearch(req, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleStatus : GET /api/status
+func (e *Engine) handleStatus(w http.ResponseWriter, r *http.Request) {
+	res := new(stubs.ResponseStatus)
+	_ = e.Status(stubs.RequestStatus{}, res)
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleAttach : POST /api/controllers?role=operator|observer&controller=, where attaching as an operator takes the ID of
+// one of the operators of the run
+func (e *Engine) handleAttach(w http.ResponseWriter, r *http.Request) {
+	var role stubs.Role
+	switch r.URL.Query().Get("role") {
+	case "", "observer":
+		role = stubs.Observer
+	case "operator":
+		role = stubs.Operator
+	default:
+		writeError(w, http.StatusBadRequest, errors.New("role must be operator or observer"))
+		return
+	}
+	res := new(stubs.ResponseReconnect)
+	if err := e.Reconnect(stubs.RequestReconnect{Role: role, ControllerID: r.URL.Query().Get("controller")}, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, res)
+}
+
+// handleAliveCells : GET /api/alive
+func (e *Engine) handleAliveCells(w http.ResponseWriter, r *http.Request) {
+	res := new(stubs.ResponseAliveCells)
+	if err := e.AliveCells(stubs.RequestAliveCells{}, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleSnapshot : GET /api/snapshot?format=pgm|png
+func (e *Engine) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	res := new(stubs.ResponsePGM)
+	if err := e.GetPGM(stubs.RequestPGM{}, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeImage(w, r, Work{World: res.World, Turn: res.Turn})
+}
+
+// handleCensus : GET /api/census
+func (e *Engine) handleCensus(w http.ResponseWriter, r *http.Request) {
+	res := new(stubs.ResponseCensus)
+	if err := e.Census(stubs.RequestCensus{}, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleStats : GET /api/stats?from=&to=&format=json|csv|jsonl, with to being the latest turn if it's missing
+func (e *Engine) handleStats(w http.ResponseWriter, r *http.Request) {
+	from, err := intParam(r, "from", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	to, err := intParam(r, "to", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res := new(stubs.ResponseStats)
+	if err = e.GetStats(stubs.RequestStats{From: from, To: to}, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "csv" && format != "jsonl" {
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+	s, _ := e.currentSession()
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	writer := stats.NewWriter(w, format == "jsonl", s.statsRegions)
+	for _, t := range res.Turns {
+		if err = writer.Write(t); err != nil {
+			return
+		}
+	}
+	_ = writer.Flush()
+}
+
+// handleHeatMap : GET /api/heatmap?kind=alive|flips&format=pgm|png, normalised to 8 bits with the PNG in false colour
+func (e *Engine) handleHeatMap(w http.ResponseWriter, r *http.Request) {
+	res := new(stubs.ResponseHeatMap)
+	if err := e.GetHeatMap(stubs.RequestHeatMap{}, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	var counts [][]uint32
+	switch r.URL.Query().Get("kind") {
+	case "", "alive":
+		counts = res.Alive
+	case "flips":
+		counts = res.Flips
+	default:
+		writeError(w, http.StatusBadRequest, errors.New("kind must be alive or flips"))
+		return
+	}
+	if len(counts) == 0 {
+		writeError(w, http.StatusConflict, errors.New("no heat map available"))
+		return
+	}
+	grey := heatmap.Normalise(counts)
+	var body bytes.Buffer
+	var err error
+	switch r.URL.Query().Get("format") {
+	case "", "pgm":
+		w.Header().Set("Content-Type", "image/x-portable-graymap")
+		err = heatmap.WritePGM(&body, grey)
+	case "png":
+		w.Header().Set("Content-Type", "image/png")
+		err = heatmap.WritePNG(&body, grey)
+	default:
+		writeError(w, http.StatusBadRequest, errors.New("format must be pgm or png"))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("X-Completed-Turns", strconv.Itoa(res.Turn))
+	_, _ = w.Write(body.Bytes())
+}
+
+// handleResults : GET /api/results?format=pgm|png, blocks until the run has finished
+func (e *Engine) handleResults(w http.ResponseWriter, r *http.Request) {
+	res := new(stubs.ResponseResult)
+	if err := e.GetResults(stubs.RequestResult{}, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.Header().Set("X-Stopped", strconv.FormatBool(res.Stopped))
+	if res.Cycle != nil {
+		w.Header().Set("X-Cycle-Period", strconv.Itoa(res.Cycle.Period))
+		w.Header().Set("X-Cycle-Start", strconv.Itoa(res.Cycle.Start))
+	}
+	writeImage(w, r, Work{World: res.World, Turn: res.Turn})
+}
+
+// handlePause : POST /api/pause?controller=
+func (e *Engine) handlePause(w http.ResponseWriter, r *http.Request) {
+	id, err := controllerParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res := new(stubs.ResponsePause)
+	if err = e.Pause(stubs.RequestPause{ControllerID: id}, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleResume : POST /api/resume?controller=
+func (e *Engine) handleResume(w http.ResponseWriter, r *http.Request) {
+	id, err := controllerParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res := new(stubs.ResponseResume)
+	if err = e.Resume(stubs.RequestResume{ControllerID: id}, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleStep : POST /api/step?controller=&turns= computes turns while paused, one by default
+func (e *Engine) handleStep(w http.ResponseWriter, r *http.Request) {
+	id, err := controllerParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	turns, err := intParam(r, "turns", 1)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res := new(stubs.ResponseStep)
+	if err = e.RunFor(stubs.RequestRunFor{ControllerID: id, Turns: turns}, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleRewind : POST /api/rewind?controller=&turns= goes back turns while paused, one by default
+func (e *Engine) handleRewind(w http.ResponseWriter, r *http.Request) {
+	id, err := controllerParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	turns, err := intParam(r, "turns", 1)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res := new(stubs.ResponseStep)
+	if err = e.Rewind(stubs.RequestRewind{ControllerID: id, Turns: turns}, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleSeek : POST /api/seek?controller=&turn= goes back to a retained turn while paused
+func (e *Engine) handleSeek(w http.ResponseWriter, r *http.Request) {
+	id, err := controllerParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if r.URL.Query().Get("turn") == "" {
+		writeError(w, http.StatusBadRequest, errors.New("turn must be specified"))
+		return
+	}
+	turn, err := intParam(r, "turn", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res := new(stubs.ResponseStep)
+	if err = e.Seek(stubs.RequestSeek{ControllerID: id, Turn: turn}, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleSetCells : POST /api/cells?controller= sets the cells in the JSON body, e.g. [{"x": 1, "y": 2, "state": 255}],
+// while paused
+func (e *Engine) handleSetCells(w http.ResponseWriter, r *http.Request) {
+	id, err := controllerParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var cells []stubs.CellState
+	if err = json.NewDecoder(r.Body).Decode(&cells); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("cells must be a JSON array of x, y and state: %v", err))
+		return
+	}
+	res := new(stubs.ResponseSetCells)
+	if err = e.SetCells(stubs.RequestSetCells{ControllerID: id, Cells: cells}, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleHistory : GET /api/history?turn=&format=pgm|png exports a retained turn without rewinding
+func (e *Engine) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("turn") == "" {
+		writeError(w, http.StatusBadRequest, errors.New("turn must be specified"))
+		return
+	}
+	turn, err := intParam(r, "turn", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res := new(stubs.ResponsePGM)
+	if err = e.GetTurn(stubs.RequestTurn{Turn: turn}, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeImage(w, r, Work{World: res.World, Turn: res.Turn})
+}
+
+// handleStop : POST /api/stop?controller=
+func (e *Engine) handleStop(w http.ResponseWriter, r *http.Request) {
+	id, err := controllerParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res := new(stubs.ResponseStop)
+	if err = e.Stop(stubs.RequestStop{ControllerID: id}, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleStopWorkers : POST /api/workers/stop?controller=
+func (e *Engine) handleStopWorkers(w http.ResponseWriter, r *http.Request) {
+	id, err := controllerParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res := new(stubs.ResponseStopWorkers)
+	if err = e.StopWorkers(stubs.RequestStopWorkers{ControllerID: id}, res); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleOpenAPI : GET /api/openapi.json
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(openAPISpec))
+}
@@ -0,0 +1,362 @@
+package broker
+
+// openAPISpec : OpenAPI description of the HTTP/JSON API served by ServeHTTP, available at /api/openapi.json
+const openAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Game of Life engine",
+    "version": "1.0.0",
+    "description": "HTTP/JSON API mirroring the net/rpc handlers in stubs. Operator requests take the controller ID returned when starting a run or attaching to one, which is random so it can't be guessed."
+  },
+  "paths": {
+    "/api/runs": {
+      "post": {
+        "summary": "Start a new run, stopping the current one if there is one",
+        "parameters": [
+          {"name": "controller", "in": "query", "description": "ID of an operator of the run that's running, which is required to stop it", "schema": {"type": "string"}},
+          {"name": "turns", "in": "query", "schema": {"type": "integer", "default": 0}},
+          {"name": "workers", "in": "query", "schema": {"type": "integer", "minimum": 1, "default": 1}},
+          {"name": "history", "in": "query", "description": "Memory budget in MB for keeping recent turns to rewind to, disabled if 0", "schema": {"type": "integer", "minimum": 0, "default": 0}},
+          {"name": "stopOnCycle", "in": "query", "description": "Stop early once the board settles into a still life or an oscillator", "schema": {"type": "boolean", "default": false}},
+          {"name": "regions", "in": "query", "description": "Keep population and activity stats every turn, measuring the density in this many regions along each side. Disabled if 0", "schema": {"type": "integer", "minimum": 0, "default": 0}},
+          {"name": "heatmap", "in": "query", "description": "Count how often each cell is alive and flips, so heat maps can be exported", "schema": {"type": "boolean", "default": false}},
+          {"name": "rule", "in": "query", "description": "Generations rule in B/S/C notation, e.g. B2/S/C3 for Brian's Brain or B2n3/S23-q with Hensel's non-totalistic letters, Larger than Life rule in LtL notation, e.g. R5,C0,M1,S34..58,B34..45,NM, or the name of a rule, e.g. Wireworld, 3d:4555 for a 3D rule in Bays' notation or stochastic:B3/S23,birth=0.9,survive=0.95,update=0.5 for births, survivals and updates that only happen with a chance. Grey levels of the image are snapped onto its states", "schema": {"type": "string", "default": "B3/S23"}},
+          {"name": "topology", "in": "query", "description": "Lattice the cells are laid out on, with the odd rows of a hex world shifted half a cell right and the triangles of a triangular world pointing up where x+y is even. Hex and triangular worlds only run totalistic B/S/C rules", "schema": {"type": "string", "enum": ["square", "hex", "triangular"], "default": "square"}},
+          {"name": "depth", "in": "query", "description": "Number of slices of a volume for a 3D rule, e.g. 3d:4555 for Bays' 4555, stacked one after the other in the image. The world is flat if 0 or 1", "schema": {"type": "integer", "minimum": 0, "default": 0}},
+          {"name": "seed", "in": "query", "description": "Seed stochastic rules draw their random numbers from. Runs with the same seed give the same results whatever the number of workers", "schema": {"type": "integer", "default": 0}}
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {"image/x-portable-graymap": {"schema": {"type": "string", "format": "binary"}}}
+        },
+        "responses": {
+          "202": {"description": "Run started, the caller is attached as an operator", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Attached"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/soups": {
+      "post": {
+        "summary": "Run random soups on the workers until they settle and census the ash, responding once they've all been run",
+        "parameters": [
+          {"name": "soups", "in": "query", "required": true, "schema": {"type": "integer", "minimum": 1}},
+          {"name": "size", "in": "query", "schema": {"type": "integer", "minimum": 1, "default": 16}},
+          {"name": "density", "in": "query", "schema": {"type": "number", "minimum": 0, "maximum": 1, "default": 0.5}},
+          {"name": "turns", "in": "query", "description": "Soups that haven't settled after this many turns are censused as they are", "schema": {"type": "integer", "minimum": 1, "default": 100000}},
+          {"name": "workers", "in": "query", "schema": {"type": "integer", "minimum": 1, "default": 1}},
+          {"name": "seed", "in": "query", "description": "Seed of the first soup, the others use consecutive seeds. Random if 0", "schema": {"type": "integer", "default": 0}}
+        ],
+        "responses": {
+          "200": {"description": "Search results", "content": {"application/json": {"schema": {"type": "object", "properties": {
+            "soups": {"type": "integer"},
+            "settled": {"type": "integer"},
+            "objects": {"type": "object", "additionalProperties": {"type": "integer"}},
+            "rare": {"type": "array", "items": {"type": "object", "properties": {"name": {"type": "string"}, "seed": {"type": "integer"}}}},
+            "seconds": {"type": "number"},
+            "soupsPerSecond": {"type": "number"}
+          }}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/status": {
+      "get": {
+        "summary": "Check whether the engine is running",
+        "responses": {
+          "200": {"description": "Status", "content": {"application/json": {"schema": {"type": "object", "properties": {"running": {"type": "boolean"}}}}}}
+        }
+      }
+    },
+    "/api/controllers": {
+      "post": {
+        "summary": "Attach to the running session",
+        "parameters": [
+          {"name": "role", "in": "query", "schema": {"type": "string", "enum": ["observer", "operator"], "default": "observer"}},
+          {"name": "controller", "in": "query", "description": "ID of an operator of the run, which is required to attach as an operator", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "201": {"description": "Attached", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Attached"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/alive": {
+      "get": {
+        "summary": "Number of alive cells and completed turns",
+        "responses": {
+          "200": {"description": "Alive cells, and the cycle the board has settled into once it's been detected", "content": {"application/json": {"schema": {"type": "object", "properties": {"completedTurns": {"type": "integer"}, "aliveCells": {"type": "integer"}, "cycle": {"$ref": "#/components/schemas/Cycle"}}}}}},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/snapshot": {
+      "get": {
+        "summary": "Current board state",
+        "parameters": [{"$ref": "#/components/parameters/Format"}],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Image"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/census": {
+      "get": {
+        "summary": "Number of still lifes, oscillators and spaceships of each type on the board, with objects that aren't recognised counted as unknown",
+        "responses": {
+          "200": {"description": "Census", "content": {"application/json": {"schema": {"type": "object", "properties": {"completedTurns": {"type": "integer"}, "objects": {"type": "object", "additionalProperties": {"type": "integer"}}}}}}},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/stats": {
+      "get": {
+        "summary": "Population, births, deaths, bounding box and density per region of each turn in a range. The CSV columns start the same way as check/alive/*.csv",
+        "parameters": [
+          {"name": "from", "in": "query", "schema": {"type": "integer", "minimum": 0, "default": 0}},
+          {"name": "to", "in": "query", "description": "Last turn of the range, the latest turn if missing", "schema": {"type": "integer", "minimum": 0}},
+          {"name": "format", "in": "query", "schema": {"type": "string", "enum": ["json", "csv", "jsonl"], "default": "json"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "Stats",
+            "content": {
+              "application/json": {"schema": {"type": "object", "properties": {"turns": {"type": "array", "items": {"$ref": "#/components/schemas/Stats"}}}}},
+              "text/csv": {"schema": {"type": "string"}},
+              "application/x-ndjson": {"schema": {"type": "string"}}
+            }
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/heatmap": {
+      "get": {
+        "summary": "Heat map of how often each cell has been alive or flipped, normalised so the busiest cell is 255. PNGs are in false colour",
+        "parameters": [
+          {"name": "kind", "in": "query", "schema": {"type": "string", "enum": ["alive", "flips"], "default": "alive"}},
+          {"$ref": "#/components/parameters/Format"}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Image"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/results": {
+      "get": {
+        "summary": "Final board state, blocks until the run has finished. If a cycle was detected, its period and first turn are in the X-Cycle-Period and X-Cycle-Start headers",
+        "parameters": [{"$ref": "#/components/parameters/Format"}],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Image"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/pause": {
+      "post": {
+        "summary": "Pause the run. Alive cells and snapshots can still be requested while paused",
+        "parameters": [{"$ref": "#/components/parameters/Controller"}],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Message"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/resume": {
+      "post": {
+        "summary": "Resume the run after it's been paused",
+        "parameters": [{"$ref": "#/components/parameters/Controller"}],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Message"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/step": {
+      "post": {
+        "summary": "Compute turns while paused, responding once they've all been computed",
+        "parameters": [
+          {"$ref": "#/components/parameters/Controller"},
+          {"name": "turns", "in": "query", "schema": {"type": "integer", "minimum": 1, "default": 1}}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Turn"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/rewind": {
+      "post": {
+        "summary": "Go back turns while paused, stopping at the oldest turn kept in the history",
+        "parameters": [
+          {"$ref": "#/components/parameters/Controller"},
+          {"name": "turns", "in": "query", "schema": {"type": "integer", "minimum": 1, "default": 1}}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Turn"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/seek": {
+      "post": {
+        "summary": "Go back to a turn kept in the history while paused",
+        "parameters": [
+          {"$ref": "#/components/parameters/Controller"},
+          {"name": "turn", "in": "query", "required": true, "schema": {"type": "integer", "minimum": 0}}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Turn"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/cells": {
+      "post": {
+        "summary": "Set cells to grey levels while paused, snapped onto the states of the rule",
+        "parameters": [{"$ref": "#/components/parameters/Controller"}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Cell"}}}}
+        },
+        "responses": {
+          "200": {
+            "description": "Cells that changed, with their grey levels snapped onto the states of the rule",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": {
+                    "cells": {"type": "array", "items": {"$ref": "#/components/schemas/Cell"}},
+                    "completedTurns": {"type": "integer"},
+                    "aliveCells": {"type": "integer"}
+                  }
+                }
+              }
+            }
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/history": {
+      "get": {
+        "summary": "Board state after a turn kept in the history, without rewinding to it",
+        "parameters": [
+          {"name": "turn", "in": "query", "required": true, "schema": {"type": "integer", "minimum": 0}},
+          {"$ref": "#/components/parameters/Format"}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Image"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/stop": {
+      "post": {
+        "summary": "Stop the run",
+        "parameters": [{"$ref": "#/components/parameters/Controller"}],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Message"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/workers/stop": {
+      "post": {
+        "summary": "Shut down the workers and the engine",
+        "parameters": [{"$ref": "#/components/parameters/Controller"}],
+        "responses": {
+          "200": {"description": "Workers stopped", "content": {"application/json": {"schema": {"type": "object", "properties": {"ok": {"type": "boolean"}}}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/openapi.json": {
+      "get": {
+        "summary": "Get this description of the API",
+        "responses": {
+          "200": {"description": "OpenAPI description", "content": {"application/json": {"schema": {"type": "object"}}}}
+        }
+      }
+    }
+  },
+  "components": {
+    "parameters": {
+      "Format": {"name": "format", "in": "query", "schema": {"type": "string", "enum": ["pgm", "png"], "default": "pgm"}},
+      "Controller": {"name": "controller", "in": "query", "required": true, "schema": {"type": "string"}}
+    },
+    "schemas": {
+      "Attached": {"type": "object", "properties": {"message": {"type": "string"}, "controllerId": {"type": "string"}}},
+      "Error": {"type": "object", "properties": {"error": {"type": "string"}}},
+      "Cell": {"type": "object", "properties": {"x": {"type": "integer"}, "y": {"type": "integer"}, "state": {"type": "integer", "minimum": 0, "maximum": 255}}},
+      "Cycle": {"type": "object", "properties": {"start": {"type": "integer"}, "period": {"type": "integer"}, "detectedOn": {"type": "integer"}}},
+      "Stats": {
+        "type": "object",
+        "properties": {
+          "completedTurns": {"type": "integer"},
+          "aliveCells": {"type": "integer"},
+          "births": {"type": "integer"},
+          "deaths": {"type": "integer"},
+          "minX": {"type": "integer"},
+          "minY": {"type": "integer"},
+          "maxX": {"type": "integer"},
+          "maxY": {"type": "integer"},
+          "regions": {"type": "array", "items": {"type": "number"}, "description": "Proportion of alive cells in each region, row by row"}
+        }
+      }
+    },
+    "responses": {
+      "Turn": {"description": "Turn the session is now on", "content": {"application/json": {"schema": {"type": "object", "properties": {"completedTurns": {"type": "integer"}, "aliveCells": {"type": "integer"}}}}}},
+      "Message": {"description": "OK", "content": {"application/json": {"schema": {"type": "object", "properties": {"message": {"type": "string"}}}}}},
+      "Error": {"description": "Error", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+      "Image": {
+        "description": "Board state, with the number of completed turns in the X-Completed-Turns header",
+        "content": {
+          "image/x-portable-graymap": {"schema": {"type": "string", "format": "binary"}},
+          "image/png": {"schema": {"type": "string", "format": "binary"}}
+        }
+      }
+    }
+  }
+}
+`
@@ -0,0 +1,146 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/census"
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/transport"
+)
+
+const (
+	// defaultSoupSize : side of the soups when the request doesn't give one
+	defaultSoupSize = 16
+
+	// defaultDensity : proportion of alive cells in the soups when the request doesn't give one
+	defaultDensity = 0.5
+
+	// maxSoupTurns : soups that haven't settled after this many turns are censused as they are
+	maxSoupTurns = 100000
+)
+
+// commonAsh : objects that turn up in most soups. Anything else that's recognised is logged as rare, with its seed.
+var commonAsh = map[string]bool{
+	"block":     true,
+	"beehive":   true,
+	"blinker":   true,
+	"loaf":      true,
+	"boat":      true,
+	"ship":      true,
+	"tub":       true,
+	"pond":      true,
+	"long boat": true,
+	"glider":    true,
+	"beacon":    true,
+	"toad":      true,
+}
+
+var (
+	errSoups   = errors.New("the number of soups must be positive")
+	errWorkers = errors.New("the number of workers must be positive and no more than are available")
+)
+
+func requestSoup(client transport.Client, request stubs.RequestSoup) (stubs.ResponseSoup, error) {
+	response := new(stubs.ResponseSoup)
+	err := client.Call(stubs.SoupHandler, request, response)
+	return *response, err
+}
+
+// soupResult : the census of a soup from a worker, or the error calling it
+type soupResult struct {
+	stubs.ResponseSoup
+	err error
+}
+
+// searchSoups : farms soups out to the workers as independent jobs and censuses the ash they settle into.
+// Soups are seeded with consecutive seeds from req.Seed, or from a random seed if it's 0, so any soup can be reproduced.
+func searchSoups(req stubs.RequestSoupSearch) (stubs.ResponseSoupSearch, error) {
+	if req.Soups <= 0 {
+		return stubs.ResponseSoupSearch{}, errSoups
+	}
+	if req.NumWorkers < 1 || req.NumWorkers > len(workerIPs) {
+		return stubs.ResponseSoupSearch{}, errWorkers
+	}
+	if req.Size <= 0 {
+		req.Size = defaultSoupSize
+	}
+	if req.Density <= 0 || req.Density > 1 {
+		req.Density = defaultDensity
+	}
+	if req.MaxTurns <= 0 || req.MaxTurns > maxSoupTurns {
+		req.MaxTurns = maxSoupTurns
+	}
+	if req.Seed == 0 {
+		req.Seed = rand.Int63()
+	}
+
+	workerClients := make([]transport.Client, req.NumWorkers)
+	for i := range workerClients {
+		client, err := workerTransport.Dial(workerIPs[i])
+		if err != nil {
+			return stubs.ResponseSoupSearch{}, err
+		}
+		defer client.Close()
+		workerClients[i] = client
+	}
+
+	// Each worker takes the next seed as soon as it's finished its last soup, so faster workers run more soups
+	jobs := make(chan int64, req.Soups)
+	for i := 0; i < req.Soups; i++ {
+		jobs <- req.Seed + int64(i)
+	}
+	close(jobs)
+	// Buffered so workers still running don't block once the search has given up on a failed one
+	results := make(chan soupResult, req.Soups)
+	for i, client := range workerClients {
+		go func(i int, client transport.Client) {
+			for seed := range jobs {
+				result, err := requestSoup(client, stubs.RequestSoup{Seed: seed, Size: req.Size, Density: req.Density, MaxTurns: req.MaxTurns})
+				if err != nil {
+					err = fmt.Errorf("worker %v failed soup %v: %v", i, seed, err)
+				}
+				results <- soupResult{result, err}
+			}
+		}(i, client)
+	}
+
+	fmt.Printf("Searching %d soups of size %dx%d with density %v from seed %d\n\n", req.Soups, req.Size, req.Size, req.Density, req.Seed)
+	res := stubs.ResponseSoupSearch{Objects: census.Census{}}
+	start := time.Now()
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for res.Soups < req.Soups {
+		select {
+		case result := <-results:
+			if result.err != nil {
+				return stubs.ResponseSoupSearch{}, result.err
+			}
+			res.Soups++
+			if result.Settled {
+				res.Settled++
+			}
+			names := make([]string, 0, len(result.Objects))
+			for name, count := range result.Objects {
+				res.Objects[name] += count
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if !commonAsh[name] && name != census.Unknown {
+					fmt.Println("Found", name, "in soup with seed", result.Seed)
+					res.Rare = append(res.Rare, stubs.RareObject{Name: name, Seed: result.Seed})
+				}
+			}
+		case <-ticker.C:
+			fmt.Printf("Searched %d soups, %.1f soups/s\n", res.Soups, float64(res.Soups)/time.Since(start).Seconds())
+		}
+	}
+	res.Seconds = time.Since(start).Seconds()
+	res.SoupsPerSecond = float64(res.Soups) / res.Seconds
+	fmt.Printf("Searched %d soups in %.1fs, %.1f soups/s\n\n", res.Soups, res.Seconds, res.SoupsPerSecond)
+	return res, nil
+}
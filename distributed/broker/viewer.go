@@ -0,0 +1,270 @@
+package broker
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+const (
+	// keyFrame : frame holding the whole board
+	keyFrame = iota
+
+	// diffFrame : frame holding the XOR of the board with the previously sent frame
+	diffFrame
+)
+
+const (
+	// frameHeaderSize : kind (1 byte) followed by turn, alive cells, width and height (4 bytes each) and the number of
+	// states of the rule (1 byte), which is followed by the grey level of each state
+	frameHeaderSize = 18
+
+	// maxFrameRate : upper bound on frames per second, even if the client keeps up
+	maxFrameRate = 30
+
+	// ackTimeout : how long to wait for the client to acknowledge a frame before sending a key frame again
+	ackTimeout = 5 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1 << 16,
+}
+
+// viewerMessage : JSON message sent between the browser and the engine over the WebSocket
+type viewerMessage struct {
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+}
+
+// stateBits : the number of bits each cell is packed into to hold the given number of states
+func stateBits(states int) int {
+	bits := 1
+	for 1<<uint(bits) < states {
+		bits++
+	}
+	return bits
+}
+
+// packWorld : packs a world into stateBits bits per cell holding the state of the cell, going by the grey level of
+// each state, row by row, most significant bit first. Cells in any other level are packed as the first state.
+func packWorld(world [][]byte, levels []byte) []byte {
+	var states [256]byte
+	for state, level := range levels {
+		states[level] = byte(state)
+	}
+	bits := stateBits(len(levels))
+	height := len(world)
+	width := len(world[0])
+	packed := make([]byte, (width*height*bits+7)/8)
+	i := 0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			state := states[world[y][x]]
+			for b := bits - 1; b >= 0; b-- {
+				if state>>uint(b)&1 != 0 {
+					packed[i/8] |= 0x80 >> uint(i%8)
+				}
+				i++
+			}
+		}
+	}
+	return packed
+}
+
+// encodeFrame : builds a binary frame message, compressing the packed board with raw DEFLATE
+func encodeFrame(kind byte, work Work, alive int, levels []byte, packed []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	header := make([]byte, frameHeaderSize)
+	header[0] = kind
+	binary.BigEndian.PutUint32(header[1:], uint32(work.Turn))
+	binary.BigEndian.PutUint32(header[5:], uint32(alive))
+	binary.BigEndian.PutUint32(header[9:], uint32(len(work.World[0])))
+	binary.BigEndian.PutUint32(header[13:], uint32(len(work.World)))
+	header[17] = byte(len(levels))
+	buf.Write(header)
+	buf.Write(levels)
+	writer, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = writer.Write(packed); err != nil {
+		return nil, err
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// handleViewerPage : GET / serves the embedded live viewer
+func handleViewerPage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(viewerPage))
+}
+
+// viewerSnapshot : gets the world of the session for the viewers, along with the number of changes it's up to. The world
+// is only collected from the workers once for each change, however many viewers there are, and shared between them.
+func (e *Engine) viewerSnapshot(s *session) (Work, int) {
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+	changes := s.getChanges()
+	if len(s.snapshot.World) == 0 || s.snapshotChanges != changes {
+		s.snapshot = getPGM(s, e.workChan, e.cmdChan)
+		s.snapshotChanges = changes
+	}
+	return s.snapshot, changes
+}
+
+// handleViewerSocket : GET /ws?controller= streams frames of the running session to the browser. Pausing and stopping
+// from the browser act as the controller with the given ID, so only take effect if it's an operator of the session.
+// A new frame is only sent once the browser has acknowledged the previous one, so the frame rate adapts to the client,
+// and only once the world has changed, so nothing is sent while the session is paused or once it has finished.
+func (e *Engine) handleViewerSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	controllerID := r.URL.Query().Get("controller")
+
+	acks := make(chan bool, 1)
+	replies := make(chan viewerMessage, 10)
+	closed := make(chan bool)
+
+	// Read acknowledgements and controls from the browser. Controls are mapped onto the existing RPC handlers.
+	go func() {
+		defer close(closed)
+		for {
+			var msg viewerMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			switch msg.Type {
+			case "ack":
+				select {
+				case acks <- true:
+				default:
+				}
+			case "pause", "stop":
+				replies <- e.viewerControl(msg.Type, controllerID)
+			}
+		}
+	}()
+
+	var current *session
+	var previous []byte
+	sent := 0 // changes of the world the last frame is up to
+	acked := true
+	lastFrame := time.Time{}
+	ticker := time.NewTicker(time.Second / maxFrameRate)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case reply := <-replies:
+			if conn.WriteJSON(reply) != nil {
+				return
+			}
+			continue
+		case <-acks:
+			acked = true
+			continue
+		case <-ticker.C:
+		}
+
+		s, err := e.currentSession()
+		if err != nil {
+			continue
+		}
+		if s != current {
+			// New run, so the browser needs a key frame
+			current = s
+			previous = nil
+			acked = true
+			if conn.WriteJSON(viewerMessage{Type: "status", Message: "Attached to a new run"}) != nil {
+				return
+			}
+		}
+		if !acked && time.Since(lastFrame) < ackTimeout {
+			continue
+		}
+		if !acked {
+			previous = nil // the client fell behind or lost a frame, start over from a key frame
+		}
+
+		if previous != nil && s.getChanges() == sent {
+			continue
+		}
+
+		work, changes := e.viewerSnapshot(s)
+		if len(work.World) == 0 || s.failure() != nil {
+			continue
+		}
+		levels := s.runRule().Levels()
+		packed := packWorld(work.World, levels)
+		kind := byte(keyFrame)
+		payload := packed
+		if previous != nil && len(previous) == len(packed) {
+			kind = diffFrame
+			payload = make([]byte, len(packed))
+			for i := range packed {
+				payload[i] = packed[i] ^ previous[i]
+			}
+		}
+		frame, err := encodeFrame(kind, work, numAliveCells(work.World), levels, payload)
+		if err != nil {
+			continue
+		}
+		if conn.WriteMessage(websocket.BinaryMessage, frame) != nil {
+			return
+		}
+		previous = packed
+		sent = changes
+		acked = false
+		lastFrame = time.Now()
+	}
+}
+
+// viewerControl : performs a pause, resume or stop requested from the browser as the controller with the given ID
+func (e *Engine) viewerControl(control string, id string) viewerMessage {
+	s, err := e.currentSession()
+	if err != nil {
+		return viewerMessage{Type: "error", Message: err.Error()}
+	}
+	switch control {
+	case "pause":
+		// The pause button toggles between pausing and resuming
+		if s.getState() == stubs.Paused {
+			res := new(stubs.ResponseResume)
+			err = e.Resume(stubs.RequestResume{ControllerID: id}, res)
+			if err == nil {
+				return viewerMessage{Type: "status", Message: res.Message}
+			}
+			break
+		}
+		res := new(stubs.ResponsePause)
+		err = e.Pause(stubs.RequestPause{ControllerID: id}, res)
+		if err == nil {
+			return viewerMessage{Type: "status", Message: res.Message}
+		}
+	case "stop":
+		res := new(stubs.ResponseStop)
+		err = e.Stop(stubs.RequestStop{ControllerID: id}, res)
+		if err == nil {
+			return viewerMessage{Type: "status", Message: res.Message}
+		}
+	}
+	return viewerMessage{Type: "error", Message: err.Error()}
+}
@@ -0,0 +1,125 @@
+package broker
+
+// viewerPage : live viewer served at /, or at /?controller= with the ID of an operator to pause and stop the run.
+// Frames are inflated with DecompressionStream, applied to the previous board and drawn onto a canvas in the grey level
+// of each state of the rule. Every frame is acknowledged so the engine only sends as many as the browser can draw.
+const viewerPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>GOL Viewer</title>
+<style>
+  body { background: #111; color: #ddd; font-family: monospace; margin: 1em; }
+  canvas { image-rendering: pixelated; border: 1px solid #444; width: 512px; }
+  button { margin-right: 0.5em; }
+  #log { height: 6em; overflow-y: auto; color: #999; }
+</style>
+</head>
+<body>
+<div>
+  <button id="pause">Pause</button>
+  <button id="snapshot">Snapshot</button>
+  <button id="stop">Stop</button>
+  <span id="stats">Waiting for a run...</span>
+</div>
+<p><canvas id="board" width="1" height="1"></canvas></p>
+<div id="log"></div>
+<script>
+const params = new URLSearchParams(location.search);
+const controller = params.get("controller") || "";
+const canvas = document.getElementById("board");
+const context = canvas.getContext("2d");
+const stats = document.getElementById("stats");
+const log = document.getElementById("log");
+let board = null;
+let image = null;
+let frames = 0;
+let fps = 0;
+let lastSecond = performance.now();
+
+if (controller === "") {
+  document.getElementById("pause").disabled = true;
+  document.getElementById("stop").disabled = true;
+}
+
+function logLine(text) {
+  const line = document.createElement("div");
+  line.textContent = text;
+  log.prepend(line);
+}
+
+async function inflate(data) {
+  const stream = new Blob([data]).stream().pipeThrough(new DecompressionStream("deflate-raw"));
+  return new Uint8Array(await new Response(stream).arrayBuffer());
+}
+
+const socket = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/ws?controller=" + encodeURIComponent(controller));
+socket.binaryType = "arraybuffer";
+
+socket.onmessage = async (event) => {
+  if (typeof event.data === "string") {
+    const msg = JSON.parse(event.data);
+    logLine(msg.type + ": " + msg.message);
+    return;
+  }
+  const view = new DataView(event.data);
+  const kind = view.getUint8(0);
+  const turn = view.getUint32(1);
+  const alive = view.getUint32(5);
+  const width = view.getUint32(9);
+  const height = view.getUint32(13);
+  const levels = new Uint8Array(event.data, 18, view.getUint8(17));
+  let stateBits = 1;
+  while ((1 << stateBits) < levels.length) {
+    stateBits++;
+  }
+  const bits = await inflate(new Uint8Array(event.data, 18 + levels.length));
+
+  if (kind === 0 || board === null || board.length !== bits.length) {
+    board = bits;
+    canvas.width = width;
+    canvas.height = height;
+    image = context.createImageData(width, height);
+  } else {
+    for (let i = 0; i < bits.length; i++) {
+      board[i] ^= bits[i];
+    }
+  }
+  for (let i = 0; i < width * height; i++) {
+    let state = 0;
+    for (let bit = i * stateBits; bit < (i + 1) * stateBits; bit++) {
+      state = (state << 1) | ((board[bit >> 3] >> (7 - (bit & 7))) & 1);
+    }
+    const value = state < levels.length ? levels[state] : 0;
+    image.data[4 * i] = value;
+    image.data[4 * i + 1] = value;
+    image.data[4 * i + 2] = value;
+    image.data[4 * i + 3] = 255;
+  }
+  context.putImageData(image, 0, 0);
+
+  frames++;
+  const now = performance.now();
+  if (now - lastSecond >= 1000) {
+    fps = frames * 1000 / (now - lastSecond);
+    frames = 0;
+    lastSecond = now;
+  }
+  stats.textContent = "Turn " + turn + " - Alive Cells " + alive + " - " + fps.toFixed(1) + " fps";
+  requestAnimationFrame(() => socket.send(JSON.stringify({type: "ack"})));
+};
+
+socket.onclose = () => logLine("Disconnected from engine");
+
+document.getElementById("pause").onclick = () => socket.send(JSON.stringify({type: "pause"}));
+document.getElementById("stop").onclick = () => socket.send(JSON.stringify({type: "stop"}));
+document.getElementById("snapshot").onclick = () => {
+  const link = document.createElement("a");
+  link.href = "/api/snapshot?format=png";
+  link.download = "snapshot.png";
+  link.click();
+};
+</script>
+</body>
+</html>
+`
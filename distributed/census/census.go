@@ -0,0 +1,409 @@
+// Package census splits a board into separate objects and names the ones that are in a catalogue of
+// common still lifes, oscillators and spaceships, in any phase, rotation and reflection.
+package census
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const alive = 255
+
+// Unknown is the name given to objects that aren't in the catalogue, including objects that are touching, or whose cells
+// touch those of another object.
+const Unknown = "unknown"
+
+// Census is the number of objects of each type on a board.
+type Census map[string]int
+
+// String lists the objects from the most to the least common.
+func (c Census) String() string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if c[names[i]] != c[names[j]] {
+			return c[names[i]] > c[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = fmt.Sprintf("%v %v", c[name], name)
+	}
+	return strings.Join(lines, ", ")
+}
+
+type point struct {
+	x, y int
+}
+
+// pattern is an object in the catalogue, drawn with 'O' for alive cells.
+type pattern struct {
+	name   string
+	period int
+	rows   []string
+}
+
+var patterns = []pattern{
+	{"block", 1, []string{"OO", "OO"}},
+	{"beehive", 1, []string{".OO.", "O..O", ".OO."}},
+	{"loaf", 1, []string{".OO.", "O..O", ".O.O", "..O."}},
+	{"boat", 1, []string{"OO.", "O.O", ".O."}},
+	{"ship", 1, []string{"OO.", "O.O", ".OO"}},
+	{"tub", 1, []string{".O.", "O.O", ".O."}},
+	{"pond", 1, []string{".OO.", "O..O", "O..O", ".OO."}},
+	{"long boat", 1, []string{"OO..", "O.O.", ".O.O", "..O."}},
+	{"barge", 1, []string{".O..", "O.O.", ".O.O", "..O."}},
+	{"snake", 1, []string{"OO.O", "O.OO"}},
+	{"mango", 1, []string{".OO..", "O..O.", ".O..O", "..OO."}},
+	{"aircraft carrier", 1, []string{"OO..", "O..O", "..OO"}},
+	{"blinker", 2, []string{"OOO"}},
+	{"toad", 2, []string{".OOO", "OOO."}},
+	{"beacon", 2, []string{"OO..", "OO..", "..OO", "..OO"}},
+	{"clock", 2, []string{"..O.", "O.O.", ".O.O", ".O.."}},
+	{"pulsar", 3, []string{
+		"..OOO...OOO..",
+		".............",
+		"O....O.O....O",
+		"O....O.O....O",
+		"O....O.O....O",
+		"..OOO...OOO..",
+		".............",
+		"..OOO...OOO..",
+		"O....O.O....O",
+		"O....O.O....O",
+		"O....O.O....O",
+		".............",
+		"..OOO...OOO..",
+	}},
+	{"pentadecathlon", 15, []string{"..O....O..", "OO.OOOO.OO", "..O....O.."}},
+	{"glider", 4, []string{".O.", "..O", "OOO"}},
+	{"lightweight spaceship", 4, []string{".O..O", "O....", "O...O", "OOOO."}},
+	{"middleweight spaceship", 4, []string{"...O..", ".O...O", "O.....", "O....O", "OOOOO."}},
+	{"heavyweight spaceship", 4, []string{"...OO..", ".O....O", "O......", "O.....O", "OOOOOO."}},
+}
+
+// catalogue maps the canonical form of every phase of every pattern onto its name.
+var catalogue = buildCatalogue()
+
+// arrangement is a phase of a spaceship or oscillator in the catalogue, in one of its rotations and reflections, whose
+// cells are split into parts that don't touch each other, along with the encoding of each part.
+type arrangement struct {
+	cells []point
+	parts [][]point
+	keys  []string
+}
+
+// arrangements maps the encoding of every part of every arrangement onto the arrangements it's a part of, so parts
+// found on a board can be put back together.
+var arrangements = buildArrangements()
+
+func buildCatalogue() map[string]string {
+	catalogue := map[string]string{}
+	for _, p := range patterns {
+		cells := p.cells()
+		for phase := 0; phase < p.period; phase++ {
+			catalogue[canonical(cells)] = p.name
+			cells = step(cells)
+		}
+	}
+	return catalogue
+}
+
+func buildArrangements() map[string][]arrangement {
+	arrangements := map[string][]arrangement{}
+	seen := map[string]bool{}
+	for _, p := range patterns {
+		if p.period == 1 {
+			continue
+		}
+		cells := p.cells()
+		for phase := 0; phase < p.period; phase++ {
+			for symmetry := 0; symmetry < 8; symmetry++ {
+				transformed := transform(cells, symmetry)
+				parts := islands(transformed)
+				if len(parts) == 1 || seen[encode(transformed)] {
+					continue
+				}
+				seen[encode(transformed)] = true
+				a := arrangement{cells: transformed, parts: parts, keys: make([]string, len(parts))}
+				for i, part := range parts {
+					a.keys[i] = encode(part)
+				}
+				added := map[string]bool{}
+				for _, key := range a.keys {
+					if !added[key] {
+						added[key] = true
+						arrangements[key] = append(arrangements[key], a)
+					}
+				}
+			}
+			cells = step(cells)
+		}
+	}
+	return arrangements
+}
+
+// cells lists the alive cells of the pattern as drawn.
+func (p pattern) cells() []point {
+	var cells []point
+	for y, row := range p.rows {
+		for x, cell := range row {
+			if cell == 'O' {
+				cells = append(cells, point{x, y})
+			}
+		}
+	}
+	return cells
+}
+
+// step computes the next generation of a set of cells on an unbounded plane.
+func step(cells []point) []point {
+	isAlive := map[point]bool{}
+	neighbours := map[point]int{}
+	for _, c := range cells {
+		isAlive[c] = true
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if dx != 0 || dy != 0 {
+					neighbours[point{c.x + dx, c.y + dy}]++
+				}
+			}
+		}
+	}
+	var next []point
+	for c, n := range neighbours {
+		if n == 3 || (n == 2 && isAlive[c]) {
+			next = append(next, c)
+		}
+	}
+	return next
+}
+
+// canonical encodes a set of cells the same way regardless of its position, rotation and reflection,
+// by picking the smallest encoding out of the 8 symmetries of the square.
+func canonical(cells []point) string {
+	best := ""
+	for symmetry := 0; symmetry < 8; symmetry++ {
+		if key := encode(transform(cells, symmetry)); best == "" || key < best {
+			best = key
+		}
+	}
+	return best
+}
+
+// transform applies one of the 8 symmetries of the square to a set of cells, numbered by whether they mirror x, mirror
+// y and swap x and y.
+func transform(cells []point, symmetry int) []point {
+	transformed := make([]point, len(cells))
+	for i, c := range cells {
+		x, y := c.x, c.y
+		if symmetry&1 != 0 {
+			x = -x
+		}
+		if symmetry&2 != 0 {
+			y = -y
+		}
+		if symmetry&4 != 0 {
+			x, y = y, x
+		}
+		transformed[i] = point{x, y}
+	}
+	return transformed
+}
+
+// encode translates the cells so the top left of their bounding box is at the origin and lists them in order.
+func encode(cells []point) string {
+	origin := topLeft(cells)
+	minX, minY := origin.x, origin.y
+	sorted := make([]point, len(cells))
+	for i, c := range cells {
+		sorted[i] = point{c.x - minX, c.y - minY}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].y != sorted[j].y {
+			return sorted[i].y < sorted[j].y
+		}
+		return sorted[i].x < sorted[j].x
+	})
+	var b strings.Builder
+	for _, c := range sorted {
+		fmt.Fprintf(&b, "%d,%d;", c.x, c.y)
+	}
+	return b.String()
+}
+
+// topLeft gets the top left corner of the bounding box of the cells.
+func topLeft(cells []point) point {
+	corner := cells[0]
+	for _, c := range cells {
+		if c.x < corner.x {
+			corner.x = c.x
+		}
+		if c.y < corner.y {
+			corner.y = c.y
+		}
+	}
+	return corner
+}
+
+// clusters splits the alive cells of a board into clusters of cells up to two apart, which holds every part of a phase
+// of a spaceship or an oscillator whose parts don't touch, along with any objects close to it.
+// The board wraps around at the edges, and the cells of a cluster crossing an edge are given coordinates
+// past the edge so the cluster stays in one piece.
+func clusters(world [][]byte) [][]point {
+	height := len(world)
+	if height == 0 {
+		return nil
+	}
+	width := len(world[0])
+	visited := make([][]bool, height)
+	for y := range visited {
+		visited[y] = make([]bool, width)
+	}
+
+	var clusters [][]point
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if world[y][x] != alive || visited[y][x] {
+				continue
+			}
+			visited[y][x] = true
+			component := []point{{x, y}}
+			for i := 0; i < len(component); i++ {
+				c := component[i]
+				for dy := -2; dy <= 2; dy++ {
+					for dx := -2; dx <= 2; dx++ {
+						nx, ny := c.x+dx, c.y+dy
+						wx, wy := (nx%width+width)%width, (ny%height+height)%height
+						if world[wy][wx] == alive && !visited[wy][wx] {
+							visited[wy][wx] = true
+							component = append(component, point{nx, ny})
+						}
+					}
+				}
+			}
+			clusters = append(clusters, component)
+		}
+	}
+	return clusters
+}
+
+// islands splits cells into groups of cells that touch, including diagonally, in the order of their first cells.
+func islands(cells []point) [][]point {
+	remaining := map[point]bool{}
+	for _, c := range cells {
+		remaining[c] = true
+	}
+	var islands [][]point
+	for _, c := range cells {
+		if !remaining[c] {
+			continue
+		}
+		delete(remaining, c)
+		island := []point{c}
+		for i := 0; i < len(island); i++ {
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					n := point{island[i].x + dx, island[i].y + dy}
+					if remaining[n] {
+						delete(remaining, n)
+						island = append(island, n)
+					}
+				}
+			}
+		}
+		islands = append(islands, island)
+	}
+	return islands
+}
+
+// components splits the alive cells of a board into separate objects. Cells that touch belong to the same object, and
+// parts that don't touch are only put back together if they make up a phase of a spaceship or oscillator in the
+// catalogue, so still lifes and ash next to each other are still told apart.
+func components(world [][]byte) [][]point {
+	var components [][]point
+	for _, cluster := range clusters(world) {
+		parts := islands(cluster)
+		owner := map[point]int{}
+		for i, part := range parts {
+			for _, c := range part {
+				owner[c] = i
+			}
+		}
+		joined := make([]bool, len(parts))
+		for i, part := range parts {
+			if joined[i] {
+				continue
+			}
+			joined[i] = true
+			component := part
+			if together, ok := rejoin(i, parts, owner, joined); ok {
+				component = nil
+				for _, j := range together {
+					joined[j] = true
+					component = append(component, parts[j]...)
+				}
+			}
+			components = append(components, component)
+		}
+	}
+	return components
+}
+
+// rejoin finds the parts that make up an arrangement together with the part at the given index, where every cell of
+// the arrangement is in one of them and they have no other cells, skipping parts that are already joined to another.
+func rejoin(index int, parts [][]point, owner map[point]int, joined []bool) ([]int, bool) {
+	part := parts[index]
+	key := encode(part)
+	corner := topLeft(part)
+	for _, a := range arrangements[key] {
+		for k, candidate := range a.parts {
+			if a.keys[k] != key {
+				continue
+			}
+			from := topLeft(candidate)
+			dx, dy := corner.x-from.x, corner.y-from.y
+			together := map[int]bool{}
+			fits := true
+			for _, c := range a.cells {
+				i, ok := owner[point{c.x + dx, c.y + dy}]
+				if !ok || (joined[i] && i != index) {
+					fits = false
+					break
+				}
+				together[i] = true
+			}
+			cells := 0
+			for i := range together {
+				cells += len(parts[i])
+			}
+			if !fits || cells != len(a.cells) {
+				continue
+			}
+			indices := make([]int, 0, len(together))
+			for i := range together {
+				indices = append(indices, i)
+			}
+			sort.Ints(indices)
+			return indices, true
+		}
+	}
+	return nil, false
+}
+
+// Take counts the objects of each type on a board. Objects that aren't in the catalogue are counted as Unknown.
+func Take(world [][]byte) Census {
+	census := Census{}
+	for _, component := range components(world) {
+		name, ok := catalogue[canonical(component)]
+		if !ok {
+			name = Unknown
+		}
+		census[name]++
+	}
+	return census
+}
@@ -0,0 +1,54 @@
+package compute
+
+import (
+	"math/rand"
+
+	"uk.ac.bris.cs/gameoflife/census"
+	"uk.ac.bris.cs/gameoflife/cycle"
+	"uk.ac.bris.cs/gameoflife/rule"
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+// boardScale : soups are placed in the middle of an empty board this many times their size, so the ash has room to
+// spread out before it wraps around the edges
+const boardScale = 4
+
+// makeSoup : generates a board holding a square soup of the given size in its middle, where each cell of the soup
+// is alive with the given density. The same seed always gives the same soup.
+func makeSoup(seed int64, size int, density float64) [][]byte {
+	random := rand.New(rand.NewSource(seed))
+	world := makeWorld(size*boardScale, size*boardScale)
+	offset := (size*boardScale - size) / 2
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if random.Float64() < density {
+				world[offset+y][offset+x] = ALIVE
+			}
+		}
+	}
+	return world
+}
+
+// RunSoup : generates a soup from the seed, runs it until it settles into a cycle or req.MaxTurns have been computed,
+// and takes a census of the ash. Soups don't use the worker world, so they can be run alongside a session.
+func (w *Worker) RunSoup(req stubs.RequestSoup, res *stubs.ResponseSoup) (err error) {
+	world := makeSoup(req.Seed, req.Size, req.Density)
+
+	// A glider takes 4 turns per cell to come back round the board, and the other objects in the ash have short
+	// periods, so this is long enough to catch the period of the whole board
+	detector := cycle.New(12 * len(world))
+	detector.Add(0, cycle.Hash(world))
+	turn := 0
+	for turn < req.MaxTurns {
+		world = calculateNextState(world, rule.Life, turn)
+		turn++
+		if _, found := detector.Add(turn, cycle.Hash(world)); found {
+			res.Settled = true
+			break
+		}
+	}
+	res.Seed = req.Seed
+	res.Turns = turn
+	res.Objects = census.Take(world)
+	return
+}
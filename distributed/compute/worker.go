@@ -0,0 +1,272 @@
+// Package compute holds the worker's state and RPC handlers, so workers can be run in their own process or inside the engine.
+package compute
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"os"
+
+	"uk.ac.bris.cs/gameoflife/cycle"
+	"uk.ac.bris.cs/gameoflife/heatmap"
+	"uk.ac.bris.cs/gameoflife/rule"
+	"uk.ac.bris.cs/gameoflife/stats"
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+/*
+	TODO: potentially change how the workers are structured, so there's a loop that will wait for inputs that are provided in a request and sent through a channel.
+	This will be similar to how subscriber_loop was used in the broker/factory lab. Will have to think more about this however to see if it's actually viable.
+	If we do it this way, maybe we'd have to make it so the workers also dial the engine, but I'm not sure about this. Then it could be made so the workers just work,
+	and whenever they're done with calculating one step they make a request to the engine to get the new halos from the other workers.
+*/
+
+const (
+	// ALIVE : pixel value for alive cells
+	ALIVE = 255
+
+	// DEAD : pixel value for dead cells
+	DEAD = 0
+)
+
+// Worker : holds the part of the world this worker is responsible for, including its halo rows
+type Worker struct {
+	world        [][]byte
+	workerID     int
+	numWorkers   int
+	statsRegions int
+	heat         *heatmap.Counters
+	rule         rule.Rule
+	top          int  // row of the world the rows this worker is responsible for start at
+	turn         int  // number of turns computed, which stochastic rules draw their random numbers for
+	history      bool // whether the engine keeps a history, which the cells changed on each turn are sent back for
+}
+
+func makeWorld(height, width int) [][]byte {
+	world := make([][]byte, height)
+	for i := range world {
+		world[i] = make([]byte, width)
+	}
+	return world
+}
+
+// Computes one evolution of the given rule on a strip of the world with halo rows above and below it, as many as the
+// rule needs. Only the rows in between are computed, the halo rows are kept until the next ones come in. The rows in
+// between start at row top of the world, which hex and triangular lattices need to know the shape of a neighbourhood,
+// and turn turns have been computed before this one.
+func calculateNextStrip(strip [][]byte, r rule.Rule, top, turn int) [][]byte {
+	halo := r.Halo()
+	newStrip := make([][]byte, 0, len(strip))
+	newStrip = append(newStrip, strip[:halo]...)
+	newStrip = append(newStrip, r.Step(strip, top, turn)...)
+	return append(newStrip, strip[len(strip)-halo:]...)
+}
+
+// Computes one evolution of the given rule on the whole world, which wraps round at the top and bottom edges as well,
+// after turn turns have been computed
+func calculateNextState(world [][]byte, r rule.Rule, turn int) [][]byte {
+	halo := r.Halo()
+	height := len(world)
+	strip := make([][]byte, height+2*halo)
+	for y := range strip {
+		strip[y] = world[((y-halo)%height+height)%height]
+	}
+	return r.Step(strip, 0, turn)
+}
+
+// numAliveCells : gets the number of alive cells from a given world
+func numAliveCells(world [][]byte) int {
+	aliveCells := 0
+	for y := range world {
+		for x := range world[y] {
+			if world[y][x] == ALIVE {
+				aliveCells++
+			}
+		}
+	}
+	return aliveCells
+}
+
+// ownRows : gets the rows this worker is responsible for, which is the whole world if it's the only worker
+func (w *Worker) ownRows() [][]byte {
+	if w.numWorkers == 1 {
+		return w.world
+	}
+	halo := w.rule.Halo()
+	return w.world[halo : len(w.world)-halo]
+}
+
+// haloRows : gets the rows the workers above and below need for their halos, which are the top and bottom rows this
+// worker is responsible for
+func (w *Worker) haloRows(res *stubs.ResponseRows) {
+	halo := w.rule.Halo()
+	own := w.ownRows()
+	res.TopRows = own[:halo]
+	res.BottomRows = own[len(own)-halo:]
+}
+
+// measureRows : measures each of the rows this worker is responsible for against the same rows before the turn,
+// if the engine asked for stats
+func (w *Worker) measureRows(before [][]byte) []stats.Row {
+	if w.statsRegions <= 0 {
+		return nil
+	}
+	after := w.ownRows()
+	rows := make([]stats.Row, len(after))
+	for y := range after {
+		rows[y] = stats.MeasureRow(before[y], after[y], w.statsRegions)
+	}
+	return rows
+}
+
+// countHeat : counts the turn just computed in the heat map counters of the rows this worker is responsible for,
+// if the engine asked for heat maps
+func (w *Worker) countHeat(before [][]byte) {
+	if w.heat != nil {
+		w.heat.Add(before, w.ownRows())
+	}
+}
+
+// changedCells : finds the cells this worker is responsible for that changed on the turn just computed, at their row
+// of the world, if the engine keeps a history, so it doesn't have to collect the whole world to record every turn
+func (w *Worker) changedCells(before [][]byte) []stubs.CellState {
+	if !w.history {
+		return nil
+	}
+	var changes []stubs.CellState
+	for y, row := range w.ownRows() {
+		for x, cell := range row {
+			if cell != before[y][x] {
+				changes = append(changes, stubs.CellState{X: x, Y: w.top + y, State: cell})
+			}
+		}
+	}
+	return changes
+}
+
+// StartWorker : starts the worker by receiving the worker world from the RPC request and sends back halo rows
+func (w *Worker) StartWorker(req stubs.RequestStartWorker, res *stubs.ResponseRows) (err error) {
+	w.world = nil
+	w.workerID = req.WorkerID
+	w.numWorkers = req.NumWorkers
+	w.statsRegions = req.StatsRegions
+	w.top = req.Top
+	w.turn = req.Turn
+	w.history = req.History
+	if w.rule, err = rule.New(req.Rule); err != nil {
+		return
+	}
+	topology, err := rule.ParseTopology(req.Topology)
+	if err != nil {
+		return
+	}
+	if w.rule, err = rule.OnTopology(w.rule, topology); err != nil {
+		return
+	}
+	if w.rule, err = rule.InVolume(w.rule, req.Height, req.Depth); err != nil {
+		return
+	}
+	w.rule = rule.Seeded(w.rule, req.Seed)
+	fmt.Print("\n Worker started\n\n")
+	w.world = req.WorkerWorld
+	before := w.ownRows()
+	w.heat = nil
+	if req.HeatMap {
+		heat := heatmap.New(len(before), len(before[0]))
+		w.heat = &heat
+	}
+	if w.numWorkers == 1 {
+		w.world = calculateNextState(w.world, w.rule, w.turn)
+	} else {
+		w.world = calculateNextStrip(w.world, w.rule, w.top, w.turn)
+	}
+	w.turn++
+	w.haloRows(res)
+	res.Hash = cycle.Hash(w.ownRows())
+	res.Stats = w.measureRows(before)
+	res.Changes = w.changedCells(before)
+	w.countHeat(before)
+	return
+}
+
+// Load : replaces the worker world without computing a step, e.g. after the engine has rewound, and sends back halo rows.
+// Heat map counters are kept as they are, as they count the activity the worker has computed rather than the history.
+func (w *Worker) Load(req stubs.RequestStartWorker, res *stubs.ResponseRows) (err error) {
+	w.workerID = req.WorkerID
+	w.numWorkers = req.NumWorkers
+	w.statsRegions = req.StatsRegions
+	w.top = req.Top
+	w.turn = req.Turn
+	w.history = req.History
+	w.world = req.WorkerWorld
+	w.haloRows(res)
+	res.Hash = cycle.Hash(w.ownRows())
+	return
+}
+
+// CalculateNextState : calculates the next state from given halo rows and sends back a hash of the rows this worker is
+// responsible for, so the engine can detect cycles without collecting the world every turn. The rows are measured
+// here as well if the engine asked for stats, as this is the only place both the old and new rows are at hand.
+func (w *Worker) CalculateNextState(req stubs.RequestNextState, res *stubs.ResponseRows) (err error) {
+	before := w.ownRows()
+	if req.TopRows == nil && req.BottomRows == nil {
+		w.world = calculateNextState(w.world, w.rule, w.turn)
+		fmt.Println("Next state calculated")
+	} else {
+		halo := w.rule.Halo()
+		copy(w.world[:halo], req.TopRows)
+		copy(w.world[len(w.world)-halo:], req.BottomRows)
+		w.world = calculateNextStrip(w.world, w.rule, w.top, w.turn)
+		w.haloRows(res)
+		fmt.Println("Next state calculated")
+	}
+	w.turn++
+	res.Hash = cycle.Hash(w.ownRows())
+	res.Stats = w.measureRows(before)
+	res.Changes = w.changedCells(before)
+	w.countHeat(before)
+	return
+}
+
+// GetResult : Gets the result of this worker and sends it back, excluding the extra top and bottom rows
+func (w *Worker) GetResult(req stubs.RequestWorkerResult, res *stubs.ResponseWorkerResult) (err error) {
+	if req.NumWorkers == 1 {
+		res.WorkerWorldPart = w.world
+		res.WorkerID = w.workerID
+	} else {
+		res.WorkerWorldPart = w.ownRows()
+		res.WorkerID = w.workerID
+	}
+	return
+}
+
+// GetPGM : gets the current worker world part
+func (w *Worker) GetPGM(req stubs.RequestPGM, res *stubs.ResponseWorkerResult) (err error) {
+	res.WorkerWorldPart = w.ownRows()
+	res.WorkerID = w.workerID
+	return
+}
+
+// GetHeatMap : gets the heat map counters of the rows this worker is responsible for, which are nil if the engine didn't
+// ask for heat maps
+func (w *Worker) GetHeatMap(req stubs.RequestHeatMap, res *stubs.ResponseWorkerHeatMap) (err error) {
+	if w.heat != nil {
+		res.Alive = w.heat.Alive
+		res.Flips = w.heat.Flips
+	}
+	res.WorkerID = w.workerID
+	return
+}
+
+// Stop : stops by exiting
+func (w *Worker) Stop(req stubs.RequestStopWorker, res *stubs.ResponseStopWorker) (err error) {
+	os.Exit(0)
+	return
+}
+
+// Serve : registers a new worker on its own RPC server and serves it on the listener until the listener is closed
+func Serve(listener net.Listener) {
+	server := rpc.NewServer()
+	server.Register(&Worker{})
+	server.Accept(listener)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/transport"
+)
+
+// TestControllers attaches observers and operators to a run, checks the engine only lets operators control or replace it
+// whatever role the other controllers ask for, and that every attached controller is sent the results once it's stopped.
+func TestControllers(t *testing.T) {
+	dial := func() transport.Client {
+		client, err := gol.DefaultTransport.Dial(gol.ServerAddress())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+	settleEngine(t)
+	client := dial()
+	defer client.Close()
+	world := make([][]byte, 16)
+	for y := range world {
+		world[y] = make([]byte, 16)
+	}
+	world[5][4], world[5][5], world[5][6] = 255, 255, 255
+	start := new(stubs.ResponseStart)
+	if err := client.Call(stubs.GameOfLifeHandler, stubs.RequestStart{World: world, Turns: 100000000, NumWorkers: 2}, start); err != nil {
+		t.Fatal(err)
+	}
+	operator := start.ControllerID
+
+	reconnect := func(role stubs.Role, controllerID string) (string, error) {
+		res := new(stubs.ResponseReconnect)
+		err := client.Call(stubs.ReconnectHandler, stubs.RequestReconnect{Role: role, ControllerID: controllerID}, res)
+		return res.ControllerID, err
+	}
+	observer, err := reconnect(stubs.Observer, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(operator) < 32 || observer == operator {
+		t.Errorf("The controllers were given the IDs %q and %q, expected different random IDs", operator, observer)
+	}
+	for _, id := range []string{"", "1", "2", observer} {
+		if _, err := reconnect(stubs.Operator, id); err == nil {
+			t.Errorf("A controller with the ID %q was attached as an operator", id)
+		}
+	}
+
+	// None of the controls work for an observer, and the run carries on
+	for _, call := range []struct {
+		method      string
+		args, reply interface{}
+	}{
+		{stubs.PauseHandler, stubs.RequestPause{ControllerID: observer}, new(stubs.ResponsePause)},
+		{stubs.ResumeHandler, stubs.RequestResume{ControllerID: observer}, new(stubs.ResponseResume)},
+		{stubs.RunForHandler, stubs.RequestRunFor{ControllerID: observer, Turns: 1}, new(stubs.ResponseStep)},
+		{stubs.RewindHandler, stubs.RequestRewind{ControllerID: observer, Turns: 1}, new(stubs.ResponseStep)},
+		{stubs.SetCellsHandler, stubs.RequestSetCells{ControllerID: observer}, new(stubs.ResponseSetCells)},
+		{stubs.StopHandler, stubs.RequestStop{ControllerID: observer}, new(stubs.ResponseStop)},
+		{stubs.StopWorkersHandler, stubs.RequestStopWorkers{ControllerID: observer}, new(stubs.ResponseStopWorkers)},
+	} {
+		if err := client.Call(call.method, call.args, call.reply); err == nil {
+			t.Errorf("%v succeeded for an observer", call.method)
+		}
+	}
+	for _, id := range []string{"", "1", observer} {
+		if err := client.Call(stubs.GameOfLifeHandler, stubs.RequestStart{ControllerID: id, World: world, Turns: 1, NumWorkers: 1}, new(stubs.ResponseStart)); err == nil {
+			t.Errorf("A controller with the ID %q replaced the run", id)
+		}
+	}
+	events := make(chan gol.Event, 1000)
+	gol.Run(gol.Params{Turns: 1, Threads: 1, ImageWidth: 16, ImageHeight: 16}, events, nil)
+	refused := false
+	for event := range events {
+		if e, ok := event.(gol.Error); ok && e.Fatal {
+			refused = true
+		}
+	}
+	if !refused {
+		t.Errorf("A controller that isn't an operator of the run replaced it")
+	}
+	status := new(stubs.ResponseStatus)
+	if err := client.Call(stubs.StatusHandler, stubs.RequestStatus{}, status); err != nil || !status.Running {
+		t.Fatalf("The run isn't running after an observer tried to stop it: %v", err)
+	}
+
+	// An operator hands its rights on to another controller, which stops the run while everyone waits for the results
+	handedOn, err := reconnect(stubs.Operator, operator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := make(chan *stubs.ResponseResult, 3)
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			waiting := dial()
+			defer waiting.Close()
+			res := new(stubs.ResponseResult)
+			if err := waiting.Call(stubs.ResultsHandler, stubs.RequestResult{}, res); err != nil {
+				t.Error(err)
+			}
+			results <- res
+		}()
+	}
+	if err := client.Call(stubs.StopHandler, stubs.RequestStop{ControllerID: handedOn}, new(stubs.ResponseStop)); err != nil {
+		t.Fatal(err)
+	}
+	first := <-results
+	if !first.Stopped || len(first.World) != 16 {
+		t.Fatalf("The results of the stopped run are %v rows after turn %v, stopped %v", len(first.World), first.Turn, first.Stopped)
+	}
+	for i := 1; i < cap(results); i++ {
+		res := <-results
+		if res.Turn != first.Turn || res.Stopped != first.Stopped || !bytes.Equal(bytes.Join(res.World, nil), bytes.Join(first.World, nil)) {
+			t.Errorf("A controller was sent the results after turn %v, another after turn %v", res.Turn, first.Turn)
+		}
+	}
+
+	// Of the runs started at once by an operator of the running run, only one replaces it, as the others are either
+	// turned away while it's starting or started by a controller that isn't attached to the run that replaced it
+	// The board is big enough that stopping the run and starting another takes a while, so the starts overlap
+	big := make([][]byte, 512)
+	for y := range big {
+		big[y] = make([]byte, 512)
+		for x := y % 2; x < len(big[y]); x += 3 {
+			big[y][x] = 255
+		}
+	}
+	if err := client.Call(stubs.GameOfLifeHandler, stubs.RequestStart{World: big, Turns: 100000000, NumWorkers: 2}, start); err != nil {
+		t.Fatal(err)
+	}
+	replaced := make(chan *stubs.ResponseStart, 8)
+	for i := 0; i < cap(replaced); i++ {
+		go func() {
+			starting := dial()
+			defer starting.Close()
+			res := new(stubs.ResponseStart)
+			if err := starting.Call(stubs.GameOfLifeHandler, stubs.RequestStart{ControllerID: start.ControllerID, World: big, Turns: 100000000, NumWorkers: 2}, res); err != nil {
+				res = nil
+			}
+			replaced <- res
+		}()
+	}
+	var replacing []*stubs.ResponseStart
+	for i := 0; i < cap(replaced); i++ {
+		if res := <-replaced; res != nil {
+			replacing = append(replacing, res)
+		}
+	}
+	if len(replacing) != 1 {
+		t.Fatalf("%v runs started at once by an operator replaced the run, expected 1", len(replacing))
+	}
+	if err := client.Call(stubs.StopHandler, stubs.RequestStop{ControllerID: replacing[0].ControllerID}, new(stubs.ResponseStop)); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(stubs.ResultsHandler, stubs.RequestResult{}, new(stubs.ResponseResult)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,71 @@
+// Package cycle detects when a board settles into a still life or an oscillator, by hashing the board
+// after every turn and looking for a hash that was already seen within a bounded window of recent turns.
+package cycle
+
+import "hash/fnv"
+
+// Cycle is a sequence of boards that repeats forever, with Start being the first turn of the first repetition.
+// Still lifes have a period of 1.
+type Cycle struct {
+	Start  int
+	Period int
+}
+
+type entry struct {
+	turn int
+	hash uint64
+}
+
+// Detector finds cycles with a period of at most its window.
+type Detector struct {
+	window  int
+	entries []entry
+	seen    map[uint64]int
+}
+
+// New creates a detector that remembers the hashes of the last window turns.
+func New(window int) *Detector {
+	if window < 1 {
+		window = 1
+	}
+	return &Detector{window: window, seen: map[uint64]int{}}
+}
+
+// Hash hashes a board with 64-bit FNV-1a.
+func Hash(world [][]byte) uint64 {
+	h := fnv.New64a()
+	for _, row := range world {
+		_, _ = h.Write(row)
+	}
+	return h.Sum64()
+}
+
+// Add records the hash of the board after the given turn. If the same board was seen within the window,
+// the cycle it belongs to is returned. Because every turn is checked as it's added, the first cycle found
+// starts on the earliest turn that is ever repeated.
+func (d *Detector) Add(turn int, hash uint64) (Cycle, bool) {
+	if previous, ok := d.seen[hash]; ok && previous < turn {
+		return Cycle{Start: previous, Period: turn - previous}, true
+	}
+	d.entries = append(d.entries, entry{turn: turn, hash: hash})
+	d.seen[hash] = turn
+	if len(d.entries) > d.window {
+		oldest := d.entries[0]
+		d.entries = d.entries[1:]
+		if d.seen[oldest.hash] == oldest.turn {
+			delete(d.seen, oldest.hash)
+		}
+	}
+	return Cycle{}, false
+}
+
+// Truncate forgets every turn after the given turn, e.g. after the run has been rewound.
+func (d *Detector) Truncate(turn int) {
+	for len(d.entries) > 0 && d.entries[len(d.entries)-1].turn > turn {
+		newest := d.entries[len(d.entries)-1]
+		d.entries = d.entries[:len(d.entries)-1]
+		if d.seen[newest.hash] == newest.turn {
+			delete(d.seen, newest.hash)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestCycle runs boards known to settle into a glider, a period 2 oscillator and a still life,
+// checking that the cycle is detected and that the engine stops once it has been with 1, 2 and 4 workers.
+func TestCycle(t *testing.T) {
+	tests := []struct {
+		size   int
+		start  int
+		period int
+	}{
+		{16, 0, 64},   // a single glider crossing the board
+		{64, 1575, 2}, // blinkers
+		{128, 5, 1},   // still life
+	}
+	for _, test := range tests {
+		for _, workers := range []int{1, 2, 4} {
+			p := gol.Params{
+				Turns:       100000,
+				Threads:     workers,
+				ImageWidth:  test.size,
+				ImageHeight: test.size,
+				StopOnCycle: true,
+			}
+			t.Run(fmt.Sprintf("%dx%d-%d", test.size, test.size, workers), func(t *testing.T) {
+				events := make(chan gol.Event)
+				gol.Run(p, events, nil)
+				var detected *gol.CycleDetected
+				for event := range events {
+					switch e := event.(type) {
+					case gol.CycleDetected:
+						if detected != nil {
+							t.Fatalf("Cycle detected twice, on turns %v and %v", detected.CompletedTurns, e.CompletedTurns)
+						}
+						detected = &e
+					case gol.FinalTurnComplete:
+						if detected == nil {
+							t.Fatalf("Run finished on turn %v without detecting a cycle", e.CompletedTurns)
+						}
+						if e.CompletedTurns != detected.CompletedTurns {
+							t.Fatalf("Cycle detected on turn %v but the run finished on turn %v", detected.CompletedTurns, e.CompletedTurns)
+						}
+					}
+				}
+				if detected == nil {
+					t.Fatal("No cycle detected")
+				}
+				if detected.Start != test.start || detected.Period != test.period {
+					t.Fatalf("Expected a cycle of period %v from turn %v, got period %v from turn %v", test.period, test.start, detected.Period, detected.Start)
+				}
+				if detected.CompletedTurns != test.start+test.period {
+					t.Fatalf("Expected the cycle to be detected on turn %v, got %v", test.start+test.period, detected.CompletedTurns)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/transport"
+)
+
+// faultyTransport dials the engine with the default transport, failing the dials and calls that fail says should fail
+// instead.
+type faultyTransport struct {
+	mu    sync.Mutex
+	dials int
+	calls map[string]int
+	fail  func(method string, call int) error
+}
+
+type faultyClient struct {
+	transport.Client
+	transport *faultyTransport
+}
+
+func (t *faultyTransport) Dial(address string) (transport.Client, error) {
+	t.mu.Lock()
+	t.dials++
+	dial := t.dials
+	t.mu.Unlock()
+	if err := t.fail("dial", dial); err != nil {
+		return nil, err
+	}
+	client, err := gol.DefaultTransport.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+	return faultyClient{client, t}, nil
+}
+
+func (c faultyClient) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	c.transport.mu.Lock()
+	c.transport.calls[serviceMethod]++
+	call := c.transport.calls[serviceMethod]
+	c.transport.mu.Unlock()
+	if err := c.transport.fail(serviceMethod, call); err != nil {
+		return err
+	}
+	return c.Client.Call(serviceMethod, args, reply)
+}
+
+// collectErrors drains the events, returning the Error events and whether the run finished on its last turn.
+func collectErrors(p gol.Params, events <-chan gol.Event) (errs []gol.Error, finished bool) {
+	for event := range events {
+		switch e := event.(type) {
+		case gol.Error:
+			errs = append(errs, e)
+		case gol.FinalTurnComplete:
+			finished = e.CompletedTurns == p.Turns
+		}
+	}
+	return errs, finished
+}
+
+// TestErrors injects failures into the connection to the engine and checks they're retried or reported with Error
+// events instead of panicking.
+func TestErrors(t *testing.T) {
+	p := gol.Params{Turns: 100, Threads: 2, ImageWidth: 64, ImageHeight: 64}
+
+	t.Run("unreachable", func(t *testing.T) {
+		transport := &faultyTransport{calls: map[string]int{}, fail: func(method string, call int) error {
+			if method == "dial" {
+				return errors.New("connection refused")
+			}
+			return nil
+		}}
+		p := p
+		p.Transport = transport
+		events := make(chan gol.Event, 1000)
+		gol.Run(p, events, nil)
+		errs, finished := collectErrors(p, events)
+		if finished {
+			t.Errorf("The run finished without an engine")
+		}
+		if len(errs) != 1 || !errs[0].Fatal {
+			t.Fatalf("Not reaching the engine gave the errors %v", errs)
+		}
+		if err, ok := errs[0].Err.(gol.EngineError); !ok || !err.Unreachable {
+			t.Errorf("Not reaching the engine gave %#v, expected an unreachable EngineError", errs[0].Err)
+		}
+		if transport.dials < 2 {
+			t.Errorf("The engine was dialled %v times, expected it to be dialled again", transport.dials)
+		}
+	})
+
+	t.Run("dropped", func(t *testing.T) {
+		transport := &faultyTransport{calls: map[string]int{}, fail: func(method string, call int) error {
+			if method == "Engine.GetResults" && call == 1 {
+				return rpc.ErrShutdown
+			}
+			return nil
+		}}
+		p := p
+		p.Transport = transport
+		events := make(chan gol.Event, 1000)
+		gol.Run(p, events, nil)
+		errs, finished := collectErrors(p, events)
+		if len(errs) != 0 {
+			t.Errorf("A dropped connection gave the errors %v, expected it to be dialled again", errs)
+		}
+		if !finished {
+			t.Errorf("The run didn't finish after the connection dropped")
+		}
+		if transport.dials != 2 {
+			t.Errorf("The engine was dialled %v times, expected 2", transport.dials)
+		}
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		transport := &faultyTransport{calls: map[string]int{}, fail: func(method string, call int) error {
+			if method == "Engine.GetPGM" {
+				return rpc.ServerError("no board")
+			}
+			return nil
+		}}
+		p := p
+		p.Turns = 5000
+		p.Transport = transport
+		events := make(chan gol.Event, 1000)
+		keyPresses := make(chan rune, 10)
+		gol.Run(p, events, keyPresses)
+		keyPresses <- 'p'
+		awaitEvent(t, events, func(e gol.Event) bool {
+			stateChange, ok := e.(gol.StateChange)
+			return ok && stateChange.NewState == gol.Paused
+		})
+		keyPresses <- 's'
+		e := awaitEvent(t, events, func(e gol.Event) bool {
+			_, ok := e.(gol.Error)
+			return ok
+		}).(gol.Error)
+		if err, ok := e.Err.(gol.EngineError); e.Fatal || !ok || err.Unreachable {
+			t.Errorf("The engine refusing a board gave %v, expected a non-fatal EngineError", e)
+		}
+		keyPresses <- 'p'
+		if errs, finished := collectErrors(p, events); len(errs) != 0 || !finished {
+			t.Errorf("The run didn't carry on after the engine refused a board, with the errors %v", errs)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "gol")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		p := p
+		p.Input = filepath.Join(dir, "missing.pgm")
+		events := make(chan gol.Event, 1000)
+		gol.Run(p, events, nil)
+		errs, finished := collectErrors(p, events)
+		if finished {
+			t.Errorf("The run finished without a board")
+		}
+		if len(errs) != 1 || !errs[0].Fatal {
+			t.Fatalf("Reading %v gave the errors %v", p.Input, errs)
+		}
+		if _, ok := errs[0].Err.(gol.IOError); !ok {
+			t.Errorf("Reading %v gave %T, expected an IOError", p.Input, errs[0].Err)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestFiles runs a board read from an image outside of images, with its size read from the header, and checks it's
+// written to the output directory under the name from the output template.
+func TestFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	data, err := ioutil.ReadFile("images/64x64.pgm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join(dir, "start.pgm")
+	if err := ioutil.WriteFile(input, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	width, height, err := gol.ImageSize(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if width != 64 || height != 64 {
+		t.Fatalf("Read the size of %v as %vx%v, expected 64x64", input, width, height)
+	}
+	commented := filepath.Join(dir, "commented.pgm")
+	if err := ioutil.WriteFile(commented, []byte("P5\n# made by hand\n3 2\n255\n\x00\x00\x00\x00\x00\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if width, height, err := gol.ImageSize(commented); err != nil || width != 3 || height != 2 {
+		t.Errorf("Read the size of %v as %vx%v (%v), expected 3x2", commented, width, height, err)
+	}
+
+	for _, template := range []string{"{bogus}-{turn}", "boards/{turn}"} {
+		if gol.CheckTemplate(template) == nil {
+			t.Errorf("The output template %q was taken", template)
+		}
+	}
+	template := "{rule}-{width}x{height}-t{turn}"
+	if err := gol.CheckTemplate(template); err != nil {
+		t.Fatal(err)
+	}
+
+	p := gol.Params{
+		Turns:          100,
+		Threads:        4,
+		ImageWidth:     width,
+		ImageHeight:    height,
+		Input:          input,
+		OutDir:         filepath.Join(dir, "boards"),
+		OutputTemplate: template,
+	}
+	events := make(chan gol.Event, 1000)
+	gol.Run(p, events, nil)
+	for event := range events {
+		if e, ok := event.(gol.ImageOutputComplete); ok && e.Filename != "B3_S23-64x64-t100" {
+			t.Errorf("Output the board as %v, expected B3_S23-64x64-t100", e.Filename)
+		}
+	}
+	output := readPgm(t, filepath.Join(p.OutDir, "B3_S23-64x64-t100.pgm"))
+	expected := readPgm(t, "check/images/64x64x100.pgm")
+	for y := range expected {
+		if !bytes.Equal(output[y], expected[y]) {
+			t.Fatalf("Row %v is %v, expected %v", y, output[y], expected[y])
+		}
+	}
+
+	// Boards read from images can be any shape, so the engine counts the alive cells of wide and tall boards as well
+	for _, size := range []struct{ width, height int }{{48, 16}, {16, 48}} {
+		name := fmt.Sprintf("%dx%d", size.width, size.height)
+		t.Run(name, func(t *testing.T) {
+			board := make([]byte, size.width*size.height)
+			for x := 2; x <= 4; x++ {
+				board[5*size.width+x] = 255
+			}
+			input := filepath.Join(dir, name+".pgm")
+			header := fmt.Sprintf("P5\n%d %d\n255\n", size.width, size.height)
+			if err := ioutil.WriteFile(input, append([]byte(header), board...), 0644); err != nil {
+				t.Fatal(err)
+			}
+			p := gol.Params{Turns: 1000000, Threads: 3, ImageWidth: size.width, ImageHeight: size.height, Input: input, OutDir: dir}
+			events := make(chan gol.Event, 1000)
+			keyPresses := make(chan rune, 10)
+			gol.Run(p, events, keyPresses)
+			keyPresses <- 'p'
+			awaitEvent(t, events, func(e gol.Event) bool {
+				stateChange, ok := e.(gol.StateChange)
+				return ok && stateChange.NewState == gol.Paused
+			})
+			keyPresses <- 'n'
+			count := awaitEvent(t, events, func(e gol.Event) bool {
+				_, ok := e.(gol.AliveCellsCount)
+				return ok
+			}).(gol.AliveCellsCount)
+			keyPresses <- 'q'
+			for range events {
+			}
+			if count.CellsCount != 3 {
+				t.Errorf("Counted %v alive cells of a blinker on a %v board, expected 3", count.CellsCount, name)
+			}
+		})
+	}
+}
@@ -2,4 +2,7 @@ module uk.ac.bris.cs/gameoflife
 
 go 1.12
 
-require github.com/veandco/go-sdl2 v0.4.4
+require (
+	github.com/gorilla/websocket v1.4.2
+	github.com/veandco/go-sdl2 v0.4.4
+)
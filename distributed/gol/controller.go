@@ -1,12 +1,21 @@
 package gol
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-	"net/rpc"
+	"math"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"uk.ac.bris.cs/gameoflife/census"
+	"uk.ac.bris.cs/gameoflife/heatmap"
+	"uk.ac.bris.cs/gameoflife/rule"
+	"uk.ac.bris.cs/gameoflife/session"
+	"uk.ac.bris.cs/gameoflife/stats"
 	"uk.ac.bris.cs/gameoflife/stubs"
 	"uk.ac.bris.cs/gameoflife/util"
 )
@@ -16,24 +25,39 @@ const (
 	DEAD  = 0
 )
 
+// errRunning is the error starting a run while the engine is running another, without the ID of one of its operators
+var errRunning = errors.New("the engine is already running a run, which can be attached to with -reconnect or -observe, or replaced with -controller and the ID of one of its operators")
+
+// lastStarted is the ID of the operator of the last run a controller in this program started, so a program that runs
+// one run after another, like the tests, can replace its own runs without being given an ID
+var lastStarted struct {
+	sync.Mutex
+	controllerID string
+}
+
 type Work struct {
-	World [][]byte
-	Turn  int
+	World   [][]byte
+	Turn    int
+	Stopped bool
+	Cycle   *stubs.Cycle
 }
 
 type AliveCells struct {
 	NumAliveCells  int
 	CompletedTurns int
+	Cycle          *stubs.Cycle
 }
 
 type controllerChannels struct {
 	events     chan<- Event
 	ioCommand  chan<- ioCommand
 	ioIdle     <-chan bool
+	ioError    <-chan error
 	ioFilename chan<- string
 	ioOutput   chan<- uint8
 	ioInput    <-chan uint8
 	keyPresses <-chan rune
+	edits      <-chan SetCells
 }
 
 func makeWorld(height, width int) [][]byte {
@@ -60,200 +84,697 @@ func calculateAliveCells(world [][]byte) []util.Cell {
 
 /* Functions to send RPC requests to the engine */
 
-func startGameOfLife(client rpc.Client, world [][]byte, turns, numWorkers int) string {
-	request := stubs.RequestStart{World: world, Turns: turns, NumWorkers: numWorkers}
+func startGameOfLife(client *engineClient, controllerID string, world [][]byte, turn, turns, numWorkers, historyBudget int, stopOnCycle bool, statsRegions int, heatMap bool, ruleSpec rule.Spec, topology string, depth int, seed int64) (string, string, error) {
+	request := stubs.RequestStart{ControllerID: controllerID, World: world, Turn: turn, Turns: turns, NumWorkers: numWorkers, HistoryBudget: historyBudget, StopOnCycle: stopOnCycle, StatsRegions: statsRegions, HeatMap: heatMap, Rule: ruleSpec, Topology: topology, Depth: depth, Seed: seed}
 	response := new(stubs.ResponseStart)
-	client.Call(stubs.GameOfLifeHandler, request, response)
-	return response.Message
+	err := client.call(stubs.GameOfLifeHandler, request, response)
+	return response.Message, response.ControllerID, err
 }
 
-func requestResults(client rpc.Client, resultsChan chan Work) {
+func requestResults(client *engineClient) (Work, error) {
 	request := stubs.RequestResult{}
 	response := new(stubs.ResponseResult)
-	client.Call(stubs.ResultsHandler, request, response)
-	resultsChan <- Work{World: response.World, Turn: response.Turn}
+	err := client.query(stubs.ResultsHandler, request, response)
+	return Work{World: response.World, Turn: response.Turn, Stopped: response.Stopped, Cycle: response.Cycle}, err
 }
 
-func requestAliveCells(client rpc.Client) AliveCells {
+func requestAliveCells(client *engineClient) (AliveCells, error) {
 	request := stubs.RequestAliveCells{}
 	response := new(stubs.ResponseAliveCells)
-	client.Call(stubs.AliveCellsHandler, request, response)
-	return AliveCells{NumAliveCells: response.NumAliveCells, CompletedTurns: response.CompletedTurns}
+	err := client.query(stubs.AliveCellsHandler, request, response)
+	return AliveCells{NumAliveCells: response.NumAliveCells, CompletedTurns: response.CompletedTurns, Cycle: response.Cycle}, err
 }
 
-func requestPGM(client rpc.Client) Work {
+func requestPGM(client *engineClient) (Work, error) {
 	request := stubs.RequestPGM{}
 	response := new(stubs.ResponsePGM)
-	client.Call(stubs.PGMHandler, request, response)
-	return Work{World: response.World, Turn: response.Turn}
+	err := client.query(stubs.PGMHandler, request, response)
+	return Work{World: response.World, Turn: response.Turn}, err
+}
+
+func requestCensus(client *engineClient) (census.Census, int, error) {
+	request := stubs.RequestCensus{}
+	response := new(stubs.ResponseCensus)
+	err := client.query(stubs.CensusHandler, request, response)
+	return response.Objects, response.CompletedTurns, err
+}
+
+// requestStats gets the metrics of every turn from the given turn onwards
+func requestStats(client *engineClient, from int) ([]stats.Turn, error) {
+	request := stubs.RequestStats{From: from}
+	response := new(stubs.ResponseStats)
+	err := client.query(stubs.StatsHandler, request, response)
+	return response.Turns, err
+}
+
+// requestHeatMap gets how often each cell has been alive and flipped so far
+func requestHeatMap(client *engineClient) (heatmap.Counters, int, error) {
+	request := stubs.RequestHeatMap{}
+	response := new(stubs.ResponseHeatMap)
+	err := client.query(stubs.HeatMapHandler, request, response)
+	return heatmap.Counters{Alive: response.Alive, Flips: response.Flips}, response.Turn, err
 }
 
-func requestPause(client rpc.Client) string {
-	request := stubs.RequestPause{}
+func requestPause(client *engineClient, controllerID string) (string, error) {
+	request := stubs.RequestPause{ControllerID: controllerID}
 	response := new(stubs.ResponsePause)
-	client.Call(stubs.PauseHandler, request, response)
-	return response.Message
+	err := client.call(stubs.PauseHandler, request, response)
+	return response.Message, err
+}
+
+func requestResume(client *engineClient, controllerID string) (string, error) {
+	request := stubs.RequestResume{ControllerID: controllerID}
+	response := new(stubs.ResponseResume)
+	err := client.call(stubs.ResumeHandler, request, response)
+	return response.Message, err
+}
+
+// requestRunFor computes the given number of turns while the engine is paused
+func requestRunFor(client *engineClient, controllerID string, turns int) (AliveCells, error) {
+	request := stubs.RequestRunFor{ControllerID: controllerID, Turns: turns}
+	response := new(stubs.ResponseStep)
+	err := client.call(stubs.RunForHandler, request, response)
+	return AliveCells{NumAliveCells: response.NumAliveCells, CompletedTurns: response.CompletedTurns}, err
+}
+
+// requestRewind goes back the given number of turns while the engine is paused, stopping at the oldest retained turn
+func requestRewind(client *engineClient, controllerID string, turns int) (AliveCells, error) {
+	request := stubs.RequestRewind{ControllerID: controllerID, Turns: turns}
+	response := new(stubs.ResponseStep)
+	err := client.call(stubs.RewindHandler, request, response)
+	return AliveCells{NumAliveCells: response.NumAliveCells, CompletedTurns: response.CompletedTurns}, err
+}
+
+// requestSetCells sets cells of the world while the engine is paused, returning the cells that changed
+func requestSetCells(client *engineClient, controllerID string, edit SetCells) ([]stubs.CellState, AliveCells, error) {
+	request := stubs.RequestSetCells{ControllerID: controllerID}
+	for cell, level := range edit {
+		request.Cells = append(request.Cells, stubs.CellState{X: cell.X, Y: cell.Y, State: level})
+	}
+	response := new(stubs.ResponseSetCells)
+	err := client.call(stubs.SetCellsHandler, request, response)
+	return response.Cells, AliveCells{NumAliveCells: response.NumAliveCells, CompletedTurns: response.CompletedTurns}, err
 }
 
-func requestStop(client rpc.Client) string {
-	request := stubs.RequestStop{}
+// requestStateChange blocks until the state of the engine differs from the known state
+func requestStateChange(client *engineClient, known stubs.State) (stubs.State, int) {
+	request := stubs.RequestStateChange{Known: known}
+	response := new(stubs.ResponseStateChange)
+	err := client.query(stubs.StateChangeHandler, request, response)
+	if err != nil {
+		return stubs.Quitting, 0
+	}
+	return response.State, response.CompletedTurns
+}
+
+func requestStop(client *engineClient, controllerID string) (string, error) {
+	request := stubs.RequestStop{ControllerID: controllerID}
 	response := new(stubs.ResponseStop)
-	client.Call(stubs.StopHandler, request, response)
-	return response.Message
+	err := client.call(stubs.StopHandler, request, response)
+	return response.Message, err
 }
 
-func requestStatus(client rpc.Client) bool {
+func requestStatus(client *engineClient) (bool, error) {
 	request := stubs.RequestStatus{}
 	response := new(stubs.ResponseStatus)
-	client.Call(stubs.StatusHandler, request, response)
-	return response.Running
+	err := client.query(stubs.StatusHandler, request, response)
+	return response.Running, err
 }
 
-func requestReconnect(client rpc.Client) string {
-	request := stubs.RequestReconnect{}
+func requestReconnect(client *engineClient, role stubs.Role, controllerID string) (string, string, error) {
+	request := stubs.RequestReconnect{Role: role, ControllerID: controllerID}
 	response := new(stubs.ResponseReconnect)
-	client.Call(stubs.ReconnectHandler, request, response)
-	return response.Message
+	err := client.call(stubs.ReconnectHandler, request, response)
+	return response.Message, response.ControllerID, err
 }
 
-func requestStopWorkers(client rpc.Client) bool {
-	request := stubs.RequestStopWorkers{}
+func requestStopWorkers(client *engineClient, controllerID string) (bool, error) {
+	request := stubs.RequestStopWorkers{ControllerID: controllerID}
 	response := new(stubs.ResponseStopWorkers)
-	client.Call(stubs.StopWorkersHandler, request, response)
-	return response.OK
+	err := client.call(stubs.StopWorkersHandler, request, response)
+	return response.OK, err
+}
+
+// ServerAddress returns the address of the engine given with the server flag, or the AWS instance it runs on by default
+func ServerAddress() string {
+	if flag.Lookup("server") != nil {
+		return flag.Lookup("server").Value.String()
+	}
+	return "3.236.236.233:8030"
+}
+
+// toState converts the state of the engine into the state reported to the user
+func toState(state stubs.State) State {
+	switch state {
+	case stubs.Paused:
+		return Paused
+	case stubs.Stopping:
+		return Stopping
+	case stubs.Quitting:
+		return Quitting
+	default:
+		return Executing
+	}
 }
 
 func controller(p Params, c controllerChannels) {
 
-	// Dial server
-	var serverIP string
-	if flag.Lookup("server") != nil {
-		serverIP = flag.Lookup("server").Value.String()
-	} else {
-		serverIP = "3.236.236.233:8030"
+	// Dial server, giving up on the run if it can't be reached
+	client, err := connect(p.Transport)
+	if err != nil {
+		fail(c, 0, err)
+		return
+	}
+	engineRunning, err := requestStatus(client)
+	if err != nil {
+		client.Close()
+		fail(c, 0, err)
+		return
 	}
-	client, _ := rpc.Dial("tcp", serverIP)
 
-	engineRunning := requestStatus(*client)
-	if p.Reconnect != true {
+	// Observers attach to an already running engine and are not allowed to pause or stop it
+	role := stubs.Operator
+	if p.Observer {
+		role = stubs.Observer
+	}
 
-		// Check if engine is already running and processing GoL, if it is stop it and load in the initial board state and start processing from the beginning
-		if engineRunning == true {
-			fmt.Println(requestStop(*client))
+	var controllerID string
+	started := false // only the controller that started the run knows its params, so only it can save its session
+	if p.Reconnect != true && p.Observer != true {
+
+		// If the engine is already processing GoL, the new run replaces it only if the controller is one of its operators
+		operatorID := p.ControllerID
+		if engineRunning {
+			if operatorID == "" {
+				lastStarted.Lock()
+				operatorID = lastStarted.controllerID
+				lastStarted.Unlock()
+			}
+			if operatorID == "" {
+				client.Close()
+				fail(c, 0, errRunning)
+				return
+			}
+			fmt.Println("Replacing the run that's running")
 		}
 
-		// Request IO to read image file
-		c.ioCommand <- ioInput
-		c.ioFilename <- fmt.Sprintf("%dx%d", p.ImageHeight, p.ImageWidth)
+		// Carry on from a saved session if there is one, otherwise request IO to read image file. The run can't carry on
+		// without a board, so it ends with an Error event if there isn't one.
+		var saved session.Session
+		if p.Session != "" {
+			saved, err = loadSession(p.Session)
+			if err == nil && (saved.Width != p.ImageWidth || saved.Height != worldHeight(p)) {
+				err = FormatError{Path: p.Session, Problem: fmt.Sprintf("is %vx%v, expected %vx%v", saved.Width, saved.Height, p.ImageWidth, worldHeight(p))}
+			}
+		} else {
+			c.ioCommand <- ioInput
+			c.ioFilename <- imageName(p)
+			err = <-c.ioError
+		}
+		if err != nil {
+			client.Close()
+			fail(c, saved.Turn, err)
+			return
+		}
 
-		// Load world in
-		world := makeWorld(p.ImageHeight, p.ImageWidth)
+		// Load world in, snapping the grey levels of the image onto the states of the rule. A volume is run as a flat
+		// world of its slices stacked up if it falls back to Life.
+		depth := p.ImageDepth
+		r, err := rule.Parse(p.Rule)
+		if err == nil {
+			_, err = rule.InVolume(r, p.ImageHeight, depth)
+		}
+		if err != nil {
+			fmt.Println(err, "- running Life instead")
+			r, p.Rule, depth = rule.Rule(rule.Life), "", 0
+		}
+		topology, err := rule.ParseTopology(p.Topology)
+		if err == nil {
+			err = topology.Check(p.ImageWidth, p.ImageHeight)
+		}
+		if err == nil {
+			_, err = rule.OnTopology(r, topology)
+		}
+		if err != nil {
+			fmt.Println(err, "- running on square cells instead")
+			p.Topology = ""
+		}
+		world := makeWorld(worldHeight(p), p.ImageWidth)
 		for y := range world {
-			for x := range world {
-				world[y][x] = <-c.ioInput
+			for x := range world[y] {
+				if saved.World != nil {
+					world[y][x] = r.Quantise(saved.World[y][x])
+				} else {
+					world[y][x] = r.Quantise(<-c.ioInput)
+				}
 			}
 		}
 
 		// Make call to server to start Game of Life
-		startGameOfLife(*client, world, p.Turns, p.Threads)
+		regions := 0
+		if p.StatsFile != "" {
+			regions = statsRegions(p)
+		}
+		p.Seed = seed(p, r)
+		_, controllerID, err = startGameOfLife(client, operatorID, world, saved.Turn, p.Turns, p.Threads, p.HistoryBudget, p.StopOnCycle, regions, p.HeatMap, r.Spec(), p.Topology, depth, p.Seed)
+		if err != nil {
+			client.Close()
+			fail(c, saved.Turn, err)
+			return
+		}
+		fmt.Println("Controller ID:", controllerID, "- other controllers can operate the run with -reconnect -controller", controllerID)
+		lastStarted.Lock()
+		lastStarted.controllerID = controllerID
+		lastStarted.Unlock()
+		started = true
 
 	} else {
 		if engineRunning == false {
 			fmt.Println("Engine is not currently processing Game of Life, cannot reconnect. Exiting...")
 			os.Exit(0)
 		} else {
-			fmt.Println(requestReconnect(*client))
+			var message string
+			message, controllerID, err = requestReconnect(client, role, p.ControllerID)
+			if err != nil {
+				client.Close()
+				fail(c, 0, err)
+				return
+			}
+			fmt.Println(message)
 		}
 	}
 
-	resultsChan := make(chan Work)
+	// The engine broadcasts the results to every attached controller once it's done, so they can be requested straight away
+	type result struct {
+		Work
+		err error
+	}
+	resultsChan := make(chan result, 1)
+	go func() {
+		work, err := requestResults(client)
+		resultsChan <- result{work, err}
+	}()
 	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	// Wait for changes of state on the engine, including ones made by other controllers, and pass them on to the
+	// anonymous goroutine below so it can report them as StateChange events
+	stateChan := make(chan StateChange)
+	finished := make(chan bool)
+	go func() {
+		known := stubs.Executing
+		for {
+			state, turn := requestStateChange(client, known)
+			if state == stubs.Quitting {
+				return
+			}
+			known = state
+			select {
+			case stateChan <- StateChange{CompletedTurns: turn, NewState: toState(state)}:
+			case <-finished:
+				return
+			}
+		}
+	}()
 
-	// If there are few turns to calculate or the image is small we don't need the ticker because computation will finish before the ticker gets the chance to
-	// request the alive cells from the engine
-	if (p.Turns < 100) || (p.ImageHeight < 512) {
-		ticker.Stop()
-		go requestResults(*client, resultsChan)
+	// The engine reports the cycle the board has settled into with the alive cells and the results, whichever comes first
+	var cycleOnce sync.Once
+	reportCycle := func(cycle *stubs.Cycle) {
+		if cycle == nil {
+			return
+		}
+		cycleOnce.Do(func() {
+			c.events <- CycleDetected{CompletedTurns: cycle.Turn, Period: cycle.Period, Start: cycle.Start}
+		})
 	}
 
-	// Anonymous goroutine to allow for ticker to be run in the background along with registering keypresses
-	quitChannel := make(chan bool)
+	// The engine keeps the metrics of every turn, so they're fetched with the alive cells and written out as they come in
+	var statsWriter *stats.Writer
+	statsFrom := 1
+	if p.StatsFile != "" {
+		statsFile, err := os.Create(p.StatsFile)
+		if err != nil {
+			c.events <- Error{Err: IOError{Op: "write", Path: p.StatsFile, Err: err}}
+		} else {
+			defer statsFile.Close()
+			statsWriter = stats.NewWriter(statsFile, strings.HasSuffix(p.StatsFile, ".jsonl"), statsRegions(p))
+		}
+	}
+	writeStats := func() {
+		if statsWriter == nil {
+			return
+		}
+		turns, err := requestStats(client, statsFrom)
+		if err != nil {
+			c.events <- Error{CompletedTurns: statsFrom - 1, Err: err}
+			statsWriter = nil
+			return
+		}
+		for _, t := range turns {
+			statsWriter.Write(t)
+			statsFrom = t.CompletedTurns + 1
+		}
+	}
+
+	// Anonymous goroutine to allow for ticker to be run in the background along with registering keypresses.
+	// It blocks in the select rather than spinning, so it doesn't use any CPU while waiting.
+	// Quitting with 'q' leaves the engine running and closes the events channel, so nothing is sent after it.
+	// quitChannel is buffered as the goroutine has already returned if 'q' was pressed as the results arrived.
+	quitChannel := make(chan bool, 1)
+	quitKey := make(chan bool)
 	go func(paused bool, quitChan chan bool) {
 		for {
 			select {
+			case stateChange := <-stateChan:
+				paused = stateChange.NewState == Paused
+				c.events <- stateChange
 			case <-ticker.C:
-				aliveCells := requestAliveCells(*client)
-				// If the number of completed turns by the engine is close to the total number of turns to be completed,
-				// stop the ticker so it doesn't make another RPC call, and make a RPC call to request the results from the engine.
-				if p.Turns-aliveCells.CompletedTurns <= 60 {
-					ticker.Stop()
-					go requestResults(*client, resultsChan)
-				} else {
-					c.events <- AliveCellsCount{CompletedTurns: aliveCells.CompletedTurns, CellsCount: aliveCells.NumAliveCells}
+				aliveCells, err := requestAliveCells(client)
+				if err != nil {
+					c.events <- Error{Err: err}
+					break
 				}
+				c.events <- AliveCellsCount{CompletedTurns: aliveCells.CompletedTurns, CellsCount: aliveCells.NumAliveCells}
+				reportCycle(aliveCells.Cycle)
+				writeStats()
 			case keyPress := <-c.keyPresses:
 				switch keyPress {
 				case 's':
-					boardState := requestPGM(*client)
+					boardState, err := requestPGM(client)
+					if err != nil {
+						c.events <- Error{Err: err}
+						break
+					}
 					printBoard(c, p, boardState.World, boardState.Turn)
+				case 'w':
+					boardState, err := requestPGM(client)
+					if err != nil {
+						c.events <- Error{Err: err}
+						break
+					}
+					saveEdited(c, p, boardState.World, boardState.Turn)
+				case 'e':
+					if !started {
+						fmt.Println("Only the controller that started the run can save its session")
+						break
+					}
+					boardState, err := requestPGM(client)
+					if err != nil {
+						c.events <- Error{Err: err}
+						break
+					}
+					saveSession(c, p, boardState.World, boardState.Turn)
+				case 'c':
+					objects, turn, err := requestCensus(client)
+					if err != nil {
+						c.events <- Error{Err: err}
+						break
+					}
+					c.events <- CensusComplete{CompletedTurns: turn, Objects: objects}
+				case 'h':
+					heat, turn, err := requestHeatMap(client)
+					if err != nil {
+						c.events <- Error{Err: err}
+						break
+					}
+					exportHeatMaps(c, p, heat, turn)
 				case 'q':
-					close(c.events)
+					if statsWriter != nil {
+						statsWriter.Flush()
+					}
+					close(quitKey)
+					return
 				case 'p':
-					if paused == false {
-						fmt.Println("\n" + requestPause(*client))
-						paused = true
+					if role == stubs.Observer {
+						fmt.Println("Observers cannot pause the engine")
+						break
 					}
-					for paused {
-						select {
-						case tempKey := <-c.keyPresses:
-							if tempKey == 'p' {
-								fmt.Println(requestPause(*client) + "\n")
-								paused = false
-							}
-						default:
+					// The engine keeps answering alive cells and snapshot requests while paused, and reports the change of
+					// state back through stateChan
+					if paused == false {
+						message, err := requestPause(client, controllerID)
+						if err != nil {
+							c.events <- Error{Err: err}
+							break
 						}
+						fmt.Println("\n" + message)
+					} else {
+						message, err := requestResume(client, controllerID)
+						if err != nil {
+							c.events <- Error{Err: err}
+							break
+						}
+						fmt.Println(message + "\n")
+					}
+				case 'n', 'm':
+					// Step one turn with 'n', or p.StepTurns turns with 'm', while paused
+					if role == stubs.Observer {
+						fmt.Println("Observers cannot step through turns")
+						break
+					}
+					turns := 1
+					if keyPress == 'm' {
+						turns = stepTurns(p)
+					}
+					aliveCells, err := requestRunFor(client, controllerID, turns)
+					if err != nil {
+						c.events <- Error{Err: err}
+						break
+					}
+					c.events <- TurnComplete{CompletedTurns: aliveCells.CompletedTurns}
+					c.events <- AliveCellsCount{CompletedTurns: aliveCells.CompletedTurns, CellsCount: aliveCells.NumAliveCells}
+				case 'b', 'v', 'g':
+					// Rewind one turn with 'b', p.StepTurns turns with 'v', or as far back as the history goes with 'g',
+					// while paused. Saving with 's' afterwards exports the turn that was rewound to.
+					if role == stubs.Observer {
+						fmt.Println("Observers cannot rewind")
+						break
+					}
+					turns := 1
+					if keyPress == 'v' {
+						turns = stepTurns(p)
+					} else if keyPress == 'g' {
+						turns = math.MaxInt32
+					}
+					aliveCells, err := requestRewind(client, controllerID, turns)
+					if err != nil {
+						c.events <- Error{Err: err}
+						break
 					}
+					fmt.Println("Rewound to turn", aliveCells.CompletedTurns)
+					c.events <- TurnComplete{CompletedTurns: aliveCells.CompletedTurns}
+					c.events <- AliveCellsCount{CompletedTurns: aliveCells.CompletedTurns, CellsCount: aliveCells.NumAliveCells}
 				case 'k':
-					ok := requestStopWorkers(*client)
+					if role == stubs.Observer {
+						fmt.Println("Observers cannot stop the workers")
+						break
+					}
+					ok, err := requestStopWorkers(client, controllerID)
+					if err != nil {
+						c.events <- Error{Err: err}
+						break
+					}
 					if ok {
 						os.Exit(0)
 					}
 				}
+			case edit := <-c.edits:
+				if role == stubs.Observer {
+					fmt.Println("Observers cannot edit the board")
+					break
+				}
+				changed, aliveCells, err := requestSetCells(client, controllerID, edit)
+				if err != nil {
+					c.events <- Error{Err: err}
+					break
+				}
+				for _, cell := range changed {
+					c.events <- CellFlipped{CompletedTurns: aliveCells.CompletedTurns, Cell: util.Cell{X: cell.X, Y: cell.Y}, State: cell.State}
+				}
+				c.events <- TurnComplete{CompletedTurns: aliveCells.CompletedTurns}
+				c.events <- AliveCellsCount{CompletedTurns: aliveCells.CompletedTurns, CellsCount: aliveCells.NumAliveCells}
 			case <-quitChan:
 				return
-			default:
 			}
 		}
 	}(false, quitChannel)
 
-	// Request results
+	// Request results. The engine is gone if they can't be got even after dialling it again, so there's no final board.
 	var resultWork Work
 	select {
+	case <-quitKey:
+		close(finished)
+		client.Close()
+		close(c.events)
 	case result := <-resultsChan:
-		resultWork = result
-		printBoard(c, p, resultWork.World, resultWork.Turn)
-		// Calculate alive cells
-		c.events <- FinalTurnComplete{CompletedTurns: resultWork.Turn, Alive: calculateAliveCells(resultWork.World)}
+		resultWork = result.Work
+		if result.err != nil {
+			c.events <- Error{CompletedTurns: resultWork.Turn, Err: result.err, Fatal: true}
+		} else {
+			if resultWork.Stopped {
+				fmt.Println("Engine was stopped before all turns were computed")
+			}
+			if resultWork.Cycle != nil {
+				reportCycle(resultWork.Cycle)
+				fmt.Printf("Settled into a cycle of period %v from turn %v\n", resultWork.Cycle.Period, resultWork.Cycle.Start)
+				if p.StopOnCycle && resultWork.Turn < p.Turns {
+					fmt.Println("Stopped early on turn", resultWork.Turn)
+				}
+			}
+			printBoard(c, p, resultWork.World, resultWork.Turn)
+			if p.HeatMap {
+				if heat, turn, err := requestHeatMap(client); err != nil {
+					c.events <- Error{CompletedTurns: resultWork.Turn, Err: err}
+				} else {
+					exportHeatMaps(c, p, heat, turn)
+				}
+			}
+			// Calculate alive cells
+			c.events <- FinalTurnComplete{CompletedTurns: resultWork.Turn, Alive: calculateAliveCells(resultWork.World)}
 
-		// Make sure that the Io has finished any output before exiting.
-		c.ioCommand <- ioCheckIdle
-		<-c.ioIdle
+			// Make sure that the Io has finished any output before exiting.
+			c.ioCommand <- ioCheckIdle
+			<-c.ioIdle
+		}
 
 		c.events <- StateChange{resultWork.Turn, Quitting}
 		quitChannel <- true // close anonymous goroutine
-		client.Close()      // close the client
-		close(c.events)     // close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
+		if result.err == nil {
+			writeStats()
+		}
+		if statsWriter != nil {
+			statsWriter.Flush()
+		}
+		close(finished) // close the goroutine waiting for changes of state
+		client.Close()  // close the client
+		close(c.events) // close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
+	}
+}
+
+// fail reports an error the run can't carry on from and ends it, closing the events channel like the end of a run
+func fail(c controllerChannels, turn int, err error) {
+	c.events <- Error{CompletedTurns: turn, Err: err, Fatal: true}
+	c.events <- StateChange{turn, Quitting}
+	close(c.events)
+}
+
+// stepTurns returns the number of turns to compute when stepping with 'm', defaulting to 10
+func stepTurns(p Params) int {
+	if p.StepTurns > 0 {
+		return p.StepTurns
 	}
+	return 10
+}
+
+// worldHeight returns the number of rows the world is stored in, with the slices of a volume one after the other
+func worldHeight(p Params) int {
+	if p.ImageDepth > 1 {
+		return p.ImageHeight * p.ImageDepth
+	}
+	return p.ImageHeight
+}
+
+// imageName returns the name of the image of the world, with the depth after the height and width if it's a volume
+func imageName(p Params) string {
+	if p.ImageDepth > 1 {
+		return fmt.Sprintf("%vx%vx%v", p.ImageHeight, p.ImageWidth, p.ImageDepth)
+	}
+	return fmt.Sprintf("%vx%v", p.ImageHeight, p.ImageWidth)
+}
+
+// seed returns the seed stochastic rules draw their random numbers from, which is picked at random and printed so the
+// run can be repeated if there isn't one
+func seed(p Params, r rule.Rule) int64 {
+	if _, ok := r.(rule.Stochastic); !ok || p.Seed != 0 {
+		return p.Seed
+	}
+	seed := time.Now().UnixNano()
+	fmt.Println("Seed:", seed)
+	return seed
+}
+
+// statsRegions returns the number of regions along each side of the grid the density is measured in, defaulting to 4
+func statsRegions(p Params) int {
+	if p.StatsRegions > 0 {
+		return p.StatsRegions
+	}
+	return 4
 }
 
 func printBoard(c controllerChannels, p Params, world [][]byte, turn int) {
+	writeBoard(c, p, world, turn, OutputName(p, turn))
+}
+
+// saveEdited outputs the board after it's been edited, named apart from the boards of the run
+func saveEdited(c controllerChannels, p Params, world [][]byte, turn int) {
+	writeBoard(c, p, world, turn, OutputName(p, turn)+"-edited")
+}
+
+// saveSession saves the run to a session file, with the params, rule, topology and seed it needs to carry on from the
+// turn
+func saveSession(c controllerChannels, p Params, world [][]byte, turn int) {
+	saved, err := newSession(p, world, turn)
+	if err != nil {
+		fmt.Println("Not saving the session:", err)
+		return
+	}
+	filename := filepath.Join(OutputDir(p), OutputName(p, turn)+"-session.tar.gz")
+	if err = session.Save(filename, saved); err != nil {
+		c.events <- Error{CompletedTurns: turn, Err: IOError{Op: "write", Path: filename, Err: err}}
+		return
+	}
+	c.events <- SessionOutputComplete{CompletedTurns: turn, Filename: filename}
+}
+
+// writeBoard outputs the board as a PGM image with the given name through the IO
+func writeBoard(c controllerChannels, p Params, world [][]byte, turn int, filename string) {
 	c.ioCommand <- ioOutput
-	c.ioFilename <- fmt.Sprintf("%vx%vx%v", p.ImageHeight, p.ImageWidth, turn)
-	for y := 0; y < p.ImageHeight; y++ {
+	c.ioFilename <- filename
+	for y := 0; y < worldHeight(p); y++ {
 		for x := 0; x < p.ImageWidth; x++ {
 			c.ioOutput <- world[y][x]
 		}
 	}
-	c.ioCommand <- ioCheckIdle
-	<-c.ioIdle
+	if err := <-c.ioError; err != nil {
+		c.events <- Error{CompletedTurns: turn, Err: err}
+		return
+	}
+	c.events <- ImageOutputComplete{CompletedTurns: turn, Filename: filename}
+}
+
+// exportHeatMaps exports the heat maps of how often each cell was alive and how often it flipped, each as a normalised
+// PGM through the IO and as a false-colour PNG next to it
+func exportHeatMaps(c controllerChannels, p Params, heat heatmap.Counters, turn int) {
+	var filenames []string
+	for _, kind := range []struct {
+		name   string
+		counts [][]uint32
+	}{{"alive", heat.Alive}, {"flips", heat.Flips}} {
+		filename := fmt.Sprintf("%v-%v-heat", OutputName(p, turn), kind.name)
+		grey := heatmap.Normalise(kind.counts)
+		c.ioCommand <- ioOutput
+		c.ioFilename <- filename
+		for y := 0; y < worldHeight(p); y++ {
+			for x := 0; x < p.ImageWidth; x++ {
+				c.ioOutput <- grey[y][x]
+			}
+		}
+		if err := <-c.ioError; err != nil {
+			c.events <- Error{CompletedTurns: turn, Err: err}
+			continue
+		}
+
+		path := filepath.Join(OutputDir(p), filename+".png")
+		file, err := os.Create(path)
+		if err == nil {
+			err = heatmap.WritePNG(file, grey)
+			file.Close()
+		}
+		if err != nil {
+			c.events <- Error{CompletedTurns: turn, Err: IOError{Op: "write", Path: path, Err: err}}
+			continue
+		}
+		filenames = append(filenames, filename)
+	}
+	c.events <- HeatMapOutputComplete{CompletedTurns: turn, Filenames: filenames}
 }
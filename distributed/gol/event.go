@@ -3,6 +3,8 @@ package gol
 import (
 	"fmt"
 
+	"uk.ac.bris.cs/gameoflife/census"
+
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
@@ -36,6 +38,7 @@ const (
 	Paused State = iota
 	Executing
 	Quitting
+	Stopping
 )
 
 // StateChange is an Event notifying the user about the change of state of execution.
@@ -48,9 +51,12 @@ type StateChange struct { // implements Event
 // CellFlipped is an Event notifying the GUI about a change of state of a single cell.
 // This even should be sent every time a cell changes state.
 // Make sure to send this event for all cells that are alive when the image is loaded in.
+// State is the grey level of the new state: 255 for alive, 0 for dead and in between for the refractory states of
+// Generations rules.
 type CellFlipped struct { // implements Event
 	CompletedTurns int
 	Cell           util.Cell
+	State          uint8
 }
 
 // TurnComplete is an Event notifying the GUI about turn completion.
@@ -68,6 +74,44 @@ type FinalTurnComplete struct {
 	Alive          []util.Cell
 }
 
+// CycleDetected is an Event notifying the user that the board has settled into a still life or an oscillator.
+// The board after CompletedTurns is the same as the board after Start, and repeats every Period turns from then on.
+type CycleDetected struct { // implements Event
+	CompletedTurns int
+	Period         int
+	Start          int
+}
+
+// CensusComplete is an Event notifying the user about the number of objects of each type on the board.
+// This Event should be sent every time a census is requested.
+type CensusComplete struct { // implements Event
+	CompletedTurns int
+	Objects        census.Census
+}
+
+// HeatMapOutputComplete is an Event notifying the user that heat maps of the activity so far have been exported.
+// Each filename is written both as a PGM and as a false-colour PNG.
+type HeatMapOutputComplete struct { // implements Event
+	CompletedTurns int
+	Filenames      []string
+}
+
+// SessionOutputComplete is an Event notifying the user that the run has been saved to a session file, which it can be
+// carried on from with LoadSession.
+type SessionOutputComplete struct { // implements Event
+	CompletedTurns int
+	Filename       string
+}
+
+// Error is an Event notifying the user that something went wrong instead of panicking, e.g. an image that couldn't be
+// read or written. Err is one of the error types of this package. A fatal error ends the run, and the events channel is
+// closed after it.
+type Error struct { // implements Event
+	CompletedTurns int
+	Err            error
+	Fatal          bool
+}
+
 // String methods allow the different types of Events and States to be printed.
 
 func (state State) String() string {
@@ -78,6 +122,8 @@ func (state State) String() string {
 		return "Executing"
 	case Quitting:
 		return "Quitting"
+	case Stopping:
+		return "Stopping"
 	default:
 		return "Incorrect State"
 	}
@@ -123,6 +169,52 @@ func (event TurnComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event CycleDetected) String() string {
+	if event.Period == 1 {
+		return fmt.Sprintf("Still life from turn %v", event.Start)
+	}
+	return fmt.Sprintf("Cycle of period %v from turn %v", event.Period, event.Start)
+}
+
+func (event CycleDetected) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
+func (event CensusComplete) String() string {
+	return fmt.Sprintf("Census %v", event.Objects)
+}
+
+func (event CensusComplete) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
+func (event HeatMapOutputComplete) String() string {
+	return fmt.Sprintf("Heat maps %v output", event.Filenames)
+}
+
+func (event HeatMapOutputComplete) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
+func (event SessionOutputComplete) String() string {
+	return fmt.Sprintf("Session %v saved", event.Filename)
+}
+
+func (event SessionOutputComplete) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
+func (event Error) String() string {
+	if event.Fatal {
+		return fmt.Sprintf("Fatal error: %v", event.Err)
+	}
+	return fmt.Sprintf("Error: %v", event.Err)
+}
+
+func (event Error) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event FinalTurnComplete) String() string {
 	return fmt.Sprintf("")
 }
@@ -4,15 +4,15 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
-
-	"uk.ac.bris.cs/gameoflife/util"
 )
 
 type ioChannels struct {
 	command  <-chan ioCommand
 	idle     chan<- bool
+	errors   chan<- error
 	filename <-chan string
 	output   <-chan uint8
 	input    chan<- uint8
@@ -38,30 +38,17 @@ const (
 	ioCheckIdle
 )
 
-// writePgmImage receives an array of bytes and writes it to a pgm file.
-func (io *ioState) writePgmImage() {
-	_ = os.Mkdir("out", os.ModePerm)
-
+// writePgmImage receives an array of bytes and writes it to a pgm file, returning why it couldn't if it couldn't. The
+// bytes are all received first, so the sender isn't left waiting if the file can't be written.
+func (io *ioState) writePgmImage() error {
 	filename := <-io.channels.filename
-	file, ioError := os.Create("out/" + filename + ".pgm")
-	util.Check(ioError)
-	defer file.Close()
-
-	_, _ = file.WriteString("P5\n")
-	//_, _ = file.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageWidth))
-	_, _ = file.WriteString(" ")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageHeight))
-	_, _ = file.WriteString("\n")
-	_, _ = file.WriteString(strconv.Itoa(255))
-	_, _ = file.WriteString("\n")
 
-	world := make([][]byte, io.params.ImageHeight)
+	world := make([][]byte, worldHeight(io.params))
 	for i := range world {
 		world[i] = make([]byte, io.params.ImageWidth)
 	}
 
-	for y := 0; y < io.params.ImageHeight; y++ {
+	for y := 0; y < worldHeight(io.params); y++ {
 		for x := 0; x < io.params.ImageWidth; x++ {
 			val := <-io.channels.output
 			//if val != 0 {
@@ -71,53 +58,90 @@ func (io *ioState) writePgmImage() {
 		}
 	}
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			_, ioError = file.Write([]byte{world[y][x]})
-			util.Check(ioError)
+	path := filepath.Join(OutputDir(io.params), filename+".pgm")
+	file, ioError := os.Create(path)
+	if ioError != nil {
+		return IOError{Op: "write", Path: path, Err: ioError}
+	}
+	defer file.Close()
+
+	_, _ = file.WriteString("P5\n")
+	//_, _ = file.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
+	_, _ = file.WriteString(strconv.Itoa(io.params.ImageWidth))
+	_, _ = file.WriteString(" ")
+	_, _ = file.WriteString(strconv.Itoa(worldHeight(io.params)))
+	_, _ = file.WriteString("\n")
+	_, _ = file.WriteString(strconv.Itoa(255))
+	_, _ = file.WriteString("\n")
+
+	for y := 0; y < worldHeight(io.params); y++ {
+		if _, ioError = file.Write(world[y]); ioError != nil {
+			return IOError{Op: "write", Path: path, Err: ioError}
 		}
 	}
 
-	ioError = file.Sync()
-	util.Check(ioError)
+	if ioError = file.Sync(); ioError != nil {
+		return IOError{Op: "write", Path: path, Err: ioError}
+	}
 
 	fmt.Println("File", filename, "output done!")
+	return nil
 }
 
-// readPgmImage opens a pgm file and sends its data as an array of bytes.
+// readPgmImage opens a pgm file and sends its data as an array of bytes. Whether it could be read is sent first, and
+// nothing else is sent if it couldn't.
 func (io *ioState) readPgmImage() {
 	filename := <-io.channels.filename
-	data, ioError := ioutil.ReadFile("images/" + filename + ".pgm")
-	util.Check(ioError)
+	path := "images/" + filename + ".pgm"
+	if io.params.Input != "" {
+		path = io.params.Input
+	}
+	image, ioError := io.readPgm(path)
+	io.channels.errors <- ioError
+	if ioError != nil {
+		return
+	}
+
+	for _, b := range image {
+		io.channels.input <- b
+	}
+
+	fmt.Println("File", filename, "input done!")
+}
+
+// readPgm reads the pixels of a pgm file, checking it's an image of the world.
+func (io *ioState) readPgm(path string) ([]byte, error) {
+	data, ioError := ioutil.ReadFile(path)
+	if ioError != nil {
+		return nil, IOError{Op: "read", Path: path, Err: ioError}
+	}
 
 	fields := strings.Fields(string(data))
 
-	if fields[0] != "P5" {
-		panic("Not a pgm file")
+	if len(fields) < 5 || fields[0] != "P5" {
+		return nil, FormatError{Path: path, Problem: "is not a pgm file"}
 	}
 
 	width, _ := strconv.Atoi(fields[1])
 	if width != io.params.ImageWidth {
-		panic("Incorrect width")
+		return nil, FormatError{Path: path, Problem: fmt.Sprintf("is %v cells wide, expected %v", fields[1], io.params.ImageWidth)}
 	}
 
 	height, _ := strconv.Atoi(fields[2])
-	if height != io.params.ImageHeight {
-		panic("Incorrect height")
+	if height != worldHeight(io.params) {
+		return nil, FormatError{Path: path, Problem: fmt.Sprintf("is %v cells high, expected %v", fields[2], worldHeight(io.params))}
 	}
 
 	maxval, _ := strconv.Atoi(fields[3])
 	if maxval != 255 {
-		panic("Incorrect maxval/bit depth")
+		return nil, FormatError{Path: path, Problem: fmt.Sprintf("has a maxval of %v, expected 8-bit grey levels", fields[3])}
 	}
 
 	image := []byte(fields[4])
-
-	for _, b := range image {
-		io.channels.input <- b
+	if len(image) != width*height {
+		return nil, FormatError{Path: path, Problem: fmt.Sprintf("has %v pixels, expected %v", len(image), width*height)}
 	}
-
-	fmt.Println("File", filename, "input done!")
+	return image, nil
 }
 
 // startIo should be the entrypoint of the io goroutine.
@@ -134,7 +158,7 @@ func startIo(p Params, c ioChannels) {
 			case ioInput:
 				io.readPgmImage()
 			case ioOutput:
-				io.writePgmImage()
+				io.channels.errors <- io.writePgmImage()
 			case ioCheckIdle:
 				io.channels.idle <- true
 			}
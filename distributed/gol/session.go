@@ -0,0 +1,60 @@
+package gol
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"uk.ac.bris.cs/gameoflife/rule"
+	"uk.ac.bris.cs/gameoflife/session"
+)
+
+// LoadSession gets the params of a run saved with 'e', which carry it on from the turn it was saved on when passed to
+// Run. They're the params it was run with, along with the seed stochastic rules drew their random numbers from.
+func LoadSession(path string) (Params, error) {
+	s, err := loadSession(path)
+	if err != nil {
+		return Params{}, err
+	}
+	var p Params
+	if err := json.Unmarshal(s.Params, &p); err != nil {
+		return Params{}, FormatError{Path: path, Problem: fmt.Sprintf("has no params: %v", err)}
+	}
+	if _, err := rule.New(s.Rule); err != nil {
+		return Params{}, err
+	}
+	p.Topology = s.Topology
+	p.Seed = s.Seed
+	p.Session = path
+	return p, nil
+}
+
+// loadSession loads a session file, with an IOError if it can't be read and a FormatError if it isn't a session.
+func loadSession(path string) (session.Session, error) {
+	s, err := session.Load(path)
+	if _, ok := err.(*os.PathError); ok {
+		return session.Session{}, IOError{Op: "read", Path: path, Err: err}
+	}
+	if err != nil {
+		return session.Session{}, FormatError{Path: path, Problem: fmt.Sprintf("is not a session: %v", err)}
+	}
+	return s, nil
+}
+
+// newSession gets the session that carries a run on from a turn. The seed in the params has to be the one the random
+// numbers are drawn from, rather than 0 for a random one.
+func newSession(p Params, world [][]byte, turn int) (session.Session, error) {
+	r, err := rule.Parse(p.Rule)
+	if err != nil {
+		r = rule.Life
+	}
+	p.Session = ""
+	params, err := json.Marshal(p)
+	if err != nil {
+		return session.Session{}, err
+	}
+	return session.Session{
+		Manifest: session.Manifest{Turn: turn, Rule: r.Spec(), Topology: p.Topology, Seed: p.Seed, Params: params},
+		World:    world,
+	}, nil
+}
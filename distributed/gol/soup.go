@@ -0,0 +1,66 @@
+package gol
+
+import (
+	"fmt"
+
+	"uk.ac.bris.cs/gameoflife/census"
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+// SoupParams provides the details of a soup search.
+type SoupParams struct {
+	Soups    int     // number of soups to run
+	Size     int     // side of each square soup
+	Density  float64 // proportion of alive cells in each soup
+	Seed     int64   // seed of the first soup, the others use consecutive seeds. Random if 0
+	MaxTurns int     // soups that haven't settled after this many turns are censused as they are
+	Workers  int     // number of workers to farm the soups out to
+}
+
+// RareObject is an object that doesn't often turn up in soups, with the seed that reproduces the soup it came from.
+type RareObject struct {
+	Name string
+	Seed int64
+}
+
+// SoupReport is the census of the ash of every soup in a search.
+type SoupReport struct {
+	Soups          int
+	Settled        int
+	Objects        census.Census
+	Rare           []RareObject
+	SoupsPerSecond float64
+}
+
+// SearchSoups asks the engine to run random soups on its workers until they settle, and reports what they settled into.
+func SearchSoups(sp SoupParams) (SoupReport, error) {
+	client, err := connect(nil)
+	if err != nil {
+		return SoupReport{}, err
+	}
+	defer client.Close()
+
+	request := stubs.RequestSoupSearch{Soups: sp.Soups, Size: sp.Size, Density: sp.Density, MaxTurns: sp.MaxTurns, NumWorkers: sp.Workers, Seed: sp.Seed}
+	response := new(stubs.ResponseSoupSearch)
+	if err = client.call(stubs.SoupSearchHandler, request, response); err != nil {
+		return SoupReport{}, err
+	}
+	report := SoupReport{
+		Soups:          response.Soups,
+		Settled:        response.Settled,
+		Objects:        response.Objects,
+		SoupsPerSecond: response.SoupsPerSecond,
+	}
+	for _, rare := range response.Rare {
+		report.Rare = append(report.Rare, RareObject{Name: rare.Name, Seed: rare.Seed})
+	}
+	return report, nil
+}
+
+func (report SoupReport) String() string {
+	s := fmt.Sprintf("Searched %v soups (%v settled) at %.1f soups/s\nAsh: %v\n", report.Soups, report.Settled, report.SoupsPerSecond, report.Objects)
+	for _, rare := range report.Rare {
+		s += fmt.Sprintf("Found %v in soup with seed %v\n", rare.Name, rare.Seed)
+	}
+	return s
+}
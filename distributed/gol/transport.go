@@ -0,0 +1,127 @@
+package gol
+
+import (
+	"fmt"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/transport"
+)
+
+// dialAttempts is the number of times the engine is dialled before giving up on it. The controller waits retryDelay
+// after the first failed attempt, and twice as long after each one after that.
+const (
+	dialAttempts = 4
+	retryDelay   = 250 * time.Millisecond
+)
+
+// DefaultTransport is the transport to the engine used if there isn't one in the params. Tests can swap it for an
+// in-process transport to an engine running inside the test binary.
+var DefaultTransport transport.Transport = transport.TCP{}
+
+// EngineError is an error calling the engine. It's Unreachable if the call didn't get an answer from the engine, even
+// after retrying, rather than the engine answering it with an error, e.g. that it has to be paused to step.
+type EngineError struct {
+	Method      string
+	Err         error
+	Unreachable bool
+}
+
+func (e EngineError) Error() string {
+	if e.Unreachable {
+		return fmt.Sprintf("can't reach the engine for %v: %v", e.Method, e.Err)
+	}
+	return fmt.Sprintf("the engine refused %v: %v", e.Method, e.Err)
+}
+
+// engineClient is the connection of a controller to the engine, which is dialled again if it drops
+type engineClient struct {
+	transport transport.Transport
+	address   string
+
+	mu     sync.Mutex
+	client transport.Client
+}
+
+// connect dials the engine at the address given with the server flag, with the default transport if there isn't one
+func connect(t transport.Transport) (*engineClient, error) {
+	if t == nil {
+		t = DefaultTransport
+	}
+	e := &engineClient{transport: t, address: ServerAddress()}
+	client, err := e.dial()
+	if err != nil {
+		return nil, err
+	}
+	e.client = client
+	return e, nil
+}
+
+// dial dials the engine, trying again with a growing delay if it can't be reached
+func (e *engineClient) dial() (transport.Client, error) {
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		client, err := e.transport.Dial(e.address)
+		if err == nil {
+			return client, nil
+		}
+		if attempt == dialAttempts {
+			return nil, EngineError{Method: "dialling " + e.address, Err: err, Unreachable: true}
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// call makes a call to the engine that changes the run, which isn't made again if the connection drops, as the engine
+// might have carried it out already
+func (e *engineClient) call(serviceMethod string, args interface{}, reply interface{}) error {
+	e.mu.Lock()
+	client := e.client
+	e.mu.Unlock()
+	return callError(serviceMethod, client.Call(serviceMethod, args, reply))
+}
+
+// query makes a call to the engine that only asks it about the run, which is made again on a new connection if the
+// connection drops
+func (e *engineClient) query(serviceMethod string, args interface{}, reply interface{}) error {
+	e.mu.Lock()
+	client := e.client
+	e.mu.Unlock()
+	err := client.Call(serviceMethod, args, reply)
+	if _, answered := err.(rpc.ServerError); err == nil || answered {
+		return callError(serviceMethod, err)
+	}
+
+	// Only the first call to find the connection has dropped dials again, the others wait for it and use the new one
+	e.mu.Lock()
+	if e.client == client {
+		client.Close()
+		redialled, dialErr := e.dial()
+		if dialErr != nil {
+			e.mu.Unlock()
+			return callError(serviceMethod, err)
+		}
+		e.client = redialled
+	}
+	client = e.client
+	e.mu.Unlock()
+	return callError(serviceMethod, client.Call(serviceMethod, args, reply))
+}
+
+// Close closes the connection to the engine
+func (e *engineClient) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.client.Close()
+}
+
+// callError returns the error of a call to the engine as an EngineError, or nil if there wasn't one
+func callError(serviceMethod string, err error) error {
+	if err == nil {
+		return nil
+	}
+	_, answered := err.(rpc.ServerError)
+	return EngineError{Method: serviceMethod, Err: err, Unreachable: !answered}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/heatmap"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestHeatMap runs the 16x16 glider with heat maps and checks the exported PGMs against counters built up by stepping
+// the board here, as well as the colours of the PNGs. The workers count their own rows, so it's run with an uneven
+// split of the rows as well as a single worker.
+func TestHeatMap(t *testing.T) {
+	for _, workers := range []int{1, 3} {
+		p := gol.Params{
+			Turns:       50,
+			Threads:     workers,
+			ImageWidth:  16,
+			ImageHeight: 16,
+			HeatMap:     true,
+		}
+		t.Run(fmt.Sprintf("%d_workers", workers), func(t *testing.T) {
+			board := make([][]byte, p.ImageHeight)
+			for y := range board {
+				board[y] = make([]byte, p.ImageWidth)
+			}
+			for _, cell := range util.ReadAliveCells("images/16x16.pgm", p.ImageWidth, p.ImageHeight) {
+				board[cell.Y][cell.X] = 255
+			}
+			expected := heatmap.New(p.ImageHeight, p.ImageWidth)
+			for turn := 0; turn < p.Turns; turn++ {
+				next := nextBoard(board)
+				expected.Add(board, next)
+				board = next
+			}
+
+			events := make(chan gol.Event)
+			gol.Run(p, events, nil)
+			var filenames []string
+			for event := range events {
+				if e, ok := event.(gol.HeatMapOutputComplete); ok {
+					if e.CompletedTurns != p.Turns {
+						t.Errorf("Expected heat maps of turn %v, got turn %v", p.Turns, e.CompletedTurns)
+					}
+					filenames = e.Filenames
+				}
+			}
+
+			if len(filenames) != 2 {
+				t.Fatalf("Expected alive and flips heat maps, got %v", filenames)
+			}
+			for i, counts := range [][][]uint32{expected.Alive, expected.Flips} {
+				grey := heatmap.Normalise(counts)
+				data, err := ioutil.ReadFile("out/" + filenames[i] + ".pgm")
+				if err != nil {
+					t.Fatal(err)
+				}
+				header := fmt.Sprintf("P5\n%d %d\n255\n", p.ImageWidth, p.ImageHeight)
+				if !bytes.HasPrefix(data, []byte(header)) {
+					t.Fatalf("%v.pgm doesn't start with the header %q", filenames[i], header)
+				}
+				data = data[len(header):]
+				for y := 0; y < p.ImageHeight; y++ {
+					if !bytes.Equal(data[y*p.ImageWidth:(y+1)*p.ImageWidth], grey[y]) {
+						t.Fatalf("Row %v of %v.pgm is %v, expected %v", y, filenames[i], data[y*p.ImageWidth:(y+1)*p.ImageWidth], grey[y])
+					}
+				}
+
+				file, err := os.Open("out/" + filenames[i] + ".png")
+				if err != nil {
+					t.Fatal(err)
+				}
+				img, err := png.Decode(file)
+				file.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				for y := 0; y < p.ImageHeight; y++ {
+					for x := 0; x < p.ImageWidth; x++ {
+						r, g, b, _ := img.At(x, y).RGBA()
+						c := heatmap.Colour(grey[y][x])
+						if uint8(r>>8) != c.R || uint8(g>>8) != c.G || uint8(b>>8) != c.B {
+							t.Fatalf("Pixel (%v, %v) of %v.png is not the colour of %v", x, y, filenames[i], grey[y][x])
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// nextBoard computes a single turn of a board on a torus, to check the engine against.
+func nextBoard(board [][]byte) [][]byte {
+	height, width := len(board), len(board[0])
+	next := make([][]byte, height)
+	for y := range board {
+		next[y] = make([]byte, width)
+		for x := range board[y] {
+			neighbours := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if (dy != 0 || dx != 0) && board[(y+dy+height)%height][(x+dx+width)%width] == 255 {
+						neighbours++
+					}
+				}
+			}
+			if neighbours == 3 || (neighbours == 2 && board[y][x] == 255) {
+				next[y][x] = 255
+			}
+		}
+	}
+	return next
+}
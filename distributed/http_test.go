@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/broker"
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+// httpRoutes are the routes of the HTTP API and the method each of them takes.
+var httpRoutes = map[string]string{
+	"/api/runs":         http.MethodPost,
+	"/api/soups":        http.MethodPost,
+	"/api/status":       http.MethodGet,
+	"/api/controllers":  http.MethodPost,
+	"/api/alive":        http.MethodGet,
+	"/api/snapshot":     http.MethodGet,
+	"/api/census":       http.MethodGet,
+	"/api/stats":        http.MethodGet,
+	"/api/heatmap":      http.MethodGet,
+	"/api/results":      http.MethodGet,
+	"/api/pause":        http.MethodPost,
+	"/api/resume":       http.MethodPost,
+	"/api/step":         http.MethodPost,
+	"/api/rewind":       http.MethodPost,
+	"/api/seek":         http.MethodPost,
+	"/api/cells":        http.MethodPost,
+	"/api/history":      http.MethodGet,
+	"/api/stop":         http.MethodPost,
+	"/api/workers/stop": http.MethodPost,
+	"/api/openapi.json": http.MethodGet,
+}
+
+// startHTTPServer serves the HTTP API of the engine inside the test binary, after replacing whatever session a previous
+// test left running, so requests that wait for results don't block.
+func startHTTPServer(t *testing.T) *httptest.Server {
+	if testEngine == nil {
+		t.Skip("The HTTP API is only tested against the engine inside the test binary")
+	}
+	settleEngine(t)
+	return httptest.NewServer(broker.Handler(testEngine))
+}
+
+// pgmBody encodes a world as a binary PGM image.
+func pgmBody(world [][]byte) io.Reader {
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "P5\n%d %d\n255\n", len(world[0]), len(world))
+	for _, row := range world {
+		body.Write(row)
+	}
+	return &body
+}
+
+// request sends a request to the server and checks it's answered with the expected status, decoding a JSON body into
+// reply if it's given, and returns the response with its body read.
+func request(t *testing.T, server *httptest.Server, method, path string, body io.Reader, status int, reply interface{}) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != status {
+		t.Fatalf("%v %v answered %v %s, expected %v", method, path, res.StatusCode, data, status)
+	}
+	if reply != nil {
+		if err := json.Unmarshal(data, reply); err != nil {
+			t.Fatalf("%v %v answered %s, which isn't JSON: %v", method, path, data, err)
+		}
+	}
+	return res, data
+}
+
+// TestHTTP starts runs and controls them through every route of the HTTP API, checking the status of each answer,
+// including that only an operator of the run that's running can stop it or start another one.
+func TestHTTP(t *testing.T) {
+	server := startHTTPServer(t)
+	defer server.Close()
+	world := make([][]byte, 16)
+	for y := range world {
+		world[y] = make([]byte, 16)
+	}
+	world[5][4], world[5][5], world[5][6] = 255, 255, 255
+
+	status := new(stubs.ResponseStatus)
+	request(t, server, http.MethodGet, "/api/status", nil, http.StatusOK, status)
+	if status.Running {
+		t.Fatalf("The engine is running before a run has been started")
+	}
+	res, _ := request(t, server, http.MethodGet, "/api/runs", nil, http.StatusMethodNotAllowed, nil)
+	if allow := res.Header.Get("Allow"); allow != http.MethodPost {
+		t.Errorf("GET /api/runs allows %q, expected POST", allow)
+	}
+	request(t, server, http.MethodPost, "/api/runs", strings.NewReader("P2\n16 16\n255\n"), http.StatusBadRequest, nil)
+	for _, body := range []string{"", "P5\n0 0\n255\n", "P5\n16 0\n255\n"} {
+		request(t, server, http.MethodPost, "/api/runs", strings.NewReader(body), http.StatusBadRequest, nil)
+	}
+	for _, empty := range [][][]byte{nil, {}, {{}, {}}} {
+		if err := testEngine.GameOfLife(stubs.RequestStart{World: empty, Turns: 1, NumWorkers: 1}, new(stubs.ResponseStart)); err == nil {
+			t.Errorf("A run was started on the empty world %v", empty)
+		}
+	}
+	for _, query := range []string{"workers=0", "workers=1000", "turns=x", "history=-1", "rule=nonsense", "topology=cube"} {
+		request(t, server, http.MethodPost, "/api/runs?"+query, pgmBody(world), http.StatusBadRequest, nil)
+	}
+	request(t, server, http.MethodPost, "/api/soups?soups=0", nil, http.StatusBadRequest, nil)
+
+	// A run without a history, stats or heat maps
+	started := new(stubs.ResponseStart)
+	request(t, server, http.MethodPost, "/api/runs?turns=100000000&workers=2", pgmBody(world), http.StatusAccepted, started)
+	operator := started.ControllerID
+	request(t, server, http.MethodGet, "/api/status", nil, http.StatusOK, status)
+	if !status.Running {
+		t.Errorf("The engine isn't running after a run has been started")
+	}
+	attached := new(stubs.ResponseReconnect)
+	request(t, server, http.MethodPost, "/api/controllers?role=observer", nil, http.StatusCreated, attached)
+	observer := attached.ControllerID
+	request(t, server, http.MethodPost, "/api/controllers?role=owner", nil, http.StatusBadRequest, nil)
+	request(t, server, http.MethodPost, "/api/controllers?role=operator", nil, http.StatusUnauthorized, nil)
+	request(t, server, http.MethodPost, "/api/controllers?role=operator&controller="+observer, nil, http.StatusForbidden, nil)
+	request(t, server, http.MethodPost, "/api/controllers?role=operator&controller="+operator, nil, http.StatusCreated, attached)
+	request(t, server, http.MethodPost, "/api/pause?controller="+attached.ControllerID, nil, http.StatusOK, nil)
+	request(t, server, http.MethodPost, "/api/resume?controller="+attached.ControllerID, nil, http.StatusOK, nil)
+
+	request(t, server, http.MethodPost, "/api/runs", pgmBody(world), http.StatusUnauthorized, nil)
+	request(t, server, http.MethodPost, "/api/runs?controller="+observer, pgmBody(world), http.StatusForbidden, nil)
+	request(t, server, http.MethodPost, "/api/runs?controller=-1", pgmBody(world), http.StatusUnauthorized, nil)
+	request(t, server, http.MethodPost, "/api/pause", nil, http.StatusBadRequest, nil)
+	request(t, server, http.MethodPost, "/api/pause?controller=x", nil, http.StatusUnauthorized, nil)
+	request(t, server, http.MethodPost, "/api/pause?controller="+observer, nil, http.StatusForbidden, nil)
+	request(t, server, http.MethodPost, "/api/pause?controller="+operator, nil, http.StatusOK, nil)
+
+	alive := new(stubs.ResponseAliveCells)
+	request(t, server, http.MethodGet, "/api/alive", nil, http.StatusOK, alive)
+	if alive.NumAliveCells != 3 || alive.CompletedTurns < 1 {
+		t.Errorf("A blinker paused on turn %v has %v alive cells, expected 3", alive.CompletedTurns, alive.NumAliveCells)
+	}
+	_, pgm := request(t, server, http.MethodGet, "/api/snapshot", nil, http.StatusOK, nil)
+	if !bytes.HasPrefix(pgm, []byte("P5\n16 16\n255\n")) || len(pgm) != len("P5\n16 16\n255\n")+16*16 {
+		t.Errorf("The snapshot isn't a 16x16 PGM image: %q", pgm)
+	}
+	_, png := request(t, server, http.MethodGet, "/api/snapshot?format=png", nil, http.StatusOK, nil)
+	if !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Errorf("The snapshot isn't a PNG image")
+	}
+	request(t, server, http.MethodGet, "/api/snapshot?format=gif", nil, http.StatusBadRequest, nil)
+	request(t, server, http.MethodGet, "/api/census", nil, http.StatusOK, new(stubs.ResponseCensus))
+	request(t, server, http.MethodGet, "/api/stats", nil, http.StatusConflict, nil)
+	request(t, server, http.MethodGet, "/api/stats?from=x", nil, http.StatusBadRequest, nil)
+	request(t, server, http.MethodGet, "/api/heatmap", nil, http.StatusConflict, nil)
+	request(t, server, http.MethodGet, "/api/history", nil, http.StatusBadRequest, nil)
+	request(t, server, http.MethodGet, "/api/history?turn=1", nil, http.StatusConflict, nil)
+	request(t, server, http.MethodPost, "/api/rewind?controller="+operator, nil, http.StatusConflict, nil)
+	request(t, server, http.MethodPost, "/api/seek?controller="+operator, nil, http.StatusBadRequest, nil)
+
+	stepped := new(stubs.ResponseStep)
+	request(t, server, http.MethodPost, "/api/step?controller="+operator+"&turns=2", nil, http.StatusOK, stepped)
+	if stepped.CompletedTurns != alive.CompletedTurns+2 {
+		t.Errorf("Stepping 2 turns from turn %v went to turn %v", alive.CompletedTurns, stepped.CompletedTurns)
+	}
+	request(t, server, http.MethodPost, "/api/step?controller="+observer, nil, http.StatusForbidden, nil)
+	set := new(stubs.ResponseSetCells)
+	request(t, server, http.MethodPost, "/api/cells?controller="+operator, strings.NewReader(`[{"x": 0, "y": 0, "state": 255}]`), http.StatusOK, set)
+	if len(set.Cells) != 1 || set.NumAliveCells != 4 {
+		t.Errorf("Setting a cell changed %v cells and left %v alive cells, expected 1 and 4", len(set.Cells), set.NumAliveCells)
+	}
+	request(t, server, http.MethodPost, "/api/cells?controller="+operator, strings.NewReader(`{"x": 0}`), http.StatusBadRequest, nil)
+	request(t, server, http.MethodPost, "/api/cells?controller="+operator, strings.NewReader(`[{"x": 16, "y": 0, "state": 255}]`), http.StatusBadRequest, nil)
+	request(t, server, http.MethodPost, "/api/workers/stop?controller="+observer, nil, http.StatusForbidden, nil)
+	request(t, server, http.MethodPost, "/api/stop?controller="+observer, nil, http.StatusForbidden, nil)
+	request(t, server, http.MethodPost, "/api/resume?controller="+observer, nil, http.StatusForbidden, nil)
+	request(t, server, http.MethodPost, "/api/resume?controller="+operator, nil, http.StatusOK, nil)
+
+	// An operator of the first run starts a run with a history, stats and heat maps in its place
+	request(t, server, http.MethodPost, "/api/runs?controller="+operator+"&turns=100000000&workers=2&history=1&regions=2&heatmap=true", pgmBody(world), http.StatusAccepted, started)
+	operator = started.ControllerID
+	request(t, server, http.MethodPost, "/api/pause?controller="+operator, nil, http.StatusOK, nil)
+	request(t, server, http.MethodPost, "/api/step?controller="+operator+"&turns=3", nil, http.StatusOK, stepped)
+	statsReply := new(stubs.ResponseStats)
+	request(t, server, http.MethodGet, "/api/stats", nil, http.StatusOK, statsReply)
+	if len(statsReply.Turns) == 0 {
+		t.Errorf("No stats were kept")
+	}
+	res, _ = request(t, server, http.MethodGet, "/api/stats?format=csv", nil, http.StatusOK, nil)
+	if contentType := res.Header.Get("Content-Type"); contentType != "text/csv" {
+		t.Errorf("The stats were sent as %v, expected text/csv", contentType)
+	}
+	request(t, server, http.MethodGet, "/api/heatmap?kind=flips", nil, http.StatusOK, nil)
+	request(t, server, http.MethodGet, "/api/heatmap?kind=age", nil, http.StatusBadRequest, nil)
+	rewound := new(stubs.ResponseStep)
+	request(t, server, http.MethodPost, "/api/rewind?controller="+operator+"&turns=2", nil, http.StatusOK, rewound)
+	if rewound.CompletedTurns != stepped.CompletedTurns-2 {
+		t.Errorf("Rewinding 2 turns from turn %v went to turn %v", stepped.CompletedTurns, rewound.CompletedTurns)
+	}
+	seekTo := fmt.Sprint(rewound.CompletedTurns - 1)
+	request(t, server, http.MethodPost, "/api/seek?controller="+operator+"&turn="+seekTo, nil, http.StatusOK, nil)
+	res, _ = request(t, server, http.MethodGet, "/api/history?turn="+seekTo, nil, http.StatusOK, nil)
+	if turn := res.Header.Get("X-Completed-Turns"); turn != seekTo {
+		t.Errorf("The history of turn %v was sent for turn %v", seekTo, turn)
+	}
+	request(t, server, http.MethodGet, "/api/history?turn=1000000000", nil, http.StatusNotFound, nil)
+	request(t, server, http.MethodPost, "/api/seek?controller="+operator+"&turn=1000000000", nil, http.StatusNotFound, nil)
+
+	request(t, server, http.MethodPost, "/api/stop?controller="+operator, nil, http.StatusOK, nil)
+	res, _ = request(t, server, http.MethodGet, "/api/results", nil, http.StatusOK, nil)
+	if stopped := res.Header.Get("X-Stopped"); stopped != "true" {
+		t.Errorf("The results of a stopped run were sent with X-Stopped %q", stopped)
+	}
+	request(t, server, http.MethodPost, "/api/pause?controller="+operator, nil, http.StatusConflict, nil)
+	request(t, server, http.MethodGet, "/api/status", nil, http.StatusOK, status)
+	if status.Running {
+		t.Errorf("The engine is still running after the run has been stopped")
+	}
+
+	_, page := request(t, server, http.MethodGet, "/", nil, http.StatusOK, nil)
+	if !bytes.Contains(page, []byte("<canvas")) {
+		t.Errorf("The viewer page doesn't have a canvas")
+	}
+	request(t, server, http.MethodGet, "/api/nothing", nil, http.StatusNotFound, nil)
+}
+
+// TestOpenAPI checks the OpenAPI description lists every route of the HTTP API with the method the route takes, and
+// nothing else.
+func TestOpenAPI(t *testing.T) {
+	server := startHTTPServer(t)
+	defer server.Close()
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	request(t, server, http.MethodGet, "/api/openapi.json", nil, http.StatusOK, &spec)
+
+	for path, method := range httpRoutes {
+		if _, ok := spec.Paths[path][strings.ToLower(method)]; !ok || len(spec.Paths[path]) != 1 {
+			t.Errorf("The OpenAPI description of %v is %v, expected %v", path, spec.Paths[path], strings.ToLower(method))
+		}
+	}
+	for path := range spec.Paths {
+		if _, ok := httpRoutes[path]; !ok {
+			t.Errorf("The OpenAPI description has %v, which isn't a route", path)
+		}
+	}
+	// Every route answers other methods with the method it takes, where a path that isn't a route would be answered by
+	// the viewer page
+	for path, method := range httpRoutes {
+		res, _ := request(t, server, http.MethodPut, path, nil, http.StatusMethodNotAllowed, nil)
+		if allow := res.Header.Get("Allow"); allow != method {
+			t.Errorf("%v allows %v, expected %v", path, allow, method)
+		}
+		if method == http.MethodGet {
+			res, err := server.Client().Get(server.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode == http.StatusNotFound {
+				t.Errorf("GET %v isn't a route", path)
+			}
+		}
+	}
+}
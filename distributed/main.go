@@ -6,7 +6,9 @@ import (
 	"runtime"
 
 	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/rule"
 	"uk.ac.bris.cs/gameoflife/sdl"
+	"uk.ac.bris.cs/gameoflife/theme"
 )
 
 // main is the function called when starting Game of Life ff with 'go run .'
@@ -32,6 +34,12 @@ func main() {
 		512,
 		"Specify the height of the image. Defaults to 512.")
 
+	flag.IntVar(
+		&params.ImageDepth,
+		"d",
+		0,
+		"Specify the depth of the image for 3D rules, i.e. the number of slices stacked one after the other in it. The world is flat if 0 or 1. Defaults to 0.")
+
 	flag.IntVar(
 		&params.Turns,
 		"turns",
@@ -44,6 +52,18 @@ func main() {
 		false,
 		"Specify if controller should try to reconnect to an already running engine. Defaults to false.")
 
+	flag.StringVar(
+		&params.ControllerID,
+		"controller",
+		"",
+		"Specify the ID of an operator of the run on the engine, printed by the controller that started it, to reconnect as an operator with -reconnect or to replace it with a new run.")
+
+	flag.BoolVar(
+		&params.Observer,
+		"observe",
+		false,
+		"Specify if controller should attach to an already running engine as an observer, which can't pause or stop it. Defaults to false.")
+
 	flag.IntVar(
 		&params.Threads,
 		"workers",
@@ -51,16 +71,226 @@ func main() {
 		"Specify the number of workers to use. Defaults to 2.",
 	)
 
+	flag.IntVar(
+		&params.StepTurns,
+		"step",
+		10,
+		"Specify the number of turns to compute when stepping with 'm' while paused. Defaults to 10.")
+
+	flag.BoolVar(
+		&params.StopOnCycle,
+		"cycle",
+		false,
+		"Specify if the engine should stop early once the board settles into a still life or an oscillator. Defaults to false.")
+
+	flag.StringVar(
+		&params.StatsFile,
+		"stats",
+		"",
+		"Specify a file to write the metrics of every turn to, as JSON Lines if it ends in .jsonl and as CSV otherwise. Disabled if empty.")
+
+	flag.IntVar(
+		&params.StatsRegions,
+		"regions",
+		4,
+		"Specify the number of regions along each side of the grid the density is measured in. Defaults to 4.")
+
+	flag.BoolVar(
+		&params.HeatMap,
+		"heatmap",
+		false,
+		"Specify if the workers should count how often each cell is alive and flips, exported as heat maps with 'h' and at the end of the run. Defaults to false.")
+
+	flag.StringVar(
+		&params.Rule,
+		"rule",
+		"B3/S23",
+		"Specify the rule in B/S/C notation, e.g. B2/S/C3 for Brian's Brain, B2/S345/C4 for Star Wars or B2n3/S23-q with Hensel's non-totalistic letters, a Larger than Life rule in LtL notation, e.g. R5,C0,M1,S34..58,B34..45,NM, Wireworld, 3d:4555 for a 3D rule in Bays' notation, run on a volume of -d slices, or stochastic:B3/S23,birth=0.9,survive=0.95,update=0.5 for births, survivals and updates that only happen with a chance. Defaults to B3/S23.")
+
+	historyMB := flag.Int(
+		"history",
+		0,
+		"Specify the memory budget in MB the engine uses to keep recent turns to rewind through, e.g. 64. Disabled if 0. Defaults to 0.")
+
+	var soupParams gol.SoupParams
+
+	flag.IntVar(
+		&soupParams.Soups,
+		"soups",
+		0,
+		"Specify the number of random soups to search instead of running the Game of Life on an image. Disabled if 0. Defaults to 0.")
+
+	flag.IntVar(
+		&soupParams.Size,
+		"soupsize",
+		16,
+		"Specify the side of each square soup. Defaults to 16.")
+
+	flag.Float64Var(
+		&soupParams.Density,
+		"density",
+		0.5,
+		"Specify the proportion of alive cells in each soup. Defaults to 0.5.")
+
+	flag.Int64Var(
+		&soupParams.Seed,
+		"seed",
+		0,
+		"Specify the seed of the first soup, the others use consecutive seeds, or the seed stochastic rules draw their random numbers from, so runs can be repeated. Random if 0. Defaults to 0.")
+
+	flag.StringVar(
+		&params.Topology,
+		"topology",
+		"square",
+		"Specify the lattice the cells are laid out on: square, hex for hexagonal cells with the odd rows shifted half a cell right, or triangular. Defaults to square.")
+
+	flag.StringVar(
+		&params.Brush,
+		"brush",
+		"",
+		"Specify an RLE or .cells pattern file to stamp with the right mouse button while paused, turned with 'r' and mirrored with 'f'. Defaults to a glider.")
+
+	flag.StringVar(
+		&params.Theme,
+		"theme",
+		"classic",
+		"Specify the colour scheme cells are shown and exported in: classic, ocean, fire or paper, cycled through with 't'. Defaults to classic.")
+
+	flag.BoolVar(
+		&params.Ages,
+		"age",
+		false,
+		"Specify if cells should be coloured by the number of turns since they last changed state instead of by state, toggled with 'a'. Defaults to false.")
+
+	sessionFile := flag.String(
+		"session",
+		"",
+		"Specify a session file saved with 'e' to carry on from, on the turn it was saved on and with the params it was saved with, apart from -turns, the number of threads, -outdir and -template if they're given. Disabled if empty.")
+
+	flag.StringVar(
+		&params.Input,
+		"input",
+		"",
+		"Specify the PGM image to start from, whose size is read from its header unless -w and -h are given. Defaults to images/<width>x<height>.pgm.")
+
+	flag.StringVar(
+		&params.OutDir,
+		"outdir",
+		"out",
+		"Specify the directory images, heat maps and sessions are written to. Defaults to out.")
+
+	flag.StringVar(
+		&params.OutputTemplate,
+		"template",
+		"",
+		"Specify the name boards are output under, where {turn}, {width}, {height}, {depth}, {rule} and {time} are filled in, e.g. {rule}-{width}x{height}-t{turn}. Defaults to <height>x<width>x<turn>.")
+
 	flag.Parse()
+	params.HistoryBudget = *historyMB * 1024 * 1024
+	params.Seed = soupParams.Seed
+	if params.Input != "" {
+		if err := inputSize(&params); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	if err := gol.CheckTemplate(params.OutputTemplate); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if *sessionFile != "" {
+		loaded, err := gol.LoadSession(*sessionFile)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "turns":
+				loaded.Turns = params.Turns
+			case "t", "workers":
+				loaded.Threads = params.Threads
+			case "outdir":
+				loaded.OutDir = params.OutDir
+			case "template":
+				loaded.OutputTemplate = params.OutputTemplate
+			}
+		})
+		params = loaded
+	}
+	r, err := rule.Parse(params.Rule)
+	if err == nil {
+		r, err = rule.InVolume(r, params.ImageHeight, params.ImageDepth)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	topology, err := rule.ParseTopology(params.Topology)
+	if err == nil {
+		err = topology.Check(params.ImageWidth, params.ImageHeight)
+	}
+	if err == nil {
+		_, err = rule.OnTopology(r, topology)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if _, err := theme.Parse(params.Theme); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Soup searches are run entirely on the engine and its workers, so there's nothing to show in the window
+	if soupParams.Soups > 0 {
+		soupParams.Workers = params.Threads
+		report, err := gol.SearchSoups(soupParams)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Print(report)
+		return
+	}
 
 	fmt.Println("Threads:", params.Threads)
 	fmt.Println("Width:", params.ImageWidth)
 	fmt.Println("Height:", params.ImageHeight)
+	if params.ImageDepth > 1 {
+		fmt.Println("Depth:", params.ImageDepth)
+	}
 	fmt.Println("Reconnect:", params.Reconnect)
+	fmt.Println("Observer:", params.Observer)
 
 	keyPresses := make(chan rune, 10)
 	events := make(chan gol.Event, 1000)
+	edits := make(chan gol.SetCells, 10)
+
+	gol.RunEditable(params, events, keyPresses, edits)
+	sdl.Start(params, events, keyPresses, edits)
+}
 
-	gol.Run(params, events, keyPresses)
-	sdl.Start(params, events, keyPresses)
+// inputSize sets the size of the world to the size of the input image, checking it against -w and -h if they're given.
+// The height of a volume is the height of the image over its depth.
+func inputSize(params *gol.Params) error {
+	width, height, err := gol.ImageSize(params.Input)
+	if err != nil {
+		return err
+	}
+	if params.ImageDepth > 1 {
+		if height%params.ImageDepth != 0 {
+			return fmt.Errorf("%v is %v rows high, which isn't %v slices", params.Input, height, params.ImageDepth)
+		}
+		height /= params.ImageDepth
+	}
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	if given["w"] && params.ImageWidth != width || given["h"] && params.ImageHeight != height {
+		return fmt.Errorf("%v is %vx%v, but -w and -h give %vx%v", params.Input, width, height, params.ImageWidth, params.ImageHeight)
+	}
+	params.ImageWidth, params.ImageHeight = width, height
+	return nil
 }
@@ -0,0 +1,201 @@
+// Package pattern reads patterns from RLE and plaintext .cells files, so they can be stamped onto a board, and turns
+// them round and over.
+package pattern
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Pattern is a rectangle of cells, row by row, where true is alive.
+type Pattern [][]bool
+
+// Load reads a pattern from a file, as RLE if it ends in .rle and as plaintext if it ends in .cells.
+func Load(path string) (Pattern, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".rle":
+		return ParseRLE(file)
+	case ".cells":
+		return ParseCells(file)
+	}
+	return nil, fmt.Errorf("%v is not a pattern, which are .rle or .cells files", path)
+}
+
+// ParseRLE reads a pattern in run length encoding, e.g. bo$2bo$3o! for a glider. Lines starting with # are comments,
+// and the size comes from the header line, e.g. x = 3, y = 3, growing to fit the cells if they don't.
+func ParseRLE(r io.Reader) (Pattern, error) {
+	scanner := bufio.NewScanner(r)
+	var width, height int
+	var body strings.Builder
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "x"):
+			for _, field := range strings.Split(line, ",") {
+				option := strings.SplitN(field, "=", 2)
+				if len(option) != 2 {
+					continue
+				}
+				n, err := strconv.Atoi(strings.TrimSpace(option[1]))
+				switch strings.TrimSpace(option[0]) {
+				case "x":
+					width = n
+				case "y":
+					height = n
+				default:
+					continue
+				}
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("%q is not the size of a pattern", line)
+				}
+			}
+		default:
+			body.WriteString(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var rows [][]bool
+	row := []bool{}
+	run := 0
+	for _, c := range body.String() {
+		switch {
+		case c >= '0' && c <= '9':
+			run = run*10 + int(c-'0')
+			continue
+		case c == '!':
+			rows = append(rows, row)
+			return fill(rows, width, height), nil
+		}
+		if run == 0 {
+			run = 1
+		}
+		switch c {
+		case 'b', '.':
+			row = append(row, make([]bool, run)...)
+		case '$':
+			rows = append(rows, row)
+			for i := 1; i < run; i++ {
+				rows = append(rows, nil)
+			}
+			row = []bool{}
+		default:
+			// Every other letter is a state of a multistate rule, which are alive on a board of dead and alive cells
+			if c != 'o' && (c < 'A' || c > 'Z') {
+				return nil, fmt.Errorf("%q is not a cell of an RLE pattern", c)
+			}
+			for i := 0; i < run; i++ {
+				row = append(row, true)
+			}
+		}
+		run = 0
+	}
+	return nil, fmt.Errorf("RLE pattern doesn't end with !")
+}
+
+// ParseCells reads a pattern in plaintext, with a line for each row, . for dead cells and O for alive ones. Lines
+// starting with ! are comments.
+func ParseCells(r io.Reader) (Pattern, error) {
+	scanner := bufio.NewScanner(r)
+	var rows [][]bool
+	width := 0
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.HasPrefix(line, "!") {
+			continue
+		}
+		row := make([]bool, len(line))
+		for x, c := range line {
+			switch c {
+			case 'O', 'o', '*':
+				row[x] = true
+			case '.':
+			default:
+				return nil, fmt.Errorf("%q is not a cell of a plaintext pattern", c)
+			}
+		}
+		rows = append(rows, row)
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return fill(rows, width, len(rows)), nil
+}
+
+// fill pads the rows of a pattern with dead cells to make a rectangle of at least width by height cells.
+func fill(rows [][]bool, width, height int) Pattern {
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	for len(rows) < height {
+		rows = append(rows, nil)
+	}
+	p := make(Pattern, len(rows))
+	for y, row := range rows {
+		p[y] = make([]bool, width)
+		copy(p[y], row)
+	}
+	return p
+}
+
+// Width gets the number of cells in each row of the pattern.
+func (p Pattern) Width() int {
+	if len(p) == 0 {
+		return 0
+	}
+	return len(p[0])
+}
+
+// Height gets the number of rows of the pattern.
+func (p Pattern) Height() int {
+	return len(p)
+}
+
+// Rotate gets the pattern turned a quarter clockwise.
+func (p Pattern) Rotate() Pattern {
+	rotated := make(Pattern, p.Width())
+	for y := range rotated {
+		rotated[y] = make([]bool, p.Height())
+		for x := range rotated[y] {
+			rotated[y][x] = p[p.Height()-1-x][y]
+		}
+	}
+	return rotated
+}
+
+// Flip gets the pattern mirrored left to right.
+func (p Pattern) Flip() Pattern {
+	flipped := make(Pattern, p.Height())
+	for y := range flipped {
+		flipped[y] = make([]bool, p.Width())
+		for x := range flipped[y] {
+			flipped[y][x] = p[y][p.Width()-1-x]
+		}
+	}
+	return flipped
+}
+
+// Glider is the smallest spaceship, moving one cell down and to the right every 4 turns.
+var Glider = Pattern{
+	{false, true, false},
+	{false, false, true},
+	{true, true, true},
+}
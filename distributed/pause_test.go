@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+// TestPause pauses the engine, saves a snapshot and checks the alive cells while paused, then resumes and checks that turns are computed again.
+func TestPause(t *testing.T) {
+	p := gol.Params{
+		Turns:       5000,
+		Threads:     2,
+		ImageWidth:  64,
+		ImageHeight: 64,
+	}
+	alive := readAliveCounts(p.ImageWidth, p.ImageHeight)
+	events := make(chan gol.Event, 1000)
+	keyPresses := make(chan rune, 10)
+	gol.Run(p, events, keyPresses)
+
+	keyPresses <- 'p'
+	pausedOn := awaitEvent(t, events, func(e gol.Event) bool {
+		stateChange, ok := e.(gol.StateChange)
+		return ok && stateChange.NewState == gol.Paused
+	}).GetCompletedTurns()
+
+	keyPresses <- 's'
+	snapshot := awaitEvent(t, events, func(e gol.Event) bool {
+		_, ok := e.(gol.ImageOutputComplete)
+		return ok
+	})
+	if snapshot.GetCompletedTurns() != pausedOn {
+		t.Fatalf("Snapshot taken while paused on turn %v was of turn %v", pausedOn, snapshot.GetCompletedTurns())
+	}
+
+	count := awaitEvent(t, events, func(e gol.Event) bool {
+		_, ok := e.(gol.AliveCellsCount)
+		return ok
+	}).(gol.AliveCellsCount)
+	if count.CompletedTurns != pausedOn {
+		t.Fatalf("Turns kept being computed while paused on turn %v, now on turn %v", pausedOn, count.CompletedTurns)
+	}
+	if expected, ok := alive[count.CompletedTurns]; ok && count.CellsCount != expected {
+		t.Fatalf("At turn %v expected %v alive cells, got %v instead", count.CompletedTurns, expected, count.CellsCount)
+	}
+
+	keyPresses <- 'p'
+	resumedOn := awaitEvent(t, events, func(e gol.Event) bool {
+		stateChange, ok := e.(gol.StateChange)
+		return ok && stateChange.NewState == gol.Executing
+	}).GetCompletedTurns()
+	if resumedOn != pausedOn {
+		t.Fatalf("Paused on turn %v but resumed on turn %v", pausedOn, resumedOn)
+	}
+
+	// Pausing again straight away doesn't wait for the ticker, which a fast engine can finish the run before
+	keyPresses <- 'p'
+	pausedAgainOn := awaitEvent(t, events, func(e gol.Event) bool {
+		stateChange, ok := e.(gol.StateChange)
+		return ok && stateChange.NewState == gol.Paused
+	}).GetCompletedTurns()
+	if pausedAgainOn <= resumedOn {
+		t.Fatalf("No turns computed after resuming on turn %v", resumedOn)
+	}
+	keyPresses <- 'p'
+
+	for event := range events {
+		switch e := event.(type) {
+		case gol.FinalTurnComplete:
+			if e.CompletedTurns != p.Turns {
+				t.Fatalf("Expected %v completed turns, got %v", p.Turns, e.CompletedTurns)
+			}
+		}
+	}
+}
+
+// TestStep pauses the engine, steps one turn and then p.StepTurns turns, checking the alive cells after each step.
+func TestStep(t *testing.T) {
+	p := gol.Params{
+		Turns:       5000,
+		Threads:     4,
+		ImageWidth:  64,
+		ImageHeight: 64,
+		StepTurns:   10,
+	}
+	alive := readAliveCounts(p.ImageWidth, p.ImageHeight)
+	events := make(chan gol.Event, 1000)
+	keyPresses := make(chan rune, 10)
+	gol.Run(p, events, keyPresses)
+
+	keyPresses <- 'p'
+	pausedOn := awaitEvent(t, events, func(e gol.Event) bool {
+		stateChange, ok := e.(gol.StateChange)
+		return ok && stateChange.NewState == gol.Paused
+	}).GetCompletedTurns()
+
+	for _, step := range []struct {
+		key   rune
+		turns int
+	}{{'n', 1}, {'m', p.StepTurns}, {'n', 1}} {
+		keyPresses <- step.key
+		turnComplete := awaitEvent(t, events, func(e gol.Event) bool {
+			_, ok := e.(gol.TurnComplete)
+			return ok
+		})
+		if turnComplete.GetCompletedTurns() != pausedOn+step.turns {
+			t.Fatalf("Stepping %v turns from turn %v ended on turn %v", step.turns, pausedOn, turnComplete.GetCompletedTurns())
+		}
+		count := awaitEvent(t, events, func(e gol.Event) bool {
+			_, ok := e.(gol.AliveCellsCount)
+			return ok
+		}).(gol.AliveCellsCount)
+		if count.CellsCount != alive[count.CompletedTurns] {
+			t.Fatalf("At turn %v expected %v alive cells, got %v instead", count.CompletedTurns, alive[count.CompletedTurns], count.CellsCount)
+		}
+		pausedOn = turnComplete.GetCompletedTurns()
+	}
+
+	keyPresses <- 'p'
+	for range events {
+	}
+}
+
+// TestRewind pauses the engine, steps forward and rewinds with 'b', 'v' and 'g', checking the alive cells of every retained turn it goes back to.
+func TestRewind(t *testing.T) {
+	p := gol.Params{
+		Turns:         1000,
+		Threads:       4,
+		ImageWidth:    64,
+		ImageHeight:   64,
+		StepTurns:     10,
+		HistoryBudget: 1024 * 1024,
+	}
+	alive := readAliveCounts(p.ImageWidth, p.ImageHeight)
+	events := make(chan gol.Event, 1000)
+	keyPresses := make(chan rune, 10)
+	gol.Run(p, events, keyPresses)
+
+	keyPresses <- 'p'
+	pausedOn := awaitEvent(t, events, func(e gol.Event) bool {
+		stateChange, ok := e.(gol.StateChange)
+		return ok && stateChange.NewState == gol.Paused
+	}).GetCompletedTurns()
+
+	// Make sure there are enough turns to go back through
+	keyPresses <- 'm'
+	awaitEvent(t, events, func(e gol.Event) bool {
+		count, ok := e.(gol.AliveCellsCount)
+		return ok && count.CompletedTurns == pausedOn+p.StepTurns
+	})
+	pausedOn += p.StepTurns
+
+	for _, rewind := range []struct {
+		key   rune
+		turns int
+	}{{'b', 1}, {'v', p.StepTurns}, {'n', -1}, {'b', 1}, {'g', pausedOn}} {
+		keyPresses <- rewind.key
+		target := pausedOn - rewind.turns
+		if rewind.key == 'g' || target < 0 {
+			target = 0 // nothing is evicted from the history, so everything back to the initial turn is retained
+		}
+		count := awaitEvent(t, events, func(e gol.Event) bool {
+			count, ok := e.(gol.AliveCellsCount)
+			return ok && count.CompletedTurns == target
+		}).(gol.AliveCellsCount)
+		if expected, ok := alive[count.CompletedTurns]; ok && count.CellsCount != expected {
+			t.Fatalf("Rewound to turn %v and expected %v alive cells, got %v instead", count.CompletedTurns, expected, count.CellsCount)
+		}
+		pausedOn = count.CompletedTurns
+	}
+
+	keyPresses <- 'p'
+	for event := range events {
+		switch e := event.(type) {
+		case gol.FinalTurnComplete:
+			if len(e.Alive) != alive[e.CompletedTurns] {
+				t.Fatalf("After rewinding, expected %v alive cells on the final turn %v, got %v instead", alive[e.CompletedTurns], e.CompletedTurns, len(e.Alive))
+			}
+		}
+	}
+}
+
+// TestRewindGenerations rewinds a Brian's Brain spaceship on the 8x8 image, which moves one cell to the right every
+// turn, and checks the snapshot of the turn it's rewound to is the starting image shifted along, dying cells and all.
+func TestRewindGenerations(t *testing.T) {
+	p := gol.Params{
+		Turns:         20000,
+		Threads:       3,
+		ImageWidth:    8,
+		ImageHeight:   8,
+		Rule:          "B2/S/C3",
+		StepTurns:     10,
+		HistoryBudget: 1024 * 1024,
+	}
+	initial := readPgm(t, "images/8x8.pgm")
+	events := make(chan gol.Event, 1000)
+	keyPresses := make(chan rune, 10)
+	gol.Run(p, events, keyPresses)
+
+	keyPresses <- 'p'
+	pausedOn := awaitEvent(t, events, func(e gol.Event) bool {
+		stateChange, ok := e.(gol.StateChange)
+		return ok && stateChange.NewState == gol.Paused
+	}).GetCompletedTurns()
+	keyPresses <- 'm'
+	awaitEvent(t, events, func(e gol.Event) bool {
+		count, ok := e.(gol.AliveCellsCount)
+		return ok && count.CompletedTurns == pausedOn+p.StepTurns
+	})
+	keyPresses <- 'v'
+	awaitEvent(t, events, func(e gol.Event) bool {
+		count, ok := e.(gol.AliveCellsCount)
+		return ok && count.CompletedTurns == pausedOn
+	})
+	keyPresses <- 's'
+	snapshot := awaitEvent(t, events, func(e gol.Event) bool {
+		_, ok := e.(gol.ImageOutputComplete)
+		return ok
+	}).(gol.ImageOutputComplete)
+
+	output := readPgm(t, "out/"+snapshot.Filename+".pgm")
+	for y := range initial {
+		expected := make([]byte, p.ImageWidth)
+		for x := range expected {
+			expected[(x+pausedOn)%p.ImageWidth] = initial[y][x]
+		}
+		if !bytes.Equal(output[y], expected) {
+			t.Errorf("Row %v after rewinding to turn %v is %v, expected %v", y, pausedOn, output[y], expected)
+		}
+	}
+	keyPresses <- 'p'
+	for range events {
+	}
+}
+
+// TestRewindWireworld steps forward and rewinds back to where a Wireworld circuit was paused, and checks the board is
+// the same as it was, with its electron tails and conductors kept rather than turned dead.
+func TestRewindWireworld(t *testing.T) {
+	p := gol.Params{
+		Turns:         10000000,
+		Threads:       3,
+		ImageWidth:    32,
+		ImageHeight:   32,
+		Rule:          "Wireworld",
+		StepTurns:     10,
+		HistoryBudget: 1024 * 1024,
+	}
+	events := make(chan gol.Event, 1000)
+	keyPresses := make(chan rune, 10)
+	gol.Run(p, events, keyPresses)
+	snapshot := func() [][]byte {
+		keyPresses <- 's'
+		output := awaitEvent(t, events, func(e gol.Event) bool {
+			_, ok := e.(gol.ImageOutputComplete)
+			return ok
+		}).(gol.ImageOutputComplete)
+		return readPgm(t, "out/"+output.Filename+".pgm")
+	}
+
+	keyPresses <- 'p'
+	pausedOn := awaitEvent(t, events, func(e gol.Event) bool {
+		stateChange, ok := e.(gol.StateChange)
+		return ok && stateChange.NewState == gol.Paused
+	}).GetCompletedTurns()
+	paused := snapshot()
+	keyPresses <- 'm'
+	awaitEvent(t, events, func(e gol.Event) bool {
+		count, ok := e.(gol.AliveCellsCount)
+		return ok && count.CompletedTurns == pausedOn+p.StepTurns
+	})
+	keyPresses <- 'v'
+	awaitEvent(t, events, func(e gol.Event) bool {
+		count, ok := e.(gol.AliveCellsCount)
+		return ok && count.CompletedTurns == pausedOn
+	})
+	rewound := snapshot()
+
+	for y := range paused {
+		if !bytes.Equal(rewound[y], paused[y]) {
+			t.Errorf("Row %v after rewinding to turn %v is %v, expected %v", y, pausedOn, rewound[y], paused[y])
+		}
+	}
+	keyPresses <- 'q'
+	for range events {
+	}
+}
+
+// TestPauseFinished pauses, resumes and stops a session that has already finished, which nothing receives commands for
+// any more, and checks the engine answers straight away instead of blocking.
+func TestPauseFinished(t *testing.T) {
+	settleEngine(t)
+	client, err := gol.DefaultTransport.Dial(gol.ServerAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	world := [][]byte{make([]byte, 16), make([]byte, 16), make([]byte, 16), make([]byte, 16)}
+	start := new(stubs.ResponseStart)
+	if err := client.Call(stubs.GameOfLifeHandler, stubs.RequestStart{World: world, Turns: 5, NumWorkers: 1}, start); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(stubs.ResultsHandler, stubs.RequestResult{}, new(stubs.ResponseResult)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, call := range []struct {
+		method      string
+		args, reply interface{}
+		fails       bool
+	}{
+		{stubs.PauseHandler, stubs.RequestPause{ControllerID: start.ControllerID}, new(stubs.ResponsePause), true},
+		{stubs.ResumeHandler, stubs.RequestResume{ControllerID: start.ControllerID}, new(stubs.ResponseResume), true},
+		{stubs.StopWorkersHandler, stubs.RequestStopWorkers{ControllerID: start.ControllerID}, new(stubs.ResponseStopWorkers), true},
+		{stubs.StopHandler, stubs.RequestStop{ControllerID: start.ControllerID}, new(stubs.ResponseStop), false},
+	} {
+		done := make(chan error, 1)
+		go func() { done <- client.Call(call.method, call.args, call.reply) }()
+		select {
+		case err := <-done:
+			if (err != nil) != call.fails {
+				t.Errorf("%v on a finished session gave the error %v", call.method, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%v on a finished session didn't answer in 5 seconds", call.method)
+		}
+	}
+}
+
+// awaitEvent returns the first event matching the condition, failing if none is received within 10 seconds.
+func awaitEvent(t *testing.T, events <-chan gol.Event, condition func(gol.Event) bool) gol.Event {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatal("events channel closed before the expected event was received")
+			}
+			if condition(event) {
+				return event
+			}
+		case <-timeout:
+			t.Fatal("expected event not received in 10 seconds")
+		}
+	}
+}
+
+// TestQuitFinishing presses 'q' at about the time short runs finish, and checks the controller ends the run whether it
+// sees the key press or the results first.
+func TestQuitFinishing(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		events := make(chan gol.Event, 1000)
+		keyPresses := make(chan rune, 10)
+		gol.Run(gol.Params{Turns: 1, Threads: 1, ImageWidth: 16, ImageHeight: 16}, events, keyPresses)
+		time.Sleep(time.Duration(i%20) * 100 * time.Microsecond)
+		keyPresses <- 'q'
+		closed := make(chan bool)
+		go func() {
+			for range events {
+			}
+			close(closed)
+		}()
+		select {
+		case <-closed:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("The controller didn't end the run after 'q' was pressed %v after it started", time.Duration(i%20)*100*time.Microsecond)
+		}
+	}
+}
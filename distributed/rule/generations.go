@@ -0,0 +1,364 @@
+package rule
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Generations is a life-like rule with a number of states: a dead cell is born if its neighbours are in the birth conditions,
+// an alive cell stays alive if they're in the survival conditions, and otherwise decays through States-2 refractory
+// states before dying.
+//
+// Rules in B/S/C notation have range 1, the 8 cells round the cell, and their conditions are in Table, indexed by the
+// 3x3 block round the cell as Neighbourhood gets it, so they can depend on where the neighbours are as well as how
+// many there are.
+//
+// Larger than Life rules have no table. Their conditions are in Birth and Survive, indexed by the number of alive
+// neighbours, which are counted in the Moore neighbourhood of range Range, the (2R+1)x(2R+1) square round the cell, or
+// in the von Neumann neighbourhood, the cells within R steps of it, with the cell itself counted as well if Middle is
+// set.
+//
+// Rules put on a hex or triangular lattice with OnTopology count their neighbours on the lattice instead, and their
+// conditions are in Birth and Survive too.
+type Generations struct {
+	Table      []bool
+	Birth      []bool
+	Survive    []bool
+	States     int
+	Range      int
+	VonNeumann bool
+	Middle     bool
+	Topology   Topology
+}
+
+// MaxRange is the largest neighbourhood range a Larger than Life rule can have.
+const MaxRange = 50
+
+// Life is Conway's Game of Life, B3/S23.
+var Life = Generations{Table: lifeTable(), States: 2, Range: 1}
+
+func lifeTable() []bool {
+	table := make([]bool, 1<<9)
+	parseHensel("3", false, table)
+	parseHensel("23", true, table)
+	return table
+}
+
+// ParseGenerations parses a rule in B/S/C notation, e.g. B3/S23 for Life or B2/S/C3 for Brian's Brain. The C part is
+// optional and defaults to 2 states. Numbers of neighbours can be followed by the letters of Hensel's isotropic
+// non-totalistic notation, e.g. B2n3/S23-q. The numeric S/B/C form, e.g. 345/2/4 for Star Wars, is accepted as well,
+// and so are Larger than Life rules such as R5,C0,M1,S34..58,B34..45,NM for Bosco's rule. An empty rule is Life.
+func ParseGenerations(notation string) (Generations, error) {
+	if notation == "" {
+		return Life, nil
+	}
+	if len(notation) > 1 && (notation[0] == 'R' || notation[0] == 'r') && strings.Contains(notation, ",") {
+		return parseLtL(notation)
+	}
+	g := Generations{Table: make([]bool, 1<<9), States: 2, Range: 1}
+	parts := strings.Split(notation, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return g, fmt.Errorf("rule %q should be in B/S/C notation", notation)
+	}
+	if d := notation[0]; d == '/' || d >= '0' && d <= '9' {
+		// S/B/C, so label the parts the same way as B/S/C
+		parts[0], parts[1] = "S"+parts[0], "B"+parts[1]
+		if len(parts) == 3 {
+			parts[2] = "C" + parts[2]
+		}
+	}
+	for _, part := range parts {
+		if part == "" {
+			return g, fmt.Errorf("rule %q has an empty part", notation)
+		}
+		digits := part[1:]
+		switch part[0] {
+		case 'B', 'b':
+			if err := parseHensel(digits, false, g.Table); err != nil {
+				return g, fmt.Errorf("rule %q: %v", notation, err)
+			}
+		case 'S', 's':
+			if err := parseHensel(digits, true, g.Table); err != nil {
+				return g, fmt.Errorf("rule %q: %v", notation, err)
+			}
+		case 'C', 'c', 'G', 'g':
+			states, err := strconv.Atoi(digits)
+			if err != nil || states < 2 || states > 256 {
+				return g, fmt.Errorf("rule %q: the number of states must be between 2 and 256", notation)
+			}
+			g.States = states
+		default:
+			return g, fmt.Errorf("rule %q should be in B/S/C notation", notation)
+		}
+	}
+	return g, nil
+}
+
+// parseLtL parses a rule in LtL notation: Rr for the range, Cc for the number of states (0 meaning 2), M1 to count
+// the cell itself, Smin..max and Bmin..max for the survival and birth ranges, and NM or NN for a Moore or von Neumann
+// neighbourhood. Parts that are left out take the values of Life.
+func parseLtL(notation string) (Generations, error) {
+	g := Generations{States: 2, Range: 1}
+	var survive, birth string
+	for _, part := range strings.Split(strings.ToUpper(notation), ",") {
+		if part == "" {
+			return g, fmt.Errorf("rule %q has an empty part", notation)
+		}
+		value := part[1:]
+		switch part[0] {
+		case 'R':
+			r, err := strconv.Atoi(value)
+			if err != nil || r < 1 || r > MaxRange {
+				return g, fmt.Errorf("rule %q: the range must be between 1 and %d", notation, MaxRange)
+			}
+			g.Range = r
+		case 'C':
+			states, err := strconv.Atoi(value)
+			if err != nil || states < 0 || states > 256 {
+				return g, fmt.Errorf("rule %q: the number of states must be between 2 and 256", notation)
+			}
+			if states > 2 {
+				g.States = states
+			}
+		case 'M':
+			if value != "0" && value != "1" {
+				return g, fmt.Errorf("rule %q: M must be 0 or 1", notation)
+			}
+			g.Middle = value == "1"
+		case 'S':
+			survive = value
+		case 'B':
+			birth = value
+		case 'N':
+			switch value {
+			case "M":
+				g.VonNeumann = false
+			case "N":
+				g.VonNeumann = true
+			default:
+				return g, fmt.Errorf("rule %q: the neighbourhood must be NM or NN", notation)
+			}
+		default:
+			return g, fmt.Errorf("rule %q should be in LtL notation", notation)
+		}
+	}
+	// The ranges can only be checked once the size of the neighbourhood is known
+	size := g.Size()
+	var err error
+	if g.Survive, err = parseRange(survive, size); err != nil {
+		return g, fmt.Errorf("rule %q: %v", notation, err)
+	}
+	if g.Birth, err = parseRange(birth, size); err != nil {
+		return g, fmt.Errorf("rule %q: %v", notation, err)
+	}
+	return g, nil
+}
+
+// parseRange marks the numbers of neighbours from min to max, given as min..max or a single number, out of size.
+func parseRange(value string, size int) ([]bool, error) {
+	counts := make([]bool, size+1)
+	if value == "" {
+		return counts, nil
+	}
+	from, to := value, value
+	if i := strings.Index(value, ".."); i >= 0 {
+		from, to = value[:i], value[i+2:]
+	}
+	min, err1 := strconv.Atoi(from)
+	max, err2 := strconv.Atoi(to)
+	if err1 != nil || err2 != nil || min < 0 || min > max || max > size {
+		return nil, fmt.Errorf("%q is not a range of neighbours between 0 and %d", value, size)
+	}
+	for n := min; n <= max; n++ {
+		counts[n] = true
+	}
+	return counts, nil
+}
+
+// Spec gets the name of the rule and its notation.
+func (g Generations) Spec() Spec {
+	return Spec{Name: "generations", Params: g.String()}
+}
+
+// Halo gets the number of rows a strip of the world needs above and below it to count its neighbours.
+func (g Generations) Halo() int {
+	if g.Range < 1 {
+		return 1
+	}
+	return g.Range
+}
+
+// Size gets the number of cells in the neighbourhood, including the cell itself if the rule counts it.
+func (g Generations) Size() int {
+	r := g.Halo()
+	size := (2*r+1)*(2*r+1) - 1
+	if g.VonNeumann {
+		size = 2 * r * (r + 1)
+	}
+	if g.Middle {
+		size++
+	}
+	return size
+}
+
+// String writes the rule in B/S/C notation, leaving out the C part for rules with two states, or in LtL notation if
+// it's a Larger than Life rule.
+func (g Generations) String() string {
+	var b strings.Builder
+	if g.Table == nil {
+		states, middle, neighbourhood := g.States, 0, "M"
+		if states <= 2 {
+			states = 0
+		}
+		if g.Middle {
+			middle = 1
+		}
+		if g.VonNeumann {
+			neighbourhood = "N"
+		}
+		fmt.Fprintf(&b, "R%d,C%d,M%d,S%s,B%s,N%s", g.Halo(), states, middle,
+			formatRange(g.Survive), formatRange(g.Birth), neighbourhood)
+		return b.String()
+	}
+	b.WriteString("B")
+	b.WriteString(formatHensel(g.Table, false))
+	b.WriteString("/S")
+	b.WriteString(formatHensel(g.Table, true))
+	if g.States > 2 {
+		fmt.Fprintf(&b, "/C%d", g.States)
+	}
+	return b.String()
+}
+
+// formatRange writes the numbers of neighbours that are set as min..max.
+func formatRange(counts []bool) string {
+	min, max := -1, -1
+	for n, ok := range counts {
+		if ok {
+			if min < 0 {
+				min = n
+			}
+			max = n
+		}
+	}
+	switch {
+	case min < 0:
+		return ""
+	case min == max:
+		return strconv.Itoa(min)
+	}
+	return fmt.Sprintf("%d..%d", min, max)
+}
+
+// Level gets the grey level of a state: 0 for dead, 255 for alive, and evenly spaced levels going down from 255
+// for the refractory states 2 to States-1.
+func (g Generations) Level(state int) byte {
+	switch {
+	case state <= 0:
+		return dead
+	case state == 1:
+		return alive
+	}
+	return byte(alive - (state-1)*(alive/(g.States-1)))
+}
+
+// State gets the state of the grey level nearest to the given one, so images from elsewhere can be read in.
+func (g Generations) State(level byte) int {
+	if level == dead {
+		return 0
+	}
+	step := alive / (g.States - 1)
+	state := 1 + (alive-int(level)+step/2)/step
+	if state >= g.States {
+		// Closer to dead than to the last refractory state
+		if int(level) < step/2 {
+			return 0
+		}
+		state = g.States - 1
+	}
+	return state
+}
+
+// Levels gets the grey level of each state, from dead to alive and then the refractory states.
+func (g Generations) Levels() []byte {
+	levels := make([]byte, g.States)
+	for state := range levels {
+		levels[state] = g.Level(state)
+	}
+	return levels
+}
+
+// Colour shows cells in their grey level.
+func (g Generations) Colour(level byte) color.RGBA {
+	return color.RGBA{level, level, level, 255}
+}
+
+// Quantise snaps a grey level onto the level of the nearest state.
+func (g Generations) Quantise(level byte) byte {
+	return g.Level(g.State(level))
+}
+
+// Step computes a turn of the rows of a strip between its halo rows. Rules in B/S/C notation look the 3x3 block round
+// each cell up in their table, and Larger than Life rules count every neighbour at once from summed-area tables.
+// Only alive cells count as neighbours, cells in refractory states just decay.
+func (g Generations) Step(strip [][]byte, top, turn int) [][]byte {
+	halo := g.Halo()
+	var neighbours [][]int
+	if g.Table == nil {
+		neighbours = g.Neighbours(strip)
+	}
+	next := make([][]byte, len(strip)-2*halo)
+	for y := range next {
+		next[y] = make([]byte, len(strip[0]))
+		for x := range next[y] {
+			level := strip[y+halo][x]
+			if g.Topology != Square {
+				next[y][x] = g.Next(level, g.Topology.count(strip, x, y+halo, top+y))
+			} else if g.Table != nil {
+				next[y][x] = g.Lookup(level, Neighbourhood(strip, x, y+halo))
+			} else {
+				next[y][x] = g.Next(level, neighbours[y][x])
+			}
+		}
+	}
+	return next
+}
+
+// Next gets the grey level of a cell after a turn of a Larger than Life rule, given its grey level and its number of
+// alive neighbours.
+func (g Generations) Next(level byte, neighbours int) byte {
+	counts := g.Birth
+	if level == alive {
+		counts = g.Survive
+	}
+	return g.next(level, neighbours < len(counts) && counts[neighbours])
+}
+
+// Lookup gets the grey level of a cell after a turn of a rule in B/S/C notation, given its grey level and its
+// neighbourhood.
+func (g Generations) Lookup(level byte, neighbourhood int) byte {
+	return g.next(level, g.Table[neighbourhood])
+}
+
+// next gets the grey level of a cell after a turn, given whether its neighbours meet the birth or survival conditions.
+func (g Generations) next(level byte, met bool) byte {
+	switch level {
+	case dead:
+		if met {
+			return alive
+		}
+		return dead
+	case alive:
+		if met {
+			return alive
+		}
+		return g.Level(2 % g.States)
+	}
+	state := g.State(level) + 1
+	if state >= g.States {
+		return dead
+	}
+	return g.Level(state)
+}
@@ -0,0 +1,183 @@
+package rule
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A neighbourhood is the 3x3 block round a cell as a 9-bit number, with the cell at (x, y) of the block in bit 3y+x,
+// so the cell itself is bit 4 and the 8 cells round it are the other bits.
+const (
+	centre = 1 << 4
+	ring   = 1<<9 - 1 - centre
+)
+
+// henselLetters are the letters of Hensel's notation for each number of neighbours up to 4, in the order they're
+// written in. The arrangements of 5 to 8 neighbours take the letters of their complements.
+var henselLetters = [5]string{"", "ce", "ceaikn", "ceaiknjqry", "ceaiknjqrytwz"}
+
+// henselShapes are an arrangement of neighbours for each of the letters, in the same order, which stands for every
+// arrangement it can be rotated or reflected into.
+var henselShapes = [5][]int{
+	{0},
+	{1, 2},
+	{5, 10, 3, 40, 33, 68},
+	{69, 42, 11, 7, 98, 13, 14, 70, 41, 97},
+	{325, 170, 15, 45, 99, 71, 106, 102, 43, 101, 105, 78, 108},
+}
+
+// class is the set of arrangements of neighbours that a letter stands for.
+type class struct {
+	letter byte
+	shapes []int
+}
+
+// classes holds the letters for every number of neighbours from 0 to 8, with no letter for 0 and 8 neighbours.
+var classes = buildClasses()
+
+func buildClasses() [9][]class {
+	var classes [9][]class
+	for n, shapes := range henselShapes {
+		for i, shape := range shapes {
+			c := class{shapes: symmetries(shape)}
+			if n > 0 {
+				c.letter = henselLetters[n][i]
+			}
+			classes[n] = append(classes[n], c)
+			if n < 4 {
+				complement := class{letter: c.letter}
+				for _, s := range c.shapes {
+					complement.shapes = append(complement.shapes, ring&^s)
+				}
+				classes[8-n] = append(classes[8-n], complement)
+			}
+		}
+	}
+	return classes
+}
+
+// symmetries gets every arrangement of neighbours that an arrangement can be rotated or reflected into.
+func symmetries(shape int) []int {
+	var shapes []int
+	seen := map[int]bool{}
+	for turn := 0; turn < 8; turn++ {
+		s := 0
+		for bit := 0; bit < 9; bit++ {
+			if shape&(1<<uint(bit)) == 0 {
+				continue
+			}
+			x, y := bit%3-1, bit/3-1
+			for i := 0; i < turn%4; i++ {
+				x, y = -y, x
+			}
+			if turn >= 4 {
+				x = -x
+			}
+			s |= 1 << uint((y+1)*3+x+1)
+		}
+		if !seen[s] {
+			seen[s] = true
+			shapes = append(shapes, s)
+		}
+	}
+	return shapes
+}
+
+// parseHensel marks the arrangements of neighbours given by a B or S part in the table, for cells that are alive if
+// alive is set and dead otherwise. Each number of neighbours can be followed by letters to only take those
+// arrangements, or by a minus and letters to take every arrangement but those, e.g. 2n3 or 23-q.
+func parseHensel(part string, alive bool, table []bool) error {
+	cell := 0
+	if alive {
+		cell = centre
+	}
+	for i := 0; i < len(part); {
+		d := part[i]
+		if d < '0' || d > '8' {
+			return fmt.Errorf("%q is not a number of neighbours", d)
+		}
+		n := int(d - '0')
+		i++
+		exclude := i < len(part) && part[i] == '-'
+		if exclude {
+			i++
+		}
+		start := i
+		for i < len(part) && part[i] >= 'a' && part[i] <= 'z' {
+			i++
+		}
+		letters := part[start:i]
+		if exclude && letters == "" {
+			return fmt.Errorf("%d- has no letters to leave out", n)
+		}
+		for _, l := range letters {
+			if !strings.ContainsRune(lettersFor(n), l) {
+				return fmt.Errorf("%q is not a letter for %d neighbours", l, n)
+			}
+		}
+		for _, c := range classes[n] {
+			if letters == "" || strings.IndexByte(letters, c.letter) >= 0 != exclude {
+				for _, s := range c.shapes {
+					table[s|cell] = true
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// lettersFor gets the letters that can follow a number of neighbours.
+func lettersFor(n int) string {
+	if n > 4 {
+		n = 8 - n
+	}
+	return henselLetters[n]
+}
+
+// formatHensel writes the arrangements of neighbours marked in the table for cells that are alive if alive is set and
+// dead otherwise, using letters only for the numbers of neighbours where some arrangements are left out, and
+// whichever of the letters in or the letters left out is shorter.
+func formatHensel(table []bool, alive bool) string {
+	cell := 0
+	if alive {
+		cell = centre
+	}
+	var b strings.Builder
+	for n, classes := range classes {
+		var in, out strings.Builder
+		for _, c := range classes {
+			if table[c.shapes[0]|cell] {
+				in.WriteByte(c.letter)
+			} else {
+				out.WriteByte(c.letter)
+			}
+		}
+		switch {
+		case in.Len() == 0:
+			continue
+		case out.Len() == 0:
+			fmt.Fprintf(&b, "%d", n)
+		case in.Len() <= out.Len():
+			fmt.Fprintf(&b, "%d%s", n, in.String())
+		default:
+			fmt.Fprintf(&b, "%d-%s", n, out.String())
+		}
+	}
+	return b.String()
+}
+
+// Neighbourhood gets the 3x3 block round a cell of a strip as the index into the table of a rule in B/S/C notation.
+// The strip wraps round at its left and right edges, and needs a row above and below the cell.
+func Neighbourhood(strip [][]byte, x, y int) int {
+	width := len(strip[0])
+	index := 0
+	for dy := 0; dy < 3; dy++ {
+		row := strip[y+dy-1]
+		for dx := 0; dx < 3; dx++ {
+			if row[((x+dx-1)%width+width)%width] == alive {
+				index |= 1 << uint(dy*3+dx)
+			}
+		}
+	}
+	return index
+}
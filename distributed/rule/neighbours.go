@@ -0,0 +1,68 @@
+package rule
+
+// Neighbours counts the alive cells in the neighbourhood of every cell of a strip, leaving out the cell itself unless
+// the rule counts the middle. The strip has Halo() rows above and below the rows being counted and wraps round at its
+// left and right edges, and the counts come back for the rows in between. They're worked out from summed-area tables,
+// so a count takes constant time in a Moore neighbourhood and time proportional to the range in a von Neumann one.
+func (g Generations) Neighbours(strip [][]byte) [][]int {
+	r := g.Halo()
+	height, width := len(strip)-2*r, len(strip[0])
+	padded := width + 2*r
+
+	// rows[y][i] is the number of alive cells in row y of the strip before column i of the padded row, where column i
+	// of the padded row is column i-r of the strip, wrapping round
+	rows := make([][]int, len(strip))
+	for y := range strip {
+		rows[y] = make([]int, padded+1)
+		for i := 0; i < padded; i++ {
+			rows[y][i+1] = rows[y][i]
+			if strip[y][((i-r)%width+width)%width] == alive {
+				rows[y][i+1]++
+			}
+		}
+	}
+
+	// table[y][i] is the number of alive cells in the rows before y and the padded columns before i
+	var table [][]int
+	if !g.VonNeumann {
+		table = make([][]int, len(strip)+1)
+		table[0] = make([]int, padded+1)
+		for y := range strip {
+			table[y+1] = make([]int, padded+1)
+			for i := range table[y+1] {
+				table[y+1][i] = table[y][i] + rows[y][i]
+			}
+		}
+	}
+
+	counts := make([][]int, height)
+	for y := 0; y < height; y++ {
+		counts[y] = make([]int, width)
+		for x := 0; x < width; x++ {
+			// The cell is at row y+r of the strip and column x+r of the padded rows
+			n := 0
+			if g.VonNeumann {
+				for dy := -r; dy <= r; dy++ {
+					reach := r - abs(dy)
+					row := rows[y+r+dy]
+					n += row[x+r+reach+1] - row[x+r-reach]
+				}
+			} else {
+				top, bottom := table[y], table[y+2*r+1]
+				n = bottom[x+2*r+1] - top[x+2*r+1] - bottom[x] + top[x]
+			}
+			if !g.Middle && strip[y+r][x] == alive {
+				n--
+			}
+			counts[y][x] = n
+		}
+	}
+	return counts
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,129 @@
+package rule
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Topology is the lattice the cells are laid out on, which decides which cells are neighbours. The world is stored
+// as rows of cells whatever the lattice, so PGM images keep the same layout.
+type Topology int
+
+const (
+	// Square cells have the 8 cells round them as neighbours.
+	Square Topology = iota
+
+	// Hex cells have 6 neighbours, with the odd rows shifted half a cell to the right of the even ones: the cells
+	// either side in the same row, and the two cells above and below that touch the cell.
+	Hex
+
+	// Triangular cells point up where x+y is even and down where it's odd, and have the 12 cells that share a corner
+	// with them as neighbours: 4 in the same row, 3 in the row the cell points into and 5 in the row its base is on.
+	Triangular
+)
+
+// ParseTopology parses the name of a topology, which is square if it's empty.
+func ParseTopology(name string) (Topology, error) {
+	switch strings.ToLower(name) {
+	case "", "square":
+		return Square, nil
+	case "hex", "hexagonal":
+		return Hex, nil
+	case "triangular", "tri":
+		return Triangular, nil
+	}
+	return Square, fmt.Errorf("there's no topology called %q, it must be square, hex or triangular", name)
+}
+
+func (t Topology) String() string {
+	switch t {
+	case Hex:
+		return "hex"
+	case Triangular:
+		return "triangular"
+	}
+	return "square"
+}
+
+// Check checks that a world of the given size wraps round without breaking the lattice. Offset rows only line up
+// across the top and bottom edges if there's an even number of rows, and triangles only fit together across the
+// edges if there's an even number of rows and columns.
+func (t Topology) Check(width, height int) error {
+	switch {
+	case t == Hex && height%2 != 0:
+		return fmt.Errorf("a hex world must have an even height to wrap round, not %d", height)
+	case t == Triangular && (height%2 != 0 || width%2 != 0):
+		return fmt.Errorf("a triangular world must have an even width and height to wrap round, not %dx%d", width, height)
+	}
+	return nil
+}
+
+// OnTopology gets a rule that runs on the topology. Only totalistic life-like rules run on hex and triangular
+// lattices, stochastic or not, as their neighbours are only counted, and the letters of Hensel's notation are for
+// square cells.
+func OnTopology(r Rule, t Topology) (Rule, error) {
+	if t == Square {
+		return r, nil
+	}
+	if s, ok := r.(Stochastic); ok {
+		g, err := OnTopology(s.Generations, t)
+		if err != nil {
+			return nil, err
+		}
+		s.Generations = g.(Generations)
+		return s, nil
+	}
+	g, ok := r.(Generations)
+	if !ok || g.Table == nil {
+		return nil, fmt.Errorf("%v can't run on a %v lattice, only rules in B/S/C notation can", r, t)
+	}
+	g.Birth, g.Survive = make([]bool, 9), make([]bool, 9)
+	for n, classes := range classes {
+		birth, survive := g.Table[classes[0].shapes[0]], g.Table[classes[0].shapes[0]|centre]
+		for _, c := range classes {
+			if g.Table[c.shapes[0]] != birth || g.Table[c.shapes[0]|centre] != survive {
+				return nil, fmt.Errorf("%v can't run on a %v lattice, as it depends on where the neighbours are", r, t)
+			}
+		}
+		g.Birth[n], g.Survive[n] = birth, survive
+	}
+	g.Topology = t
+	return g, nil
+}
+
+// count counts the alive neighbours of the cell at (x, y) of a strip, where row is the row of the world the cell is
+// on, which the shape of the neighbourhood depends on. The strip wraps round at its left and right edges.
+func (t Topology) count(strip [][]byte, x, y, row int) int {
+	width := len(strip[0])
+	n := 0
+	add := func(dx, dy int) {
+		if strip[y+dy][((x+dx)%width+width)%width] == alive {
+			n++
+		}
+	}
+	switch t {
+	case Hex:
+		shift := row & 1 // odd rows touch the cells above and below them half a cell further right
+		add(-1, 0)
+		add(1, 0)
+		for _, dy := range []int{-1, 1} {
+			add(shift-1, dy)
+			add(shift, dy)
+		}
+	case Triangular:
+		apex, base := -1, 1 // rows above and below an upward triangle
+		if (x+row)&1 != 0 {
+			apex, base = 1, -1
+		}
+		for dx := -2; dx <= 2; dx++ {
+			if dx != 0 {
+				add(dx, 0)
+			}
+			add(dx, base)
+			if dx >= -1 && dx <= 1 {
+				add(dx, apex)
+			}
+		}
+	}
+	return n
+}
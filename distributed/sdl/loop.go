@@ -2,12 +2,73 @@ package sdl
 
 import (
 	"fmt"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+
 	"github.com/veandco/go-sdl2/sdl"
 	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/pattern"
+	"uk.ac.bris.cs/gameoflife/rule"
+	"uk.ac.bris.cs/gameoflife/theme"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
-func Start(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune) {
-	w := NewWindow(int32(p.ImageWidth), int32(p.ImageHeight))
+// maxFrames is the most frames a GIF is recorded for before it's saved, so recordings of large boards don't run out
+// of memory
+const maxFrames = 500
+
+func Start(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune, edits chan<- gol.SetCells) {
+	// Cells are shown in the colours of the states of the rule
+	r, err := rule.Parse(p.Rule)
+	if err != nil {
+		r = rule.Life
+	}
+	// The cells of the whole world are kept, so a volume can be shown one slice at a time, moving between slices with
+	// ',' and '.', and so the state of a cell is known when it's clicked on
+	depth := 1
+	if p.ImageDepth > 1 {
+		depth = p.ImageDepth
+	}
+	cells := make([][]byte, p.ImageHeight*depth)
+	for y := range cells {
+		cells[y] = make([]byte, p.ImageWidth)
+	}
+	slice := 0
+	// Cells are coloured in the theme by state, or by age with 'a', keeping the turn each cell last changed on.
+	// Themes are cycled through with 't', and the slice that's shown is saved as a PNG with 'i' or recorded as a GIF
+	// between two presses of 'o', in the same colours.
+	t, err := theme.Parse(p.Theme)
+	if err != nil {
+		fmt.Println(err, "- using the classic theme instead.")
+		t = theme.Classic
+	}
+	painter := theme.Painter{Theme: t, Rule: r, Ages: p.Ages}
+	ages := theme.NewAges(len(cells), p.ImageWidth)
+	age := func(x, y int) int {
+		return ages.Age(x, slice*p.ImageHeight+y)
+	}
+	colour := func(x, y int) color.RGBA {
+		return painter.Colour(cells[slice*p.ImageHeight+y][x], age(x, y))
+	}
+	turn := 0
+	var recording *theme.Recording
+	var recordingFrom int
+	// Boards larger than the window are shown zoomed out, and can be zoomed into with '+', '-' and the mouse wheel,
+	// panned with the arrow keys or by dragging with the middle button, and moved around on the minimap
+	topology, _ := rule.ParseTopology(p.Topology)
+	v := NewViewport(p.ImageWidth, p.ImageHeight, topology == rule.Hex, colour)
+	w := NewWindow(int32(v.Width), int32(v.Height))
+	w.AddMinimap(v.MinimapSize())
+	showSlice(w, v)
+	// While paused, cells are drawn by dragging with the left button, alive if the first cell was dead and dead
+	// otherwise, and the brush is stamped with the right button, turned with 'r' and mirrored with 'f'
+	paused := false
+	drawing := false
+	var paint uint8
+	var last util.Cell
+	brush := loadBrush(p)
 
 sdlLoop:
 	for {
@@ -24,20 +85,192 @@ sdlLoop:
 					keyPresses <- 'q'
 				case sdl.K_k:
 					keyPresses <- 'k'
+				case sdl.K_n:
+					keyPresses <- 'n'
+				case sdl.K_m:
+					keyPresses <- 'm'
+				case sdl.K_b:
+					keyPresses <- 'b'
+				case sdl.K_v:
+					keyPresses <- 'v'
+				case sdl.K_g:
+					keyPresses <- 'g'
+				case sdl.K_c:
+					keyPresses <- 'c'
+				case sdl.K_h:
+					keyPresses <- 'h'
+				case sdl.K_w:
+					keyPresses <- 'w'
+				case sdl.K_e:
+					keyPresses <- 'e'
+				case sdl.K_t:
+					painter.Theme = theme.Next(painter.Theme)
+					showSlice(w, v)
+					fmt.Println("Showing the", painter.Theme.Name, "theme.")
+				case sdl.K_a:
+					painter.Ages = !painter.Ages
+					showSlice(w, v)
+					if painter.Ages {
+						fmt.Println("Colouring cells by the turns since they last changed.")
+					} else {
+						fmt.Println("Colouring cells by state.")
+					}
+				case sdl.K_i:
+					saveImage(p, painter, cells[slice*p.ImageHeight:(slice+1)*p.ImageHeight], age, turn)
+				case sdl.K_o:
+					if recording == nil {
+						recording = &theme.Recording{Delay: 5}
+						recordingFrom = turn
+						recording.Add(painter.Image(cells[slice*p.ImageHeight:(slice+1)*p.ImageHeight], age))
+						fmt.Println("Recording a GIF, press 'o' again to stop.")
+					} else {
+						saveRecording(p, painter, recording, recordingFrom, turn)
+						recording = nil
+					}
+				case sdl.K_r:
+					brush = brush.Rotate()
+					fmt.Println("Brush turned clockwise.")
+				case sdl.K_f:
+					brush = brush.Flip()
+					fmt.Println("Brush mirrored.")
+				case sdl.K_EQUALS, sdl.K_PLUS, sdl.K_KP_PLUS:
+					if v.Zoom(1, v.Width/2, v.Height/2) {
+						redraw(w, v)
+					}
+				case sdl.K_MINUS, sdl.K_KP_MINUS:
+					if v.Zoom(-1, v.Width/2, v.Height/2) {
+						redraw(w, v)
+					}
+				case sdl.K_0:
+					if v.Reset() {
+						redraw(w, v)
+					}
+				case sdl.K_LEFT, sdl.K_RIGHT, sdl.K_UP, sdl.K_DOWN:
+					dx, dy := v.Width/4, v.Height/4
+					switch e.Keysym.Sym {
+					case sdl.K_LEFT:
+						dx, dy = -dx, 0
+					case sdl.K_RIGHT:
+						dy = 0
+					case sdl.K_UP:
+						dx, dy = 0, -dy
+					case sdl.K_DOWN:
+						dx = 0
+					}
+					if v.PanPixels(dx, dy) {
+						redraw(w, v)
+					}
+				case sdl.K_COMMA, sdl.K_PERIOD:
+					if depth > 1 {
+						if e.Keysym.Sym == sdl.K_COMMA {
+							slice = (slice + depth - 1) % depth
+						} else {
+							slice = (slice + 1) % depth
+						}
+						showSlice(w, v)
+						fmt.Printf("Showing slice %v of %v\n", slice+1, depth)
+					}
+				}
+			case *sdl.MouseButtonEvent:
+				if e.Type != sdl.MOUSEBUTTONDOWN {
+					drawing = false
+					break
+				}
+				if x, y, ok := v.MinimapCell(w, int(e.X), int(e.Y)); ok {
+					if e.Button == sdl.BUTTON_LEFT && v.Centre(x, y) {
+						redraw(w, v)
+					}
+					break
+				}
+				if e.Button == sdl.BUTTON_MIDDLE {
+					break
+				}
+				cell, ok := cellAt(v, p, slice, e.X, e.Y)
+				if !ok {
+					break
+				}
+				if !paused {
+					fmt.Println("Pause before editing.")
+					break
+				}
+				switch e.Button {
+				case sdl.BUTTON_LEFT:
+					drawing = true
+					paint = 0
+					if cells[cell.Y][cell.X] == 0 {
+						paint = 255
+					}
+					last = cell
+					edits <- gol.SetCells{cell: paint}
+				case sdl.BUTTON_RIGHT:
+					edits <- stamp(p, brush, cell)
+				}
+			case *sdl.MouseWheelEvent:
+				px, py, _ := sdl.GetMouseState()
+				if v.Zoom(int(e.Y), int(px), int(py)) {
+					redraw(w, v)
+				}
+			case *sdl.MouseMotionEvent:
+				if e.State&sdl.ButtonMMask() != 0 {
+					if v.PanPixels(-int(e.XRel), -int(e.YRel)) {
+						redraw(w, v)
+					}
+					break
+				}
+				if !drawing || !paused {
+					break
+				}
+				if cell, ok := cellAt(v, p, slice, e.X, e.Y); ok && cell != last {
+					last = cell
+					edits <- gol.SetCells{cell: paint}
 				}
 			}
 		}
 		select {
 		case event, ok := <-events:
 			if !ok {
+				if recording != nil {
+					saveRecording(p, painter, recording, recordingFrom, turn)
+				}
 				w.Destroy()
 				break sdlLoop
 			}
 			switch e := event.(type) {
 			case gol.CellFlipped:
-				w.FlipPixel(e.Cell.X, e.Cell.Y)
+				x, y := e.Cell.X, e.Cell.Y%p.ImageHeight
+				shown := e.Cell.Y/p.ImageHeight == slice
+				var before color.RGBA
+				if shown {
+					before = colour(x, y)
+				}
+				cells[e.Cell.Y][e.Cell.X] = e.State
+				ages.Flip(e.Cell.X, e.Cell.Y)
+				if shown {
+					v.Change(w, x, y, before, colour(x, y))
+				}
 			case gol.TurnComplete:
-				w.RenderFrame()
+				turn = e.CompletedTurns
+				ages.Turn(turn)
+				if painter.Ages {
+					// Every cell gets a turn older, so they all change colour
+					showSlice(w, v)
+				} else {
+					w.RenderFrame()
+				}
+				if recording != nil {
+					recording.Add(painter.Image(cells[slice*p.ImageHeight:(slice+1)*p.ImageHeight], age))
+					if recording.Frames() >= maxFrames {
+						saveRecording(p, painter, recording, recordingFrom, turn)
+						recording = nil
+					}
+				}
+			case gol.Error:
+				fmt.Printf("Completed Turns %-8v%v\n", event.GetCompletedTurns(), event)
+				w.ShowError(e.Err.Error(), e.Fatal)
+			case gol.StateChange:
+				paused = e.NewState == gol.Paused
+				drawing = false
+				fmt.Printf("Completed Turns %-8v%v\n", event.GetCompletedTurns(), event)
 			default:
 				if len(event.String()) > 0 {
 					fmt.Printf("Completed Turns %-8v%v\n", event.GetCompletedTurns(), event)
@@ -49,3 +282,99 @@ sdlLoop:
 	}
 
 }
+
+// showSlice draws every cell of the slice that's shown, on the minimap as well, e.g. after moving to another slice of
+// a volume or changing colours, and shows it straight away.
+func showSlice(w *Window, v *Viewport) {
+	v.DrawMinimap(w)
+	v.Draw(w)
+	w.RenderFrame()
+}
+
+// redraw draws the slice that's shown again after zooming or panning, and shows it straight away.
+func redraw(w *Window, v *Viewport) {
+	v.Draw(w)
+	w.RenderFrame()
+}
+
+// exportName gets the name images of the board are exported under, which is the name the board is output under with
+// how it's coloured after it.
+func exportName(painter theme.Painter, name string) string {
+	name += "-" + painter.Theme.Name
+	if painter.Ages {
+		name += "-age"
+	}
+	return name
+}
+
+// saveImage saves the slice that's shown as a PNG in the colours it's shown in.
+func saveImage(p gol.Params, painter theme.Painter, slice [][]byte, age func(x, y int) int, turn int) {
+	filename := filepath.Join(gol.OutputDir(p), exportName(painter, gol.OutputName(p, turn))+".png")
+	file, err := os.Create(filename)
+	if err == nil {
+		err = png.Encode(file, painter.Image(slice, age))
+		file.Close()
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("Saved", filename)
+}
+
+// saveRecording saves the frames recorded between two turns as a GIF.
+func saveRecording(p gol.Params, painter theme.Painter, recording *theme.Recording, from, to int) {
+	name := fmt.Sprintf("%v-%d", gol.OutputName(p, from), to)
+	filename := filepath.Join(gol.OutputDir(p), exportName(painter, name)+".gif")
+	file, err := os.Create(filename)
+	if err == nil {
+		err = recording.Write(file)
+		file.Close()
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Saved %v frames to %v\n", recording.Frames(), filename)
+}
+
+// cellAt gets the cell of the world drawn at a pixel of the window, on the slice that's shown, if there's one there.
+func cellAt(v *Viewport, p gol.Params, slice int, px, py int32) (util.Cell, bool) {
+	x, y, ok := v.CellAt(int(px), int(py))
+	if !ok {
+		return util.Cell{}, false
+	}
+	return util.Cell{X: x, Y: slice*p.ImageHeight + y}, true
+}
+
+// stamp gets the edit that stamps a pattern centred on a cell, wrapping round the edges of the slice the cell is on.
+// The dead cells of the pattern clear the cells under them as well.
+func stamp(p gol.Params, brush pattern.Pattern, centre util.Cell) gol.SetCells {
+	edit := gol.SetCells{}
+	top := centre.Y / p.ImageHeight * p.ImageHeight
+	for y := range brush {
+		for x := range brush[y] {
+			cell := util.Cell{
+				X: ((centre.X+x-brush.Width()/2)%p.ImageWidth + p.ImageWidth) % p.ImageWidth,
+				Y: top + ((centre.Y-top+y-brush.Height()/2)%p.ImageHeight+p.ImageHeight)%p.ImageHeight,
+			}
+			edit[cell] = 0
+			if brush[y][x] {
+				edit[cell] = 255
+			}
+		}
+	}
+	return edit
+}
+
+// loadBrush gets the pattern given as the brush in the params, or a glider if there isn't one or it can't be loaded.
+func loadBrush(p gol.Params) pattern.Pattern {
+	if p.Brush != "" {
+		brush, err := pattern.Load(p.Brush)
+		if err == nil {
+			return brush
+		}
+		fmt.Println(err, "- using a glider as the brush instead.")
+	}
+	return pattern.Glider
+}
@@ -1,20 +1,41 @@
 package sdl
 
 import (
+	"image/color"
+	"math"
+
 	"github.com/veandco/go-sdl2/sdl"
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
+// minimapMargin is the number of pixels between the minimap and the edges of the window
+const minimapMargin = 8
+
 type Window struct {
 	Width, Height int32
 	window        *sdl.Window
 	renderer      *sdl.Renderer
 	texture       *sdl.Texture
 	pixels        []byte
+	minimap       *minimap
+}
+
+// minimap is a small picture of the whole board drawn over the bottom right corner of the window, with the part of
+// the board that's in the window marked on it.
+type minimap struct {
+	width, height int32
+	texture       *sdl.Texture
+	pixels        []byte
+	shown         bool
+	view          sdl.Rect // part of the board that's in the window, in pixels of the minimap
 }
 
 func filterEvent(e sdl.Event, userdata interface{}) bool {
-	return e.GetType() == sdl.KEYDOWN || e.GetType() == sdl.QUIT
+	switch e.GetType() {
+	case sdl.KEYDOWN, sdl.QUIT, sdl.MOUSEBUTTONDOWN, sdl.MOUSEBUTTONUP, sdl.MOUSEMOTION, sdl.MOUSEWHEEL:
+		return true
+	}
+	return false
 }
 
 func NewWindow(width, height int32) *Window {
@@ -38,10 +59,76 @@ func NewWindow(width, height int32) *Window {
 		renderer,
 		texture,
 		make([]byte, width*height*4),
+		nil,
+	}
+}
+
+// ShowError shows an error in the title of the window, or in a message box over it if the run can't carry on.
+func (w *Window) ShowError(message string, fatal bool) {
+	if fatal {
+		_ = sdl.ShowSimpleMessageBox(sdl.MESSAGEBOX_ERROR, "GOL GUI", message, w.window)
+		return
+	}
+	w.window.SetTitle("GOL GUI - " + message)
+}
+
+// AddMinimap adds a minimap of the given size to the window, which is hidden until ShowMinimap is called.
+func (w *Window) AddMinimap(width, height int) {
+	texture, err := w.renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STATIC, int32(width), int32(height))
+	util.Check(err)
+	w.minimap = &minimap{width: int32(width), height: int32(height), texture: texture, pixels: make([]byte, width*height*4)}
+}
+
+// ShowMinimap shows the minimap with a frame round the part of the board that's in the window, given in pixels of the
+// minimap.
+func (w *Window) ShowMinimap(x, y, width, height int) {
+	if w.minimap != nil {
+		w.minimap.shown = true
+		w.minimap.view = sdl.Rect{X: int32(x), Y: int32(y), W: int32(width), H: int32(height)}
+	}
+}
+
+// HideMinimap hides the minimap, e.g. when the whole board is in the window.
+func (w *Window) HideMinimap() {
+	if w.minimap != nil {
+		w.minimap.shown = false
+	}
+}
+
+// minimapRect gets where the minimap is drawn in the window, scaled down to a quarter of the window at most.
+func (w *Window) minimapRect() sdl.Rect {
+	m := w.minimap
+	scale := math.Min(1, math.Min(float64(w.Width)/4/float64(m.width), float64(w.Height)/4/float64(m.height)))
+	width, height := int32(float64(m.width)*scale), int32(float64(m.height)*scale)
+	return sdl.Rect{X: w.Width - width - minimapMargin, Y: w.Height - height - minimapMargin, W: width, H: height}
+}
+
+// MinimapPixel gets the pixel of the minimap drawn at a pixel of the window, if the minimap is shown there.
+func (w *Window) MinimapPixel(px, py int) (x, y int, ok bool) {
+	if w.minimap == nil || !w.minimap.shown {
+		return 0, 0, false
+	}
+	r := w.minimapRect()
+	if int32(px) < r.X || int32(px) >= r.X+r.W || int32(py) < r.Y || int32(py) >= r.Y+r.H {
+		return 0, 0, false
 	}
+	return int((int32(px) - r.X) * w.minimap.width / r.W), int((int32(py) - r.Y) * w.minimap.height / r.H), true
+}
+
+// SetMinimapColour sets a pixel of the minimap to a colour.
+func (w *Window) SetMinimapColour(x, y int, c color.RGBA) {
+	width := int(w.minimap.width)
+	w.minimap.pixels[4*(y*width+x)+0] = c.B
+	w.minimap.pixels[4*(y*width+x)+1] = c.G
+	w.minimap.pixels[4*(y*width+x)+2] = c.R
+	w.minimap.pixels[4*(y*width+x)+3] = c.A
 }
 
 func (w *Window) Destroy() {
+	if w.minimap != nil {
+		err := w.minimap.texture.Destroy()
+		util.Check(err)
+	}
 	err := w.texture.Destroy()
 	util.Check(err)
 	err = w.renderer.Destroy()
@@ -58,9 +145,45 @@ func (w *Window) RenderFrame() {
 	util.Check(err)
 	err = w.renderer.Copy(w.texture, nil, nil)
 	util.Check(err)
+	if w.minimap != nil && w.minimap.shown {
+		w.renderMinimap()
+	}
 	w.renderer.Present()
 }
 
+// renderMinimap draws the minimap in a grey frame, with the part of the board that's in the window framed in red.
+func (w *Window) renderMinimap() {
+	m := w.minimap
+	err := m.texture.Update(nil, m.pixels, int(m.width*4))
+	util.Check(err)
+	r := w.minimapRect()
+	err = w.renderer.Copy(m.texture, nil, &r)
+	util.Check(err)
+	err = w.renderer.SetDrawColor(0x80, 0x80, 0x80, 0xFF)
+	util.Check(err)
+	err = w.renderer.DrawRect(&sdl.Rect{X: r.X - 1, Y: r.Y - 1, W: r.W + 2, H: r.H + 2})
+	util.Check(err)
+	view := sdl.Rect{
+		X: r.X + m.view.X*r.W/m.width,
+		Y: r.Y + m.view.Y*r.H/m.height,
+		W: max32(m.view.W*r.W/m.width, 1),
+		H: max32(m.view.H*r.H/m.height, 1),
+	}
+	err = w.renderer.SetDrawColor(0xFF, 0x30, 0x30, 0xFF)
+	util.Check(err)
+	err = w.renderer.DrawRect(&view)
+	util.Check(err)
+	err = w.renderer.SetDrawColor(0, 0, 0, 0xFF)
+	util.Check(err)
+}
+
+func max32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 func (w *Window) PollEvent() sdl.Event {
 	return sdl.PollEvent()
 }
@@ -73,6 +196,24 @@ func (w *Window) SetPixel(x, y int) {
 	w.pixels[4*(y*width+x)+3] = 0xFF
 }
 
+// SetPixelLevel sets a pixel to the grey level of a cell's state, so refractory states show up as shades of grey.
+func (w *Window) SetPixelLevel(x, y int, level uint8) {
+	width := int(w.Width)
+	w.pixels[4*(y*width+x)+0] = level
+	w.pixels[4*(y*width+x)+1] = level
+	w.pixels[4*(y*width+x)+2] = level
+	w.pixels[4*(y*width+x)+3] = 0xFF
+}
+
+// SetPixelColour sets a pixel to the colour the rule shows a cell's state in.
+func (w *Window) SetPixelColour(x, y int, c color.RGBA) {
+	width := int(w.Width)
+	w.pixels[4*(y*width+x)+0] = c.B
+	w.pixels[4*(y*width+x)+1] = c.G
+	w.pixels[4*(y*width+x)+2] = c.R
+	w.pixels[4*(y*width+x)+3] = c.A
+}
+
 func (w *Window) FlipPixel(x, y int) {
 	width := int(w.Width)
 	w.pixels[4*(y*width+x)+0] = ^w.pixels[4*(y*width+x)+0]
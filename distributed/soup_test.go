@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestSoupSearch searches the same soups twice with different numbers of workers, checking that every soup is run
+// and that the seeds reproduce the same ash and rare objects.
+func TestSoupSearch(t *testing.T) {
+	sp := gol.SoupParams{
+		Soups:   8,
+		Size:    16,
+		Density: 0.5,
+		Seed:    1,
+		Workers: 1,
+	}
+	first, err := gol.SearchSoups(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Soups != sp.Soups {
+		t.Fatalf("Expected %v soups to be searched, got %v", sp.Soups, first.Soups)
+	}
+	if len(first.Objects) == 0 {
+		t.Fatal("Expected the soups to leave some ash")
+	}
+
+	sp.Workers = 4
+	second, err := gol.SearchSoups(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first.Objects, second.Objects) {
+		t.Fatalf("Searching the same seeds with %v workers gave %v, with %v workers %v", 1, first.Objects, sp.Workers, second.Objects)
+	}
+	if len(first.Rare) != len(second.Rare) {
+		t.Fatalf("Searching the same seeds with %v workers found %v rare objects, with %v workers %v", 1, len(first.Rare), sp.Workers, len(second.Rare))
+	}
+}
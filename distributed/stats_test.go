@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestStats writes the metrics of 100 turns of the 64x64 image as CSV and as JSON Lines, checking the alive cells
+// against check/alive and that the births and deaths of every turn add up to the change in alive cells.
+// The rows are measured by the workers, so it's run with an uneven split of the rows as well as a single worker.
+func TestStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	alive := readAliveCounts(64, 64)
+
+	for _, test := range []struct {
+		name    string
+		workers int
+	}{{"stats.csv", 1}, {"stats.csv", 3}, {"stats.jsonl", 1}, {"stats.jsonl", 3}} {
+		name := test.name
+		p := gol.Params{
+			Turns:        100,
+			Threads:      test.workers,
+			ImageWidth:   64,
+			ImageHeight:  64,
+			StatsFile:    filepath.Join(dir, name),
+			StatsRegions: 4,
+		}
+		t.Run(fmt.Sprintf("%v-%v_workers", name, test.workers), func(t *testing.T) {
+			events := make(chan gol.Event)
+			gol.Run(p, events, nil)
+			for range events {
+			}
+
+			type row struct{ turn, alive, births, deaths int }
+			var rows []row
+			f, err := os.Open(p.StatsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if filepath.Ext(name) == ".csv" {
+				table, err := csv.NewReader(f).ReadAll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(table[0]) != 8+p.StatsRegions*p.StatsRegions || table[0][0] != "completed_turns" || table[0][1] != "alive_cells" {
+					t.Fatalf("Unexpected CSV header %v", table[0])
+				}
+				for _, record := range table[1:] {
+					var r row
+					for i, field := range []*int{&r.turn, &r.alive, &r.births, &r.deaths} {
+						if *field, err = strconv.Atoi(record[i]); err != nil {
+							t.Fatal(err)
+						}
+					}
+					rows = append(rows, r)
+				}
+			} else {
+				scanner := bufio.NewScanner(f)
+				for scanner.Scan() {
+					var line struct {
+						CompletedTurns, AliveCells, Births, Deaths int
+						Regions                                    []float64
+					}
+					if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+						t.Fatal(err)
+					}
+					if len(line.Regions) != p.StatsRegions*p.StatsRegions {
+						t.Fatalf("Expected %v regions, got %v", p.StatsRegions*p.StatsRegions, len(line.Regions))
+					}
+					rows = append(rows, row{line.CompletedTurns, line.AliveCells, line.Births, line.Deaths})
+				}
+			}
+
+			if len(rows) != p.Turns {
+				t.Fatalf("Expected metrics for %v turns, got %v", p.Turns, len(rows))
+			}
+			for i, r := range rows {
+				if r.turn != i+1 {
+					t.Fatalf("Expected turn %v on line %v, got %v", i+1, i+1, r.turn)
+				}
+				if r.alive != alive[r.turn] {
+					t.Fatalf("At turn %v expected %v alive cells, got %v instead", r.turn, alive[r.turn], r.alive)
+				}
+				if i > 0 && rows[i-1].alive+r.births-r.deaths != r.alive {
+					t.Fatalf("At turn %v %v births and %v deaths don't take %v alive cells to %v", r.turn, r.births, r.deaths, rows[i-1].alive, r.alive)
+				}
+			}
+		})
+	}
+}
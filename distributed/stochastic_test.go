@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestStochastic runs Life with births, survivals and updates that only happen with a chance on 1 to 8 workers, and
+// checks that every output image with the same seed is the same as the one from a single worker, whichever rows each
+// worker gets. A different seed has to give a different image, so the chances are really applied.
+func TestStochastic(t *testing.T) {
+	p := gol.Params{
+		Turns:       50,
+		Threads:     1,
+		ImageWidth:  64,
+		ImageHeight: 64,
+		Rule:        "stochastic:B3/S23,birth=0.8,survive=0.9,update=0.7",
+		Seed:        42,
+	}
+	expected := runStochastic(t, p)
+	alive := 0
+	for y := range expected {
+		alive += bytes.Count(expected[y], []byte{255})
+	}
+	if alive == 0 {
+		t.Fatalf("Every cell is dead after %v turns", p.Turns)
+	}
+
+	for workers := 2; workers <= 8; workers++ {
+		p.Threads = workers
+		t.Run(fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads), func(t *testing.T) {
+			output := runStochastic(t, p)
+			for y := range expected {
+				if !bytes.Equal(output[y], expected[y]) {
+					t.Fatalf("Row %v after %v turns with seed %v is %v, expected %v", y, p.Turns, p.Seed, output[y], expected[y])
+				}
+			}
+		})
+	}
+
+	p.Threads, p.Seed = 8, 43
+	t.Run("other seed", func(t *testing.T) {
+		output := runStochastic(t, p)
+		for y := range expected {
+			if !bytes.Equal(output[y], expected[y]) {
+				return
+			}
+		}
+		t.Fatalf("Seeds 42 and 43 give the same board after %v turns", p.Turns)
+	})
+}
+
+// runStochastic runs the game and reads the output image back in.
+func runStochastic(t *testing.T, p gol.Params) [][]byte {
+	events := make(chan gol.Event)
+	gol.Run(p, events, nil)
+	for range events {
+	}
+	return readPgm(t, fmt.Sprintf("out/%dx%dx%d.pgm", p.ImageWidth, p.ImageHeight, p.Turns))
+}
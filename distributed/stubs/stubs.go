@@ -1,5 +1,46 @@
 package stubs
 
+import (
+	"uk.ac.bris.cs/gameoflife/rule"
+	"uk.ac.bris.cs/gameoflife/stats"
+)
+
+// Role : what a controller attached to the engine is allowed to do with the running session
+type Role int
+
+const (
+	// Operator : can pause, stop and shut down the session as well as observe it
+	Operator Role = iota
+
+	// Observer : only receives alive counts, snapshots and the final results
+	Observer
+)
+
+// State : state of the session running on the engine
+type State int
+
+const (
+	// Executing : turns are being computed
+	Executing State = iota
+
+	// Paused : no turns are computed, but alive cells and snapshots can still be requested
+	Paused
+
+	// Stopping : the computation has been stopped and the results are being collected
+	Stopping
+
+	// Quitting : the session has finished
+	Quitting
+)
+
+// Cycle : still life or oscillator the board has settled into. The board after Turn is the same as the board
+// after Start, and repeats every Period turns from then on.
+type Cycle struct {
+	Start  int `json:"start"`
+	Period int `json:"period"`
+	Turn   int `json:"detectedOn"`
+}
+
 /* Engine handlers */
 
 var GameOfLifeHandler = "Engine.GameOfLife"
@@ -7,6 +48,18 @@ var AliveCellsHandler = "Engine.AliveCells"
 var ResultsHandler = "Engine.GetResults"
 var PGMHandler = "Engine.GetPGM"
 var PauseHandler = "Engine.Pause"
+var ResumeHandler = "Engine.Resume"
+var StepHandler = "Engine.Step"
+var RunForHandler = "Engine.RunFor"
+var RewindHandler = "Engine.Rewind"
+var SeekHandler = "Engine.Seek"
+var SetCellsHandler = "Engine.SetCells"
+var TurnHandler = "Engine.GetTurn"
+var CensusHandler = "Engine.Census"
+var StatsHandler = "Engine.GetStats"
+var HeatMapHandler = "Engine.GetHeatMap"
+var SoupSearchHandler = "Engine.SoupSearch"
+var StateChangeHandler = "Engine.WaitStateChange"
 var StopHandler = "Engine.Stop"
 var StatusHandler = "Engine.Status"
 var ReconnectHandler = "Engine.Reconnect"
@@ -16,24 +69,31 @@ var StopWorkersHandler = "Engine.StopWorkers"
 
 var StartWorkerHandler = "Worker.StartWorker"
 var NextStateHandler = "Worker.CalculateNextState"
+var LoadWorkerHandler = "Worker.Load"
 var WorkerResultHandler = "Worker.GetResult"
 var WorkerPGMHandler = "Worker.GetPGM"
+var WorkerHeatMapHandler = "Worker.GetHeatMap"
 var StopWorkerHandler = "Worker.Stop"
+var SoupHandler = "Worker.RunSoup"
 
 /* Response structs */
 
 type ResponseStart struct {
-	Message string
+	Message      string `json:"message"`
+	ControllerID string `json:"controllerId"`
 }
 
 type ResponseAliveCells struct {
-	CompletedTurns int
-	NumAliveCells  int
+	CompletedTurns int    `json:"completedTurns"`
+	NumAliveCells  int    `json:"aliveCells"`
+	Cycle          *Cycle `json:"cycle,omitempty"`
 }
 
 type ResponseResult struct {
-	World [][]byte
-	Turn  int
+	World   [][]byte
+	Turn    int
+	Stopped bool
+	Cycle   *Cycle
 }
 
 type ResponsePGM struct {
@@ -42,24 +102,87 @@ type ResponsePGM struct {
 }
 
 type ResponsePause struct {
-	Message string
+	Message string `json:"message"`
+}
+
+type ResponseResume struct {
+	Message string `json:"message"`
+}
+
+type ResponseStateChange struct {
+	State          State `json:"state"`
+	CompletedTurns int   `json:"completedTurns"`
+}
+
+type ResponseStep struct {
+	CompletedTurns int `json:"completedTurns"`
+	NumAliveCells  int `json:"aliveCells"`
+}
+
+// ResponseSetCells : the cells that changed, with their grey levels snapped onto the states of the rule
+type ResponseSetCells struct {
+	Cells          []CellState `json:"cells"`
+	CompletedTurns int         `json:"completedTurns"`
+	NumAliveCells  int         `json:"aliveCells"`
+}
+
+type ResponseStats struct {
+	Turns []stats.Turn `json:"turns"`
+}
+
+// ResponseHeatMap : number of turns each cell was alive after and flipped on, up to Turn
+type ResponseHeatMap struct {
+	Alive [][]uint32
+	Flips [][]uint32
+	Turn  int
+}
+
+type ResponseCensus struct {
+	CompletedTurns int            `json:"completedTurns"`
+	Objects        map[string]int `json:"objects"`
+}
+
+// RareObject : object that doesn't often turn up in soups, with the seed of the soup it came from
+type RareObject struct {
+	Name string `json:"name"`
+	Seed int64  `json:"seed"`
+}
+
+type ResponseSoupSearch struct {
+	Soups          int            `json:"soups"`
+	Settled        int            `json:"settled"`
+	Objects        map[string]int `json:"objects"`
+	Rare           []RareObject   `json:"rare"`
+	Seconds        float64        `json:"seconds"`
+	SoupsPerSecond float64        `json:"soupsPerSecond"`
+}
+
+type ResponseSoup struct {
+	Seed    int64
+	Turns   int
+	Settled bool
+	Objects map[string]int
 }
 
 type ResponseStop struct {
-	Message string
+	Message string `json:"message"`
 }
 
 type ResponseReconnect struct {
-	Message string
+	Message      string `json:"message"`
+	ControllerID string `json:"controllerId"`
 }
 
 type ResponseStatus struct {
-	Running bool
+	Running bool `json:"running"`
 }
 
 type ResponseRows struct {
-	TopRow    []byte
-	BottomRow []byte
+	TopRows    [][]byte
+	BottomRows [][]byte
+	Hash       uint64
+	Stats      []stats.Row
+	Changes    []CellState // cells of the worker's part that changed on the turn, if the engine keeps a history
 }
 
 type ResponseWorkerResult struct {
@@ -67,18 +190,35 @@ type ResponseWorkerResult struct {
 	WorkerID        int
 }
 
+type ResponseWorkerHeatMap struct {
+	Alive    [][]uint32
+	Flips    [][]uint32
+	WorkerID int
+}
+
 type ResponseStopWorkers struct {
-	OK bool
+	OK bool `json:"ok"`
 }
 
 type ResponseStopWorker struct{}
 
 /* Request structs */
 
+// RequestStart : starts a session, replacing the running one only if ControllerID is the ID of one of its operators
 type RequestStart struct {
-	World      [][]byte
-	Turns      int
-	NumWorkers int
+	ControllerID  string
+	World         [][]byte
+	Turns         int
+	NumWorkers    int
+	HistoryBudget int
+	StopOnCycle   bool
+	StatsRegions  int
+	HeatMap       bool
+	Rule          rule.Spec
+	Topology      string
+	Depth         int
+	Seed          int64
+	Turn          int // turn the world is on, which is 0 unless a saved session is being carried on
 }
 
 type RequestResult struct{}
@@ -87,28 +227,120 @@ type RequestAliveCells struct{}
 
 type RequestPGM struct{}
 
-type RequestPause struct{}
+type RequestPause struct {
+	ControllerID string
+}
+
+type RequestResume struct {
+	ControllerID string
+}
+
+type RequestStateChange struct {
+	Known State
+}
+
+type RequestStep struct {
+	ControllerID string
+}
+
+type RequestRunFor struct {
+	ControllerID string
+	Turns        int
+}
+
+type RequestRewind struct {
+	ControllerID string
+	Turns        int
+}
+
+type RequestSeek struct {
+	ControllerID string
+	Turn         int
+}
+
+// CellState : a cell of the world and the grey level it's set to
+type CellState struct {
+	X     int  `json:"x"`
+	Y     int  `json:"y"`
+	State byte `json:"state"`
+}
 
-type RequestStop struct{}
+// RequestSetCells : cells to set while paused, e.g. cells drawn in the SDL window
+type RequestSetCells struct {
+	ControllerID string
+	Cells        []CellState
+}
+
+type RequestTurn struct {
+	Turn int
+}
+
+// RequestStats : turns from From to To inclusive, up to the latest turn if To is 0
+type RequestStats struct {
+	From int
+	To   int
+}
+
+type RequestHeatMap struct{}
+
+type RequestCensus struct{}
+
+type RequestSoupSearch struct {
+	Soups      int
+	Size       int
+	Density    float64
+	MaxTurns   int
+	NumWorkers int
+	Seed       int64
+}
+
+type RequestSoup struct {
+	Seed     int64
+	Size     int
+	Density  float64
+	MaxTurns int
+}
+
+type RequestStop struct {
+	ControllerID string
+}
 
 type RequestStatus struct{}
 
-type RequestReconnect struct{}
+// RequestReconnect : attaches another controller to the session. Controllers are attached as operators only if
+// ControllerID is the ID of one of its operators, who can hand their rights on by sharing it.
+type RequestReconnect struct {
+	Role         Role
+	ControllerID string
+}
 
 type RequestStartWorker struct {
-	WorkerWorld [][]byte
-	WorkerID    int
+	WorkerWorld  [][]byte
+	WorkerID     int
+	NumWorkers   int
+	StatsRegions int
+	HeatMap      bool
+	Rule         rule.Spec
+	Topology     string
+	Top          int
+	Height       int
+	Depth        int
+	Seed         int64
+	Turn         int
+	History      bool
 }
 
 type RequestNextState struct {
-	TopRow    []byte
-	BottomRow []byte
+	TopRows    [][]byte
+	BottomRows [][]byte
 }
 
 type RequestWorkerResult struct {
 	NumWorkers int
 }
 
-type RequestStopWorkers struct{}
+type RequestStopWorkers struct {
+	ControllerID string
+}
 
 type RequestStopWorker struct{}
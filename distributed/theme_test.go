@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/rule"
+	"uk.ac.bris.cs/gameoflife/theme"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestThemes checks every theme can be found by name and cycled through, and that cells are coloured by state between
+// the dead and alive colours, or in the rule's own colours in the classic theme.
+func TestThemes(t *testing.T) {
+	names := theme.Names()
+	seen := map[string]bool{}
+	next := theme.Classic
+	for range names {
+		next = theme.Next(next)
+		seen[next.Name] = true
+	}
+	if len(seen) != len(names) || next.Name != theme.Classic.Name {
+		t.Fatalf("Cycling through %v went through %v", names, seen)
+	}
+	if _, err := theme.Parse("rainbow"); err == nil {
+		t.Fatal("Expected an error for a theme that doesn't exist")
+	}
+
+	for _, name := range names {
+		th, err := theme.Parse(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		painter := theme.Painter{Theme: th, Rule: rule.Wireworld{}}
+		if name == theme.Classic.Name {
+			if painter.Colour(1, 0) != (rule.Wireworld{}).Colour(1) {
+				t.Errorf("The classic theme should show states in the rule's own colours")
+			}
+			continue
+		}
+		if painter.Colour(0, 0) != th.Dead || painter.Colour(255, 0) != th.Alive {
+			t.Errorf("The %v theme shows dead and alive cells as %v and %v, expected %v and %v", name, painter.Colour(0, 0), painter.Colour(255, 0), th.Dead, th.Alive)
+		}
+	}
+}
+
+// TestAges keeps the ages of the cells of the 16x16 glider the way the SDL window does, from a CellFlipped event for
+// each cell that changes followed by the TurnComplete event of the turn, and checks them against the turns since each
+// cell last changed. The ages are then exported as a PNG and a GIF, which have to come back in the colours the cells
+// are shown in.
+func TestAges(t *testing.T) {
+	const turns = 40
+	width, height := 16, 16
+	board := make([][]byte, height)
+	changed := make([][]int, height)
+	for y := range board {
+		board[y] = make([]byte, width)
+		changed[y] = make([]int, width)
+	}
+	painter := theme.Painter{Theme: theme.Classic, Rule: rule.Life, Ages: true}
+	ages := theme.NewAges(height, width)
+	for _, cell := range util.ReadAliveCells("images/16x16.pgm", width, height) {
+		board[cell.Y][cell.X] = 255
+		ages.Flip(cell.X, cell.Y)
+	}
+	recording := &theme.Recording{Delay: 5}
+	for turn := 0; turn < turns; turn++ {
+		next := nextBoard(board)
+		for y := range next {
+			for x := range next[y] {
+				if next[y][x] != board[y][x] {
+					changed[y][x] = turn
+					ages.Flip(x, y)
+				}
+			}
+		}
+		board = next
+		ages.Turn(turn)
+		recording.Add(painter.Image(board, ages.Age))
+	}
+
+	last := turns - 1
+	for y := range board {
+		for x := range board[y] {
+			if age := ages.Age(x, y); age != last-changed[y][x] {
+				t.Fatalf("Cell (%v, %v) is %v turns old, expected %v", x, y, age, last-changed[y][x])
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, painter.Image(board, ages.Age)); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := range board {
+		for x := range board[y] {
+			expected := painter.Colour(board[y][x], last-changed[y][x])
+			if got := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA); got != expected {
+				t.Fatalf("Cell (%v, %v) of the PNG is %v, expected %v", x, y, got, expected)
+			}
+		}
+	}
+	if young, old := painter.Colour(255, 1), painter.Colour(255, 1000); young == old {
+		t.Errorf("Cells alive for 1 and 1000 turns are both shown as %v", young)
+	}
+
+	out.Reset()
+	if err := recording.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	animation, err := gif.DecodeAll(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(animation.Image) != turns {
+		t.Fatalf("Expected a frame for each of the %v turns, got %v", turns, len(animation.Image))
+	}
+	if bounds := animation.Image[0].Bounds(); bounds.Dx() != width || bounds.Dy() != height {
+		t.Fatalf("Frames are %v, expected %vx%v", bounds, width, height)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestTopology runs totalistic rules on hex and triangular lattices and checks the output images against counting
+// each neighbour from a list of offsets. The shape of a neighbourhood depends on whether the row is odd or even, so
+// it runs with workers starting on odd rows as well as a single worker.
+func TestTopology(t *testing.T) {
+	tests := []struct {
+		topology       string
+		rule           string
+		birth, survive []int
+	}{
+		{"hex", "B2/S34", []int{2}, []int{3, 4}},
+		{"triangular", "B4/S345", []int{4}, []int{3, 4, 5}},
+	}
+	for _, test := range tests {
+		for _, turns := range []int{0, 1, 20} {
+			for _, workers := range []int{1, 3, 8} {
+				p := gol.Params{
+					Turns:       turns,
+					Threads:     workers,
+					ImageWidth:  64,
+					ImageHeight: 64,
+					Rule:        test.rule,
+					Topology:    test.topology,
+				}
+				t.Run(fmt.Sprintf("%v-%dx%dx%d-%d", p.Topology, p.ImageWidth, p.ImageHeight, p.Turns, p.Threads), func(t *testing.T) {
+					events := make(chan gol.Event)
+					gol.Run(p, events, nil)
+					for range events {
+					}
+
+					expected := readPgm(t, fmt.Sprintf("images/%dx%d.pgm", p.ImageWidth, p.ImageHeight))
+					for turn := 0; turn < p.Turns; turn++ {
+						expected = latticeNext(expected, test.topology, test.birth, test.survive)
+					}
+					output := readPgm(t, fmt.Sprintf("out/%dx%dx%d.pgm", p.ImageWidth, p.ImageHeight, p.Turns))
+					for y := range expected {
+						if !bytes.Equal(output[y], expected[y]) {
+							t.Fatalf("Row %v after %v turns is %v, expected %v", y, p.Turns, output[y], expected[y])
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+// hexOffsets are the neighbours of a cell on an even and an odd row of a hex lattice, with the odd rows shifted half
+// a cell to the right.
+var hexOffsets = [2][][2]int{
+	{{-1, 0}, {1, 0}, {-1, -1}, {0, -1}, {-1, 1}, {0, 1}},
+	{{-1, 0}, {1, 0}, {0, -1}, {1, -1}, {0, 1}, {1, 1}},
+}
+
+// triangleOffsets are the neighbours of a triangle pointing up and one pointing down, which share a corner with it.
+var triangleOffsets = [2][][2]int{
+	{
+		{-2, 0}, {-1, 0}, {1, 0}, {2, 0},
+		{-1, -1}, {0, -1}, {1, -1},
+		{-2, 1}, {-1, 1}, {0, 1}, {1, 1}, {2, 1},
+	},
+	{
+		{-2, 0}, {-1, 0}, {1, 0}, {2, 0},
+		{-1, 1}, {0, 1}, {1, 1},
+		{-2, -1}, {-1, -1}, {0, -1}, {1, -1}, {2, -1},
+	},
+}
+
+// latticeNext computes a turn of a two state totalistic rule on a hex or triangular board that wraps round at the
+// edges.
+func latticeNext(world [][]byte, topology string, birth, survive []int) [][]byte {
+	height, width := len(world), len(world[0])
+	next := make([][]byte, height)
+	for y := range world {
+		next[y] = make([]byte, width)
+		for x := range world[y] {
+			offsets := hexOffsets[y%2]
+			if topology == "triangular" {
+				offsets = triangleOffsets[(x+y)%2]
+			}
+			n := 0
+			for _, offset := range offsets {
+				if world[(y+offset[1]+height)%height][(x+offset[0]+width)%width] == 255 {
+					n++
+				}
+			}
+			counts := birth
+			if world[y][x] == 255 {
+				counts = survive
+			}
+			for _, c := range counts {
+				if n == c {
+					next[y][x] = 255
+				}
+			}
+		}
+	}
+	return next
+}
@@ -0,0 +1,154 @@
+// Package transport carries the calls controllers make to the engine and the engine makes to its workers, either with
+// net/rpc over TCP or as direct calls to handlers in the same process, so the whole system can be run inside tests.
+package transport
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"net/rpc"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Client makes calls to a server, like *rpc.Client.
+type Client interface {
+	Call(serviceMethod string, args interface{}, reply interface{}) error
+	Close() error
+}
+
+// Transport connects to servers by their address.
+type Transport interface {
+	Dial(address string) (Client, error)
+}
+
+// TCP is the transport to servers served with net/rpc over TCP.
+type TCP struct{}
+
+// Dial connects to the server at the address.
+func (TCP) Dial(address string) (Client, error) {
+	client, err := rpc.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// InProcess is the transport to handlers registered in the same process, which are called directly instead of over a
+// connection. Handlers are looked up by their address and the name of their service, like net/rpc, and the errors they
+// return are given to callers as rpc.ServerError, so callers can't tell them apart from servers over TCP. Arguments and
+// replies are sent through gob like they are by net/rpc, so handlers and callers never share them and anything that
+// can't be sent over TCP can't be sent here either.
+type InProcess struct {
+	mu       sync.Mutex
+	services map[string]map[string]interface{}
+}
+
+// NewInProcess returns an in-process transport without any handlers.
+func NewInProcess() *InProcess {
+	return &InProcess{services: map[string]map[string]interface{}{}}
+}
+
+// Register serves the methods of the receiver at the address under the name of its type, like rpc.Register.
+func (t *InProcess) Register(address string, receiver interface{}) {
+	name := reflect.Indirect(reflect.ValueOf(receiver)).Type().Name()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.services[address] == nil {
+		t.services[address] = map[string]interface{}{}
+	}
+	t.services[address][name] = receiver
+}
+
+// Dial connects to the handlers registered at the address.
+func (t *InProcess) Dial(address string) (Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	registered, ok := t.services[address]
+	if !ok {
+		return nil, fmt.Errorf("nothing is registered at %v", address)
+	}
+	services := make(map[string]interface{}, len(registered))
+	for name, receiver := range registered {
+		services[name] = receiver
+	}
+	return &inProcessClient{services: services}, nil
+}
+
+// inProcessClient calls the handlers registered at one address until it's closed.
+type inProcessClient struct {
+	mu       sync.Mutex
+	services map[string]interface{}
+	closed   bool
+}
+
+// Call calls the method of the service named by serviceMethod, e.g. Engine.GetResults, with the args and reply.
+func (c *inProcessClient) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return rpc.ErrShutdown
+	}
+
+	dot := strings.LastIndex(serviceMethod, ".")
+	if dot < 0 {
+		return rpc.ServerError("rpc: service/method request ill-formed: " + serviceMethod)
+	}
+	receiver, ok := c.services[serviceMethod[:dot]]
+	if !ok {
+		return rpc.ServerError("rpc: can't find service " + serviceMethod)
+	}
+	method := reflect.ValueOf(receiver).MethodByName(serviceMethod[dot+1:])
+	if !method.IsValid() || method.Type().NumIn() != 2 || method.Type().NumOut() != 1 {
+		return rpc.ServerError("rpc: can't find method " + serviceMethod)
+	}
+
+	// Handlers take their args by value or by pointer, like with net/rpc
+	argsType := method.Type().In(0)
+	if argsType.Kind() == reflect.Ptr {
+		argsType = argsType.Elem()
+	}
+	if given := reflect.Indirect(reflect.ValueOf(args)).Type(); given != argsType {
+		return fmt.Errorf("rpc: %v takes %v, given %v", serviceMethod, argsType, given)
+	}
+	replyType := method.Type().In(1)
+	if reflect.TypeOf(reply) != replyType {
+		return fmt.Errorf("rpc: %v replies with %v, given %v", serviceMethod, replyType, reflect.TypeOf(reply))
+	}
+	argsValue := reflect.New(argsType)
+	if err := resend(args, argsValue.Interface()); err != nil {
+		return err
+	}
+	if method.Type().In(0).Kind() != reflect.Ptr {
+		argsValue = argsValue.Elem()
+	}
+
+	// The reply is only sent back if the handler succeeds, as net/rpc leaves it as it was otherwise
+	replyValue := reflect.New(replyType.Elem())
+	if err := method.Call([]reflect.Value{argsValue, replyValue})[0].Interface(); err != nil {
+		return rpc.ServerError(err.(error).Error())
+	}
+	return resend(replyValue.Interface(), reply)
+}
+
+// resend encodes a value with gob and decodes it into another, the way it'd be sent to a server or back over TCP.
+func resend(from, to interface{}) error {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(from); err != nil {
+		return err
+	}
+	return gob.NewDecoder(&buffer).Decode(to)
+}
+
+// Close stops the client making any more calls.
+func (c *inProcessClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return rpc.ErrShutdown
+	}
+	c.closed = true
+	return nil
+}
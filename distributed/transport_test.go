@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/rpc"
+	"os"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/broker"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/transport"
+)
+
+// testWorkers is the number of workers the engine inside the test binary has, which is as many as the tests use.
+const testWorkers = 8
+
+var useTCP = flag.Bool("tcp", false, "Run the tests against the engine at the server address over TCP instead of inside the test binary")
+
+// testEngine is the engine inside the test binary, which is nil if the tests are run against an engine over TCP.
+var testEngine *broker.Engine
+
+// TestMain runs the tests against an engine and its workers inside the test binary, called directly instead of over
+// connections, so the tests don't need any servers to be running.
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if !*useTCP {
+		inProcess := transport.NewInProcess()
+		broker.StartInProcessWorkers(inProcess, testWorkers)
+		testEngine = broker.NewEngine()
+		inProcess.Register(gol.ServerAddress(), testEngine)
+		gol.DefaultTransport = inProcess
+	}
+	os.Exit(m.Run())
+}
+
+// settleEngine runs a single turn through a controller, which replaces any run an earlier test left running, so tests
+// that call the engine directly find it idle.
+func settleEngine(t *testing.T) {
+	events := make(chan gol.Event, 1000)
+	gol.Run(gol.Params{Turns: 1, Threads: 1, ImageWidth: 16, ImageHeight: 16}, events, nil)
+	for event := range events {
+		if e, ok := event.(gol.Error); ok {
+			t.Error(e)
+		}
+	}
+}
+
+// Rows is a handler that flips every cell of the rows it's given, keeping hold of both the rows and its reply.
+type Rows struct {
+	args, reply [][]byte
+}
+
+// Flip flips the cells of the rows and replies with them, or fails if there aren't any.
+func (r *Rows) Flip(args [][]byte, reply *[][]byte) error {
+	if len(args) == 0 {
+		return errors.New("no rows")
+	}
+	for _, row := range args {
+		for x := range row {
+			row[x] ^= 255
+		}
+	}
+	r.args, r.reply = args, args
+	*reply = args
+	return nil
+}
+
+// TestInProcess checks the in-process transport sends args and replies like TCP, so neither the caller nor the handler
+// sees what the other does with them afterwards, failed calls leave the reply alone, and what gob can't send isn't sent.
+func TestInProcess(t *testing.T) {
+	inProcess := transport.NewInProcess()
+	handler := new(Rows)
+	inProcess.Register("rows", handler)
+	client, err := inProcess.Dial("rows")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	rows := [][]byte{{0, 255}, {255, 0}}
+	var reply [][]byte
+	if err := client.Call("Rows.Flip", rows, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if rows[0][0] != 0 || rows[1][0] != 255 {
+		t.Errorf("The handler changed the caller's rows to %v", rows)
+	}
+	if len(reply) != 2 || reply[0][0] != 255 || reply[1][0] != 0 {
+		t.Fatalf("The reply is %v, expected the flipped rows", reply)
+	}
+	handler.reply[0][1] = 7
+	reply[1][1] = 9
+	if reply[0][1] != 0 || handler.args[1][1] != 255 {
+		t.Errorf("The caller and the handler share the reply %v and rows %v", reply, handler.args)
+	}
+
+	unchanged := [][]byte{{1}}
+	if err := client.Call("Rows.Flip", [][]byte{}, &unchanged); err == nil {
+		t.Errorf("Flipping no rows succeeded")
+	} else if _, ok := err.(rpc.ServerError); !ok {
+		t.Errorf("The handler's error was given as %T, expected rpc.ServerError", err)
+	}
+	if len(unchanged) != 1 || unchanged[0][0] != 1 {
+		t.Errorf("A failed call changed the reply to %v", unchanged)
+	}
+
+	if err := client.Call("Rows.Flip", []func(){nil}, &reply); err == nil {
+		t.Errorf("Args that can't be sent over TCP were sent")
+	}
+}
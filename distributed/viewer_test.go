@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+// viewerMessage is a JSON message sent to and from the live viewer.
+type viewerMessage struct {
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+}
+
+// dialViewer connects to the live viewer of the server as the controller with the given ID.
+func dialViewer(t *testing.T, server *httptest.Server, controllerID string) *websocket.Conn {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?controller=" + controllerID
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// readViewer reads messages from the live viewer until it sends a frame, if frame is set, or a JSON message otherwise.
+func readViewer(t *testing.T, conn *websocket.Conn, frame bool) (viewerMessage, []byte) {
+	t.Helper()
+	if err := conn.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		kind, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kind == websocket.BinaryMessage {
+			if frame {
+				return viewerMessage{}, data
+			}
+			continue
+		}
+		var msg viewerMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("The viewer sent %s, which isn't JSON: %v", data, err)
+		}
+		if !frame && msg.Message != "Attached to a new run" {
+			return msg, nil
+		}
+	}
+}
+
+// decodeFrame decodes a key frame from the live viewer into the turn it's of and the board, in the grey levels of the
+// states of the rule.
+func decodeFrame(t *testing.T, frame []byte) (int, [][]byte) {
+	t.Helper()
+	if len(frame) < 18 || frame[0] != 0 {
+		t.Fatalf("The viewer sent %v, expected a key frame", frame)
+	}
+	turn := int(binary.BigEndian.Uint32(frame[1:]))
+	width := int(binary.BigEndian.Uint32(frame[9:]))
+	height := int(binary.BigEndian.Uint32(frame[13:]))
+	levels := frame[18 : 18+int(frame[17])]
+	packed, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(frame[18+len(levels):])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bits := 1
+	for 1<<uint(bits) < len(levels) {
+		bits++
+	}
+	world := make([][]byte, height)
+	i := 0
+	for y := range world {
+		world[y] = make([]byte, width)
+		for x := range world[y] {
+			state := 0
+			for b := 0; b < bits; b++ {
+				state = state<<1 | int(packed[i/8]>>uint(7-i%8)&1)
+				i++
+			}
+			world[y][x] = levels[state]
+		}
+	}
+	return turn, world
+}
+
+// TestViewer runs a Wireworld circuit, checks the live viewer sends frames of the board in every state of the rule,
+// and that pausing and stopping from the browser only take effect for an operator.
+func TestViewer(t *testing.T) {
+	server := startHTTPServer(t)
+	defer server.Close()
+	world := make([][]byte, 16)
+	for y := range world {
+		world[y] = make([]byte, 16)
+		for x := range world[y] {
+			if (y == 4 || y == 10) && x >= 2 && x <= 12 || (x == 2 || x == 12) && y >= 4 && y <= 10 {
+				world[y][x] = 85
+			}
+		}
+	}
+	world[4][3], world[4][2] = 255, 170
+
+	started := new(stubs.ResponseStart)
+	request(t, server, http.MethodPost, "/api/runs?turns=100000000&workers=2&rule=Wireworld", pgmBody(world), http.StatusAccepted, started)
+	operator := started.ControllerID
+	request(t, server, http.MethodPost, "/api/pause?controller="+operator, nil, http.StatusOK, nil)
+	_, pgm := request(t, server, http.MethodGet, "/api/snapshot", nil, http.StatusOK, nil)
+	snapshot := pgm[len("P5\n16 16\n255\n"):]
+
+	conn := dialViewer(t, server, operator)
+	defer conn.Close()
+	_, frame := readViewer(t, conn, true)
+	turn, board := decodeFrame(t, frame)
+	if !bytes.Equal(bytes.Join(board, nil), snapshot) {
+		t.Errorf("The frame of turn %v is %v, expected %v", turn, board, snapshot)
+	}
+	if err := conn.WriteJSON(viewerMessage{Type: "ack"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another viewer is sent the same frame, and nothing after it while the run is paused
+	idle := dialViewer(t, server, "")
+	if _, shared := readViewer(t, idle, true); !bytes.Equal(shared, frame) {
+		t.Errorf("Two viewers of the paused run were sent different frames")
+	}
+	if err := idle.WriteJSON(viewerMessage{Type: "ack"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := idle.SetReadDeadline(time.Now().Add(500 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		kind, _, err := idle.ReadMessage()
+		if err != nil {
+			break
+		}
+		if kind == websocket.BinaryMessage {
+			t.Errorf("The viewer sent another frame while the run was paused")
+			break
+		}
+	}
+	idle.Close()
+
+	observer := dialViewer(t, server, "")
+	defer observer.Close()
+	if err := observer.WriteJSON(viewerMessage{Type: "stop"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg, _ := readViewer(t, observer, false); msg.Type != "error" {
+		t.Errorf("Stopping from the viewer of an observer was answered with %v", msg)
+	}
+
+	// The pause button toggles between resuming and pausing, and frames of later turns are sent in between
+	pause := func(expected string) {
+		if err := conn.WriteJSON(viewerMessage{Type: "pause"}); err != nil {
+			t.Fatal(err)
+		}
+		if msg, _ := readViewer(t, conn, false); msg.Type != "status" || !strings.HasPrefix(msg.Message, expected) {
+			t.Errorf("Pausing from the viewer was answered with %v, expected %v", msg, expected)
+		}
+	}
+	pause("Continuing")
+	for resumedOn := turn; resumedOn <= turn; {
+		if err := conn.WriteJSON(viewerMessage{Type: "ack"}); err != nil {
+			t.Fatal(err)
+		}
+		_, frame := readViewer(t, conn, true)
+		resumedOn = int(binary.BigEndian.Uint32(frame[1:]))
+	}
+	pause("Pausing on turn")
+
+	if err := conn.WriteJSON(viewerMessage{Type: "stop"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg, _ := readViewer(t, conn, false); msg.Type != "status" {
+		t.Errorf("Stopping from the viewer was answered with %v", msg)
+	}
+	res, _ := request(t, server, http.MethodGet, "/api/results", nil, http.StatusOK, nil)
+	if stopped := res.Header.Get("X-Stopped"); stopped != "true" {
+		t.Errorf("The run stopped from the viewer finished with X-Stopped %q", stopped)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/census"
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestCensus takes a census of the 16x16 board, which holds a single glider, in each of the glider's four phases.
+func TestCensus(t *testing.T) {
+	p := gol.Params{
+		Turns:       100,
+		Threads:     4,
+		ImageWidth:  16,
+		ImageHeight: 16,
+	}
+	events := make(chan gol.Event, 1000)
+	keyPresses := make(chan rune, 10)
+	gol.Run(p, events, keyPresses)
+
+	keyPresses <- 'p'
+	awaitEvent(t, events, func(e gol.Event) bool {
+		stateChange, ok := e.(gol.StateChange)
+		return ok && stateChange.NewState == gol.Paused
+	})
+
+	for phase := 0; phase < 4; phase++ {
+		keyPresses <- 'c'
+		result := awaitEvent(t, events, func(e gol.Event) bool {
+			_, ok := e.(gol.CensusComplete)
+			return ok
+		}).(gol.CensusComplete)
+		if len(result.Objects) != 1 || result.Objects["glider"] != 1 {
+			t.Fatalf("Expected a single glider on turn %v, got %v instead", result.CompletedTurns, result.Objects)
+		}
+		keyPresses <- 'n'
+	}
+
+	keyPresses <- 'p'
+	for range events {
+	}
+}
+
+// placed is a pattern drawn with 'O' for alive cells, with its top left corner at x, y.
+type placed struct {
+	x, y int
+	rows []string
+}
+
+// TestCensusObjects takes censuses of boards holding still lifes, oscillators and spaceships in different phases,
+// rotations and reflections, on their own, next to each other and across the edges of the board.
+func TestCensusObjects(t *testing.T) {
+	block := []string{"OO", "OO"}
+	beehive := []string{".OO.", "O..O", ".OO."}
+	lwss := [][]string{
+		{".O..O", "O....", "O...O", "OOOO."},
+		{".OO..", "OO.OO", ".OOOO", "..OO."},
+		{"OOOO.", "O...O", "O....", ".O..O"},
+		{"..OO.", ".OOOO", "OO.OO", ".OO.."},
+	}
+	tests := []struct {
+		name     string
+		objects  []placed
+		expected census.Census
+	}{
+		{"block", []placed{{4, 4, block}}, census.Census{"block": 1}},
+		{"beehive", []placed{{4, 4, beehive}}, census.Census{"beehive": 1}},
+		{"rotated beehive", []placed{{4, 4, []string{".O.", "O.O", "O.O", ".O."}}}, census.Census{"beehive": 1}},
+		{"blinker", []placed{{4, 4, []string{"OOO"}}}, census.Census{"blinker": 1}},
+		{"blinker phase 2", []placed{{4, 4, []string{"O", "O", "O"}}}, census.Census{"blinker": 1}},
+		{"lwss phase 1", []placed{{4, 4, lwss[0]}}, census.Census{"lightweight spaceship": 1}},
+		{"lwss phase 2", []placed{{4, 4, lwss[1]}}, census.Census{"lightweight spaceship": 1}},
+		{"lwss phase 3", []placed{{4, 4, lwss[2]}}, census.Census{"lightweight spaceship": 1}},
+		{"lwss phase 4", []placed{{4, 4, lwss[3]}}, census.Census{"lightweight spaceship": 1}},
+		{"rotated lwss", []placed{{4, 4, []string{".OOO", "O..O", "...O", "...O", "O.O."}}}, census.Census{"lightweight spaceship": 1}},
+		{"reflected glider", []placed{{4, 4, []string{".O.", "O..", "OOO"}}}, census.Census{"glider": 1}},
+		{"pentadecathlon", []placed{{3, 4, []string{"..O....O..", "OO.OOOO.OO", "..O....O.."}}}, census.Census{"pentadecathlon": 1}},
+		{"block next to a beehive", []placed{{2, 4, block}, {5, 4, beehive}}, census.Census{"block": 1, "beehive": 1}},
+		{"blocks next to each other", []placed{{2, 2, block}, {5, 2, block}, {2, 5, block}}, census.Census{"block": 3}},
+		{"lwss next to a block", []placed{{2, 4, lwss[0]}, {9, 4, block}}, census.Census{"lightweight spaceship": 1, "block": 1}},
+		{"blinker next to an lwss", []placed{{2, 2, []string{"O", "O", "O"}}, {4, 2, lwss[2]}}, census.Census{"blinker": 1, "lightweight spaceship": 1}},
+		{"block across the edges", []placed{{15, 15, block}}, census.Census{"block": 1}},
+		{"touching blocks", []placed{{2, 2, block}, {4, 2, block}}, census.Census{census.Unknown: 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			world := make([][]byte, 16)
+			for y := range world {
+				world[y] = make([]byte, 16)
+			}
+			for _, object := range test.objects {
+				for dy, row := range object.rows {
+					for dx, cell := range row {
+						if cell == 'O' {
+							world[(object.y+dy)%16][(object.x+dx)%16] = 255
+						}
+					}
+				}
+			}
+			if objects := census.Take(world); !reflect.DeepEqual(objects, test.expected) {
+				t.Errorf("Expected %v, got %v instead", test.expected, objects)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/pattern"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestPatterns reads the patterns that come with the game and checks their cells, turned round and over as well.
+func TestPatterns(t *testing.T) {
+	for _, test := range []struct {
+		path     string
+		expected []string
+		alive    int
+	}{
+		{"patterns/glider.rle", []string{".O.", "..O", "OOO"}, 5},
+		{"patterns/lwss.cells", []string{".O..O", "O....", "O...O", "OOOO."}, 9},
+		{"patterns/gosperglidergun.rle", nil, 36},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			p, err := pattern.Load(test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			alive := 0
+			for y := range p {
+				for x := range p[y] {
+					if p[y][x] {
+						alive++
+					}
+					if test.expected != nil && p[y][x] != (test.expected[y][x] == 'O') {
+						t.Fatalf("Cell (%v, %v) of %v is wrong, expected %v", x, y, test.path, test.expected)
+					}
+				}
+			}
+			if alive != test.alive {
+				t.Fatalf("%v has %v alive cells, expected %v", test.path, alive, test.alive)
+			}
+			turned := p.Rotate()
+			if turned.Width() != p.Height() || turned.Height() != p.Width() || turned[0][turned.Width()-1] != p[0][0] {
+				t.Fatalf("%v isn't turned clockwise", test.path)
+			}
+			if flipped := p.Flip(); flipped[0][0] != p[0][p.Width()-1] {
+				t.Fatalf("%v isn't mirrored", test.path)
+			}
+		})
+	}
+}
+
+// TestSetCells pauses the distributor, clears the board and stamps patterns onto it, then steps and rewinds to check
+// the edited board is what the history carries on from. The board saved with 'w' has to be the edited one, and the
+// run has to carry on from it once resumed, which is checked against Life worked out the slow way.
+func TestSetCells(t *testing.T) {
+	p := gol.Params{
+		Turns:         1000,
+		Threads:       4,
+		ImageWidth:    64,
+		ImageHeight:   64,
+		HistoryBudget: 1024 * 1024,
+	}
+	events := make(chan gol.Event, 1000)
+	keyPresses := make(chan rune, 10)
+	edits := make(chan gol.SetCells, 10)
+	gol.RunEditable(p, events, keyPresses, edits)
+
+	keyPresses <- 'p'
+	pausedOn := awaitEvent(t, events, func(e gol.Event) bool {
+		stateChange, ok := e.(gol.StateChange)
+		return ok && stateChange.NewState == gol.Paused
+	}).GetCompletedTurns()
+
+	edit := gol.SetCells{}
+	for y := 0; y < p.ImageHeight; y++ {
+		for x := 0; x < p.ImageWidth; x++ {
+			edit[util.Cell{X: x, Y: y}] = 0
+		}
+	}
+	for _, stamp := range []struct {
+		path string
+		x, y int
+		turn func(pattern.Pattern) pattern.Pattern
+	}{
+		{"patterns/glider.rle", 5, 5, func(p pattern.Pattern) pattern.Pattern { return p }},
+		{"patterns/glider.rle", 50, 10, func(p pattern.Pattern) pattern.Pattern { return p.Rotate().Flip() }},
+		{"patterns/lwss.cells", 30, 30, func(p pattern.Pattern) pattern.Pattern { return p.Rotate().Rotate() }},
+		{"patterns/gosperglidergun.rle", 2, 50, func(p pattern.Pattern) pattern.Pattern { return p }},
+	} {
+		brush, err := pattern.Load(stamp.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		brush = stamp.turn(brush)
+		for y := range brush {
+			for x := range brush[y] {
+				if brush[y][x] {
+					edit[util.Cell{X: stamp.x + x, Y: stamp.y + y}] = 255
+				}
+			}
+		}
+	}
+	expected := make([][]byte, p.ImageHeight)
+	for y := range expected {
+		expected[y] = make([]byte, p.ImageWidth)
+	}
+	for cell, level := range edit {
+		expected[cell.Y][cell.X] = level
+	}
+
+	edits <- edit
+	awaitEvent(t, events, func(e gol.Event) bool {
+		turnComplete, ok := e.(gol.TurnComplete)
+		return ok && turnComplete.CompletedTurns == pausedOn
+	})
+	count, ok := (<-events).(gol.AliveCellsCount)
+	if !ok {
+		t.Fatal("Expected the alive cells to be counted straight after editing")
+	}
+	if count.CompletedTurns != pausedOn || count.CellsCount != 5+5+9+36 {
+		t.Fatalf("After editing on turn %v expected %v alive cells, got %v on turn %v", pausedOn, 5+5+9+36, count.CellsCount, count.CompletedTurns)
+	}
+
+	keyPresses <- 'n'
+	keyPresses <- 'b'
+	keyPresses <- 'w'
+	saved := awaitEvent(t, events, func(e gol.Event) bool {
+		_, ok := e.(gol.ImageOutputComplete)
+		return ok
+	}).(gol.ImageOutputComplete)
+	if saved.CompletedTurns != pausedOn {
+		t.Fatalf("Stepping from turn %v and rewinding ended on turn %v", pausedOn, saved.CompletedTurns)
+	}
+	output := readPgm(t, "out/"+saved.Filename+".pgm")
+	for y := range expected {
+		if !bytes.Equal(output[y], expected[y]) {
+			t.Fatalf("Row %v of the edited board is %v, expected %v", y, output[y], expected[y])
+		}
+	}
+
+	keyPresses <- 'p'
+	for range events {
+	}
+	life := ltl{r: 1, sMin: 2, sMax: 3, bMin: 3, bMax: 3}
+	for turn := pausedOn; turn < p.Turns; turn++ {
+		expected = life.next(expected)
+	}
+	output = readPgm(t, fmt.Sprintf("out/%dx%dx%d.pgm", p.ImageWidth, p.ImageHeight, p.Turns))
+	for y := range expected {
+		if !bytes.Equal(output[y], expected[y]) {
+			t.Fatalf("Row %v after %v turns from the edited board is %v, expected %v", y, p.Turns, output[y], expected[y])
+		}
+	}
+}
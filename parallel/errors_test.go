@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestErrors checks images that can't be read end the run with a fatal Error event instead of panicking, and boards
+// that can't be written are reported with an Error event while the run carries on to the end.
+func TestErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	short := filepath.Join(dir, "short.pgm")
+	if err := ioutil.WriteFile(short, []byte("P5\n16 16\n255\n\xff\xff"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	notADir := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(notADir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := gol.Params{Turns: 10, Threads: 2, ImageWidth: 16, ImageHeight: 16}
+	for _, test := range []struct {
+		name  string
+		input string
+		check func(error) bool
+	}{
+		{"missing", filepath.Join(dir, "missing.pgm"), func(err error) bool { _, ok := err.(gol.IOError); return ok }},
+		{"short", short, func(err error) bool { _, ok := err.(gol.FormatError); return ok }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			p := p
+			p.Input = test.input
+			events := make(chan gol.Event, 1000)
+			gol.Run(p, events, nil)
+			var errors []gol.Error
+			for event := range events {
+				switch e := event.(type) {
+				case gol.Error:
+					errors = append(errors, e)
+				case gol.FinalTurnComplete:
+					t.Errorf("The run carried on to turn %v without a board", e.CompletedTurns)
+				}
+			}
+			if len(errors) != 1 || !errors[0].Fatal || !test.check(errors[0].Err) {
+				t.Fatalf("Reading %v gave the errors %v", test.input, errors)
+			}
+		})
+	}
+
+	t.Run("output", func(t *testing.T) {
+		p := p
+		p.OutDir = notADir
+		events := make(chan gol.Event, 1000)
+		gol.Run(p, events, nil)
+		var errors []gol.Error
+		finished := false
+		for event := range events {
+			switch e := event.(type) {
+			case gol.Error:
+				errors = append(errors, e)
+			case gol.FinalTurnComplete:
+				finished = e.CompletedTurns == p.Turns
+			}
+		}
+		if !finished {
+			t.Errorf("The run didn't finish after failing to write its board")
+		}
+		if len(errors) != 1 || errors[0].Fatal {
+			t.Fatalf("Writing the board to %v gave the errors %v", notADir, errors)
+		}
+		if _, ok := errors[0].Err.(gol.IOError); !ok {
+			t.Errorf("Writing the board to %v gave %T, expected an IOError", notADir, errors[0].Err)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestFiles runs a board read from an image outside of images, with its size read from the header, and checks it's
+// written to the output directory under the name from the output template.
+func TestFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	data, err := ioutil.ReadFile("images/64x64.pgm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join(dir, "start.pgm")
+	if err := ioutil.WriteFile(input, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	width, height, err := gol.ImageSize(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if width != 64 || height != 64 {
+		t.Fatalf("Read the size of %v as %vx%v, expected 64x64", input, width, height)
+	}
+	commented := filepath.Join(dir, "commented.pgm")
+	if err := ioutil.WriteFile(commented, []byte("P5\n# made by hand\n3 2\n255\n\x00\x00\x00\x00\x00\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if width, height, err := gol.ImageSize(commented); err != nil || width != 3 || height != 2 {
+		t.Errorf("Read the size of %v as %vx%v (%v), expected 3x2", commented, width, height, err)
+	}
+
+	for _, template := range []string{"{bogus}-{turn}", "boards/{turn}"} {
+		if gol.CheckTemplate(template) == nil {
+			t.Errorf("The output template %q was taken", template)
+		}
+	}
+	template := "{rule}-{width}x{height}-t{turn}"
+	if err := gol.CheckTemplate(template); err != nil {
+		t.Fatal(err)
+	}
+
+	p := gol.Params{
+		Turns:          100,
+		Threads:        4,
+		ImageWidth:     width,
+		ImageHeight:    height,
+		Input:          input,
+		OutDir:         filepath.Join(dir, "boards"),
+		OutputTemplate: template,
+	}
+	events := make(chan gol.Event, 1000)
+	gol.Run(p, events, nil)
+	for range events {
+	}
+	output := readPgm(t, filepath.Join(p.OutDir, "B3_S23-64x64-t100.pgm"))
+	expected := readPgm(t, "check/images/64x64x100.pgm")
+	for y := range expected {
+		if !bytes.Equal(output[y], expected[y]) {
+			t.Fatalf("Row %v is %v, expected %v", y, output[y], expected[y])
+		}
+	}
+}
@@ -3,196 +3,306 @@ package gol
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
+	"uk.ac.bris.cs/gameoflife/census"
+	"uk.ac.bris.cs/gameoflife/cycle"
+	"uk.ac.bris.cs/gameoflife/heatmap"
+	"uk.ac.bris.cs/gameoflife/history"
+	"uk.ac.bris.cs/gameoflife/rule"
+	"uk.ac.bris.cs/gameoflife/session"
+	"uk.ac.bris.cs/gameoflife/stats"
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
 const ALIVE = 255
 const DEAD = 0
 
+// A full board is kept in the history every keyframeInterval turns, the turns in between are kept as diffs.
+const keyframeInterval = 64
+
+// Cycles are only detected if they repeat within maxCyclePeriod turns, e.g. a glider crossing a 1024x1024 board.
+const maxCyclePeriod = 4096
+
 type distributorChannels struct {
 	events     chan<- Event
 	ioCommand  chan<- ioCommand
 	ioIdle     <-chan bool
+	ioError    <-chan error
 	ioFileName chan<- string
 	ioInput    <-chan uint8
 	ioOutput   chan<- uint8
 }
 
 //This buildworker function is used to create a world for a worker in each thread.(Example for running with thread 2, you need to divide the work for the worker in to two part)
-func buildWorkerWorld(world [][]byte, workerHeight, imageHeight, imageWidth, currentThreads, Threads int) [][]byte {
-	workerWorld := make([][]byte, workerHeight+2)
-	for j := range workerWorld {
-		workerWorld[j] = make([]byte, imageWidth)
+//The worker gets halo rows from the workers above and below it as well, as many as the range of the rule, starting from row start of the world.
+func buildWorkerWorld(world [][]byte, workerHeight, imageHeight, imageWidth, start, halo int) [][]byte {
+	workerWorld := make([][]byte, workerHeight+2*halo)
+	for y := range workerWorld {
+		workerWorld[y] = make([]byte, imageWidth)
+		copy(workerWorld[y], world[mod(start+y-halo, imageHeight)])
 	}
+	return workerWorld
+}
 
-	if currentThreads == Threads-1 {
-		workerHeight1 := workerHeight - imageHeight%Threads
-		for x := 0; x < imageWidth; x++ {
-			workerWorld[0][x] = world[(currentThreads*workerHeight1+imageHeight-1)%imageHeight][x]
-		}
-		for y := 1; y <= workerHeight; y++ {
-			for x := 0; x < imageWidth; x++ {
-				workerWorld[y][x] = world[currentThreads*workerHeight1+y-1][x]
-			}
-		}
-		for x := 0; x < imageWidth; x++ {
-			workerWorld[workerHeight+1][x] = world[0][x]
-		}
-	} else {
-		for x := 0; x < imageWidth; x++ {
-			workerWorld[0][x] = world[(currentThreads*workerHeight+imageHeight-1)%imageHeight][x]
-		}
-		for y := 1; y <= workerHeight; y++ {
-			for x := 0; x < imageWidth; x++ {
-				workerWorld[y][x] = world[currentThreads*workerHeight+y-1][x]
-			}
-		}
-		for x := 0; x < imageWidth; x++ {
-			workerWorld[workerHeight+1][x] = world[((currentThreads+1)*workerHeight+imageHeight)%imageHeight][x]
-		}
+// Function to wrap a row or column index round the edge of the world
+func mod(x, m int) int {
+	return (x%m + m) % m
+}
 
+// Returns the number of rows the world is stored in, with the slices of a volume one after the other.
+func worldHeight(p Params) int {
+	if p.ImageDepth > 1 {
+		return p.ImageHeight * p.ImageDepth
 	}
-
-	return workerWorld
+	return p.ImageHeight
 }
 
-// Function to find out a alive neighbor of the cell
-func mod(x, m int) int {
-	return (x + m) % m
+// Returns the number of rows each thread but the last works on, which is a whole number of slices of a volume.
+// The last thread takes the remaining rows as well.
+func threadHeight(p Params, r rule.Rule) int {
+	slice := rule.Slice(r)
+	return worldHeight(p) / slice / p.Threads * slice
 }
 
-func calculateNeighbours(p Params, x, y int, world [][]byte) int {
-	neighbours := 0
-	for i := -1; i <= 1; i++ {
-		for j := -1; j <= 1; j++ {
-			if i != 0 || j != 0 {
-				if world[mod(y+i, p.ImageHeight)][mod(x+j, p.ImageWidth)] == ALIVE {
-					neighbours++
-				}
-			}
-		}
+// Returns the name of the image of the world, with the depth after the height and width if it's a volume.
+func imageName(p Params) string {
+	if p.ImageDepth > 1 {
+		return fmt.Sprintf("%vx%vx%v", p.ImageHeight, p.ImageWidth, p.ImageDepth)
 	}
-	return neighbours
+	return fmt.Sprintf("%vx%v", p.ImageHeight, p.ImageWidth)
 }
 
 //Worker is the function that used to calculate the logic of the program and giving each byte of newWorld to distributor for finalComplete turn channel.
-func worker(c distributorChannels, p Params, workerChan chan byte, imageHeight int, imageWidth int, outChan chan byte, Thread, currentThread int) {
+func worker(c distributorChannels, p Params, r rule.Rule, workerChan chan byte, imageHeight int, imageWidth int, outChan chan byte, Thread, currentThread, turn int) {
 
-	world := make([][]byte, imageHeight+2)
+	halo := r.Halo()
+	world := make([][]byte, imageHeight+2*halo)
 	for i := range world {
 		world[i] = make([]byte, imageWidth)
 	}
-	for y := 0; y < imageHeight+2; y++ {
+	for y := 0; y < imageHeight+2*halo; y++ {
 		for x := 0; x < imageWidth; x++ {
 			world[y][x] = <-workerChan
 		}
 	}
 
-	newWorld := make([][]byte, imageHeight+2)
-	for i := range world {
-		newWorld[i] = make([]byte, imageWidth)
-	}
-	//we don't need to care about the first row, cause we need to ignore every first role.
-	for y := 1; y <= imageHeight; y++ {
+	//The rule computes the rows between the halo rows, whatever its neighbourhood is.
+	newWorld := r.Step(world, currentThread*threadHeight(p, r), turn)
+	for y := 0; y < imageHeight; y++ {
 		for x := 0; x < imageWidth; x++ {
-			var neighboursAlive = 0
-			neighboursAlive = calculateNeighbours(p, x, y, world)
-			if world[y][x] == ALIVE {
-				if neighboursAlive == 2 || neighboursAlive == 3 {
-					newWorld[y][x] = ALIVE
-				} else {
-					newWorld[y][x] = DEAD
-					c.events <- CellFlipped{p.Turns, util.Cell{X: x, Y: currentThread*imageHeight + y - 1}}
-
-				}
-			} else {
-				if neighboursAlive == 3 {
-					newWorld[y][x] = ALIVE
-					c.events <- CellFlipped{p.Turns, util.Cell{X: x, Y: currentThread*imageHeight + y - 1}}
-				} else {
-					newWorld[y][x] = DEAD
-				}
-
+			if newWorld[y][x] != world[y+halo][x] {
+				//The last worker takes the remaining rows as well, so the offset comes from the height of the other workers.
+				c.events <- CellFlipped{p.Turns, util.Cell{X: x, Y: currentThread*threadHeight(p, r) + y}, newWorld[y][x]}
 			}
 		}
 	}
-	//Here is where we ignore the first and the last row.
 	for y := 0; y < imageHeight; y++ {
 		for x := 0; x < imageWidth; x++ {
-			outChan <- newWorld[y+1][x]
+			outChan <- newWorld[y][x]
 		}
 	}
 
 }
 
 // distributor divides the work between workers and interacts with other goroutines.
-func distributor(p Params, c distributorChannels, keyPresses <-chan rune) {
+func distributor(p Params, c distributorChannels, keyPresses <-chan rune, edits <-chan SetCells) {
 	var FinalTurnComplete FinalTurnComplete
 	var mutex sync.Mutex
-	//Sednding signal to the IO to input the pgm file
-	c.ioCommand <- ioInput
-	c.ioFileName <- fmt.Sprintf("%vx%v", p.ImageHeight, p.ImageWidth)
+	eventsClosed := false // guarded by mutex, so the ticker never sends once the events channel has been closed
+	//Carry on from a saved session if there is one, otherwise send the signal to the IO to input the pgm file.
+	//The run can't carry on without a board, so it ends with an Error event if there isn't one.
+	var saved session.Session
+	var err error
+	if p.Session != "" {
+		saved, err = loadSession(p.Session)
+		if err == nil && (saved.Width != p.ImageWidth || saved.Height != worldHeight(p)) {
+			err = FormatError{Path: p.Session, Problem: fmt.Sprintf("is %vx%v, expected %vx%v", saved.Width, saved.Height, p.ImageWidth, worldHeight(p))}
+		}
+	} else {
+		c.ioCommand <- ioInput
+		c.ioFileName <- imageName(p)
+		err = <-c.ioError
+	}
+	if err != nil {
+		fail(c, saved.Turn, err)
+		return
+	}
 
 	var listCell []util.Cell
 
-	//Create a 2D slice to store the world.
-	world := make([][]byte, p.ImageHeight)
+	//Create a 2D slice to store the world, with the slices of a volume one after the other.
+	world := make([][]byte, worldHeight(p))
 	for i := range world {
 		world[i] = make([]byte, p.ImageWidth)
 	}
-	newWorld := make([][]byte, p.ImageHeight)
+	newWorld := make([][]byte, worldHeight(p))
 	for i := range world {
 		newWorld[i] = make([]byte, p.ImageWidth)
 	}
 	ticker := time.NewTicker(2 * time.Second)
 
-	//For all initially alive cells send a CellFlipped Event.
-	for y := 0; y < p.ImageHeight; y++ {
+	//The grey levels of the image are snapped onto the states of the rule.
+	r := ruleFor(p)
+	p.Seed = seed(p, r)
+	r = seeded(p, r)
+
+	//For all initially alive cells, or cells in refractory states, send a CellFlipped Event.
+	turn := saved.Turn
+	for y := 0; y < worldHeight(p); y++ {
 		for x := 0; x < p.ImageWidth; x++ {
-			input := <-c.ioInput
-			if input == ALIVE {
-				c.events <- CellFlipped{CompletedTurns: 0, Cell: util.Cell{X: x, Y: y}}
+			var input byte
+			if saved.World != nil {
+				input = r.Quantise(saved.World[y][x])
+			} else {
+				input = r.Quantise(<-c.ioInput)
+			}
+			if input != DEAD {
+				c.events <- CellFlipped{CompletedTurns: turn, Cell: util.Cell{X: x, Y: y}, State: input}
 			}
 			world[y][x] = input
 
 		}
 	}
 
-	turn := 0
+	paused := false
+	stepsLeft := 0 // turns left to compute while paused, after a step key was pressed
+
+	//Keep the recent turns so the run can be rewound, if there is a memory budget for them.
+	var hist *history.History
+	if p.HistoryBudget > 0 {
+		hist = history.New(p.HistoryBudget, keyframeInterval, r.Levels())
+		hist.Record(turn, world)
+	}
+
+	//Hash every turn to find out when the board settles into a still life or an oscillator.
+	detector := cycle.New(maxCyclePeriod)
+	detector.Add(turn, cycle.Hash(world))
+	var settled *cycle.Cycle
+
+	//Write the metrics of every turn out, if there is a file for them, once the run can't be rewound past the turn.
+	var statsWriter *pendingStats
+	if p.StatsFile != "" {
+		statsFile, err := os.Create(p.StatsFile)
+		if err != nil {
+			c.events <- Error{CompletedTurns: turn, Err: IOError{Op: "write", Path: p.StatsFile, Err: err}}
+		} else {
+			defer statsFile.Close()
+			statsWriter = &pendingStats{writer: stats.NewWriter(statsFile, strings.HasSuffix(p.StatsFile, ".jsonl"), statsRegions(p))}
+		}
+	}
+
+	//Count how often each cell is alive and flips, if heat maps were asked for.
+	var heat heatmap.Counters
+	if p.HeatMap {
+		heat = heatmap.New(worldHeight(p), p.ImageWidth)
+	}
 
 	for turn < p.Turns {
 
-		select {
-		// case <-ticker.C:
-		case keyPress := <-keyPresses:
-			if keyPress == 's' {
-				printBoard(c, p, world, turn)
-			} else if keyPress == 'q' {
-				printBoard(c, p, world, turn)
-				fmt.Println("Terminated.")
-				os.Exit(3)
-			} else if keyPress == 'p' {
-				// fmt.Println(turns)
+		var keyPress rune
+		var edit SetCells
+		if paused && stepsLeft == 0 {
+			// Nothing has to be computed while paused, so block until the next key press or edit
+			select {
+			case keyPress = <-keyPresses:
+			case edit = <-edits:
+			}
+		} else {
+			select {
+			case keyPress = <-keyPresses:
+			case edit = <-edits:
+			default:
+			}
+		}
+		if edit != nil {
+			//The board is only edited between turns while paused, and the history and cycle detection carry on from the edited board.
+			if !paused {
+				fmt.Println("Pause before editing.")
+			} else {
+				setCells(c, p, r, world, turn, edit)
+				if hist != nil {
+					hist.Record(turn, world)
+				}
+				detector.Truncate(turn - 1)
+				detector.Add(turn, cycle.Hash(world))
+				settled = nil
+			}
+		}
+		switch keyPress {
+		case 's':
+			printBoard(c, p, world, turn)
+		case 'w':
+			saveEdited(c, p, world, turn)
+		case 'e':
+			saveSession(c, p, world, turn)
+		case 'c':
+			c.events <- CensusComplete{CompletedTurns: turn, Objects: census.Take(world)}
+		case 'h':
+			if p.HeatMap {
+				exportHeatMaps(c, p, heat, turn)
+			} else {
+				fmt.Println("Heat maps are only kept when running with -heatmap.")
+			}
+		case 'q':
+			printBoard(c, p, world, turn)
+			if statsWriter != nil {
+				statsWriter.flush()
+			}
+			fmt.Println("Terminated.")
+			os.Exit(3)
+		case 'p':
+			paused = !paused
+			stepsLeft = 0
+			if paused {
 				fmt.Println("Pausing.")
-				for {
-					tempKey := <-keyPresses
-					if tempKey == 'p' {
-						fmt.Println("Proceeding.")
-						break
-					}
+				c.events <- StateChange{turn, Paused}
+			} else {
+				fmt.Println("Proceeding.")
+				c.events <- StateChange{turn, Executing}
+			}
+		case 'n', 'm':
+			// Step one turn with 'n', or p.StepTurns turns with 'm'
+			if !paused {
+				fmt.Println("Pause before stepping.")
+			} else if keyPress == 'n' {
+				stepsLeft = 1
+			} else {
+				stepsLeft = stepTurns(p)
+			}
+		case 'b', 'v', 'g':
+			// Rewind one turn with 'b', p.StepTurns turns with 'v', or seek to the oldest retained turn with 'g'
+			if !paused {
+				fmt.Println("Pause before rewinding.")
+				break
+			}
+			target := turn - 1
+			if keyPress == 'v' {
+				target = turn - stepTurns(p)
+			} else if keyPress == 'g' && hist != nil {
+				target = hist.Oldest()
+			}
+			if restored, ok := seek(c, p, hist, heat, world, turn, target); ok {
+				turn = restored
+				if statsWriter != nil {
+					statsWriter.truncate(turn)
 				}
+				detector.Truncate(turn)
+				settled = nil
 			}
-		default:
+		}
+		if paused && stepsLeft == 0 {
+			continue
 		}
 
 		go func() {
 			select {
 			case <-ticker.C:
 				var aliveCell int
-				for y := 0; y < p.ImageHeight; y++ {
+				for y := 0; y < worldHeight(p); y++ {
 					for x := 0; x < p.ImageWidth; x++ {
 						if world[y][x] == ALIVE {
 							aliveCell++
@@ -201,7 +311,9 @@ func distributor(p Params, c distributorChannels, keyPresses <-chan rune) {
 				}
 				//This mutex lock is used for locking event to not closing at line 278 because we get don't lock c.events will close the next two second when we try to send new aliveCell event, it will get closed channel.
 				mutex.Lock()
-				c.events <- AliveCellsCount{turn, aliveCell}
+				if !eventsClosed {
+					c.events <- AliveCellsCount{turn, aliveCell}
+				}
 				mutex.Unlock()
 			default:
 			}
@@ -210,17 +322,17 @@ func distributor(p Params, c distributorChannels, keyPresses <-chan rune) {
 
 		var workerHeight int
 		outChan := make([]chan byte, p.Threads)
-		workerHeight = p.ImageHeight / p.Threads
+		workerHeight = threadHeight(p, r)
 		// modOfWorkerHeight := p.ImageWidth % p.Threads
 		for i := 0; i < p.Threads; i++ {
 			outChan[i] = make(chan byte)
 			workerChan := make(chan byte)
 			//To check if it is on the last worker and if it is true, we can add all the remaining work to last worker.
 			if i == p.Threads-1 {
-				workerHeight1 := (p.ImageHeight / p.Threads) + (p.ImageHeight % p.Threads)
-				workerWorld := buildWorkerWorld(world, workerHeight1, p.ImageHeight, p.ImageWidth, i, p.Threads)
-				go worker(c, p, workerChan, workerHeight1, p.ImageWidth, outChan[i], p.Threads, i)
-				for y := 0; y < workerHeight1+2; y++ {
+				workerHeight1 := worldHeight(p) - (p.Threads-1)*workerHeight
+				workerWorld := buildWorkerWorld(world, workerHeight1, worldHeight(p), p.ImageWidth, i*workerHeight, r.Halo())
+				go worker(c, p, r, workerChan, workerHeight1, p.ImageWidth, outChan[i], p.Threads, i, turn)
+				for y := 0; y < workerHeight1+2*r.Halo(); y++ {
 					for x := 0; x < p.ImageWidth; x++ {
 						workerChan <- workerWorld[y][x]
 					}
@@ -231,9 +343,9 @@ func distributor(p Params, c distributorChannels, keyPresses <-chan rune) {
 					}
 				}
 			} else {
-				workerWorld := buildWorkerWorld(world, workerHeight, p.ImageHeight, p.ImageWidth, i, p.Threads)
-				go worker(c, p, workerChan, workerHeight, p.ImageWidth, outChan[i], p.Threads, i)
-				for y := 0; y < workerHeight+2; y++ {
+				workerWorld := buildWorkerWorld(world, workerHeight, worldHeight(p), p.ImageWidth, i*workerHeight, r.Halo())
+				go worker(c, p, r, workerChan, workerHeight, p.ImageWidth, outChan[i], p.Threads, i, turn)
+				for y := 0; y < workerHeight+2*r.Halo(); y++ {
 					for x := 0; x < p.ImageWidth; x++ {
 						workerChan <- workerWorld[y][x]
 					}
@@ -252,13 +364,42 @@ func distributor(p Params, c distributorChannels, keyPresses <-chan rune) {
 		world = newWorld
 		newWorld = x
 		turn++
-	}
-	for y := 0; y < p.ImageHeight; y++ {
-		for x := 0; x < p.ImageWidth; x++ {
-			if world[y][x] == ALIVE {
-				listCell = append(listCell, util.Cell{Y: y, X: x})
+		if statsWriter != nil {
+			statsWriter.add(stats.Measure(turn, newWorld, world, statsRegions(p)), hist)
+		}
+		if p.HeatMap {
+			heat.Add(newWorld, world)
+		}
+		if hist != nil {
+			hist.Record(turn, world)
+		}
+		if settled == nil {
+			if found, ok := detector.Add(turn, cycle.Hash(world)); ok {
+				settled = &found
+				c.events <- CycleDetected{CompletedTurns: turn, Period: found.Period, Start: found.Start}
+				if p.StopOnCycle {
+					break
+				}
 			}
 		}
+
+		//Report the alive cells once all the turns of a step have been computed.
+		if stepsLeft > 0 {
+			stepsLeft--
+			if stepsLeft == 0 {
+				c.events <- AliveCellsCount{turn, len(calculateAliveCells(p, world))}
+			}
+		}
+	}
+	listCell = calculateAliveCells(p, world)
+	if statsWriter != nil {
+		statsWriter.flush()
+	}
+	if settled != nil {
+		fmt.Printf("Settled into a cycle of period %v from turn %v\n", settled.Period, settled.Start)
+		if p.StopOnCycle {
+			fmt.Println("Stopped early on turn", turn)
+		}
 	}
 
 	FinalTurnComplete.Alive = listCell
@@ -267,6 +408,9 @@ func distributor(p Params, c distributorChannels, keyPresses <-chan rune) {
 
 	//Print the board for all testing round to pass all pgm test
 	printBoard(c, p, world, turn)
+	if p.HeatMap {
+		exportHeatMaps(c, p, heat, turn)
+	}
 
 	// Make sure that the Io has finished any output before exiting.
 	c.ioCommand <- ioCheckIdle
@@ -276,22 +420,268 @@ func distributor(p Params, c distributorChannels, keyPresses <-chan rune) {
 	c.events <- FinalTurnComplete
 	c.events <- StateChange{turn, Quitting}
 	// Close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
+	mutex.Lock()
+	ticker.Stop()
+	eventsClosed = true
 	close(c.events)
+	mutex.Unlock()
+}
+
+// Restores the world to the given turn from the history, going back as far as the oldest retained turn.
+// The GUI is told about every cell that differs between the current and the restored world, and the turns rewound past
+// are taken back out of the heat maps.
+func seek(c distributorChannels, p Params, hist *history.History, heat heatmap.Counters, world [][]byte, turn, target int) (int, bool) {
+	if hist == nil {
+		fmt.Println("History is disabled.")
+		return turn, false
+	}
+	if target < hist.Oldest() {
+		target = hist.Oldest()
+	}
+	restored, ok := hist.Get(target)
+	if !ok || target > turn {
+		fmt.Println("Turn", target, "is not retained.")
+		return turn, false
+	}
+	for y := 0; y < worldHeight(p); y++ {
+		for x := 0; x < p.ImageWidth; x++ {
+			if world[y][x] != restored[y][x] {
+				world[y][x] = restored[y][x]
+				c.events <- CellFlipped{target, util.Cell{X: x, Y: y}, restored[y][x]}
+			}
+		}
+	}
+	if p.HeatMap {
+		after, _ := hist.Get(turn)
+		for t := turn; t > target; t-- {
+			before, _ := hist.Get(t - 1)
+			heat.Remove(before, after)
+			after = before
+		}
+	}
+	hist.Truncate(target)
+	fmt.Println("Rewound to turn", target)
+	c.events <- TurnComplete{CompletedTurns: target}
+	c.events <- AliveCellsCount{target, len(calculateAliveCells(p, world))}
+	return target, true
+}
+
+// Holds the metrics of the turns the run can still be rewound past, so rewinding discards them along with the turns, and
+// writes them out once the turns have dropped out of the history.
+type pendingStats struct {
+	writer *stats.Writer
+	turns  []stats.Turn
+}
+
+// Adds the metrics of a turn, writing out those of every turn that can't be rewound past any more.
+func (s *pendingStats) add(t stats.Turn, hist *history.History) {
+	s.turns = append(s.turns, t)
+	written := 0
+	for _, pending := range s.turns {
+		if hist != nil && pending.CompletedTurns > hist.Oldest() {
+			break
+		}
+		s.writer.Write(pending)
+		written++
+	}
+	s.turns = s.turns[written:]
+}
+
+// Discards the metrics of every turn after the given turn, once the run has been rewound to it.
+func (s *pendingStats) truncate(turn int) {
+	for len(s.turns) > 0 && s.turns[len(s.turns)-1].CompletedTurns > turn {
+		s.turns = s.turns[:len(s.turns)-1]
+	}
+}
+
+// Writes out the metrics of every turn, e.g. once the run has finished.
+func (s *pendingStats) flush() {
+	for _, pending := range s.turns {
+		s.writer.Write(pending)
+	}
+	s.turns = nil
+	s.writer.Flush()
+}
+
+// Sets cells of the world to the grey levels of an edit, snapped onto the states of the rule, ignoring cells outside
+// the world. The GUI is told about every cell that changes.
+func setCells(c distributorChannels, p Params, r rule.Rule, world [][]byte, turn int, edit SetCells) {
+	for cell, level := range edit {
+		if cell.X < 0 || cell.X >= p.ImageWidth || cell.Y < 0 || cell.Y >= worldHeight(p) {
+			continue
+		}
+		level = r.Quantise(level)
+		if world[cell.Y][cell.X] != level {
+			world[cell.Y][cell.X] = level
+			c.events <- CellFlipped{turn, cell, level}
+		}
+	}
+	c.events <- TurnComplete{CompletedTurns: turn}
+	c.events <- AliveCellsCount{turn, len(calculateAliveCells(p, world))}
+}
+
+// Returns the number of turns to compute when stepping with 'm', defaulting to 10.
+func stepTurns(p Params) int {
+	if p.StepTurns > 0 {
+		return p.StepTurns
+	}
+	return 10
+}
+
+// Returns the rule given in the params on the topology given in the params, falling back to Life if the rule can't be
+// parsed or doesn't suit the depth of the world, and to square cells if it can't run on the topology.
+func ruleFor(p Params) rule.Rule {
+	r, err := rule.Parse(p.Rule)
+	if err == nil {
+		r, err = rule.InVolume(r, p.ImageHeight, p.ImageDepth)
+	}
+	if err != nil {
+		fmt.Println(err, "- running Life instead.")
+		r = rule.Life
+	}
+	topology, err := rule.ParseTopology(p.Topology)
+	if err == nil {
+		err = topology.Check(p.ImageWidth, p.ImageHeight)
+	}
+	if err == nil {
+		var onTopology rule.Rule
+		if onTopology, err = rule.OnTopology(r, topology); err == nil {
+			return onTopology
+		}
+	}
+	fmt.Println(err, "- running on square cells instead.")
+	return r
+}
+
+// Returns the seed stochastic rules draw their random numbers from, which is picked at random and printed so the run
+// can be repeated if there isn't one in the params.
+func seed(p Params, r rule.Rule) int64 {
+	if _, ok := r.(rule.Stochastic); !ok || p.Seed != 0 {
+		return p.Seed
+	}
+	seed := time.Now().UnixNano()
+	fmt.Println("Seed:", seed)
+	return seed
+}
+
+// Returns the rule drawing its random numbers from the seed in the params if it's stochastic.
+func seeded(p Params, r rule.Rule) rule.Rule {
+	if _, ok := r.(rule.Stochastic); !ok {
+		return r
+	}
+	return rule.Seeded(r, p.Seed)
+}
+
+// Returns the number of regions along each side of the grid the density is measured in, defaulting to 4.
+func statsRegions(p Params) int {
+	if p.StatsRegions > 0 {
+		return p.StatsRegions
+	}
+	return 4
+}
+
+// Returns every alive cell in the world.
+func calculateAliveCells(p Params, world [][]byte) []util.Cell {
+	var listCell []util.Cell
+	for y := 0; y < worldHeight(p); y++ {
+		for x := 0; x < p.ImageWidth; x++ {
+			if world[y][x] == ALIVE {
+				listCell = append(listCell, util.Cell{Y: y, X: x})
+			}
+		}
+	}
+	return listCell
 }
 
 //Give signal to the IO to output the new pgm file of newState of pgm
 func printBoard(d distributorChannels, p Params, world [][]byte, turn int) {
 
 	d.ioCommand <- ioOutput
-	d.ioFileName <- fmt.Sprintf("%vx%vx%v", p.ImageHeight, p.ImageWidth, turn)
+	d.ioFileName <- OutputName(p, turn)
 
-	for y := 0; y < p.ImageHeight; y++ {
+	for y := 0; y < worldHeight(p); y++ {
 		for x := 0; x < p.ImageWidth; x++ {
 			d.ioOutput <- world[y][x]
 		}
 	}
 
-	d.ioCommand <- ioCheckIdle
-	<-d.ioIdle
+	if err := <-d.ioError; err != nil {
+		d.events <- Error{CompletedTurns: turn, Err: err}
+	}
+}
 
+// Give signal to the IO to output the board after it's been edited, named apart from the boards of the run.
+func saveEdited(d distributorChannels, p Params, world [][]byte, turn int) {
+	filename := OutputName(p, turn) + "-edited"
+	d.ioCommand <- ioOutput
+	d.ioFileName <- filename
+	for y := 0; y < worldHeight(p); y++ {
+		for x := 0; x < p.ImageWidth; x++ {
+			d.ioOutput <- world[y][x]
+		}
+	}
+	if err := <-d.ioError; err != nil {
+		d.events <- Error{CompletedTurns: turn, Err: err}
+		return
+	}
+	d.events <- ImageOutputComplete{CompletedTurns: turn, Filename: filename}
+}
+
+// Send an error the run can't carry on from, and end the run by closing the events channel.
+func fail(d distributorChannels, turn int, err error) {
+	d.events <- Error{CompletedTurns: turn, Err: err, Fatal: true}
+	d.events <- StateChange{turn, Quitting}
+	close(d.events)
+}
+
+// Save the run to a session file, with the params, rule, topology and seed it needs to carry on from this turn.
+func saveSession(d distributorChannels, p Params, world [][]byte, turn int) {
+	saved, err := newSession(p, world, turn)
+	if err != nil {
+		fmt.Println("Not saving the session:", err)
+		return
+	}
+	filename := filepath.Join(OutputDir(p), OutputName(p, turn)+"-session.tar.gz")
+	if err = session.Save(filename, saved); err != nil {
+		d.events <- Error{CompletedTurns: turn, Err: IOError{Op: "write", Path: filename, Err: err}}
+		return
+	}
+	d.events <- SessionOutputComplete{CompletedTurns: turn, Filename: filename}
+}
+
+// Export the heat maps of how often each cell was alive and how often it flipped, each as a normalised PGM through the
+// IO and as a false-colour PNG next to it.
+func exportHeatMaps(d distributorChannels, p Params, heat heatmap.Counters, turn int) {
+	var filenames []string
+	for _, kind := range []struct {
+		name   string
+		counts [][]uint32
+	}{{"alive", heat.Alive}, {"flips", heat.Flips}} {
+		filename := fmt.Sprintf("%v-%v-heat", OutputName(p, turn), kind.name)
+		grey := heatmap.Normalise(kind.counts)
+		d.ioCommand <- ioOutput
+		d.ioFileName <- filename
+		for y := 0; y < worldHeight(p); y++ {
+			for x := 0; x < p.ImageWidth; x++ {
+				d.ioOutput <- grey[y][x]
+			}
+		}
+		if err := <-d.ioError; err != nil {
+			d.events <- Error{CompletedTurns: turn, Err: err}
+			continue
+		}
+
+		path := filepath.Join(OutputDir(p), filename+".png")
+		file, err := os.Create(path)
+		if err == nil {
+			err = heatmap.WritePNG(file, grey)
+			file.Close()
+		}
+		if err != nil {
+			d.events <- Error{CompletedTurns: turn, Err: IOError{Op: "write", Path: path, Err: err}}
+			continue
+		}
+		filenames = append(filenames, filename)
+	}
+	d.events <- HeatMapOutputComplete{CompletedTurns: turn, Filenames: filenames}
 }
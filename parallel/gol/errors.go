@@ -0,0 +1,32 @@
+package gol
+
+import (
+	"fmt"
+	"os"
+)
+
+// IOError is an error reading or writing a file, e.g. an image that isn't there or an output directory that can't be
+// written to.
+type IOError struct {
+	Op   string // what was being done to the file, read or write
+	Path string
+	Err  error
+}
+
+func (e IOError) Error() string {
+	err := e.Err
+	if pathError, ok := err.(*os.PathError); ok {
+		err = pathError.Err // the path is already in the message
+	}
+	return fmt.Sprintf("can't %v %v: %v", e.Op, e.Path, err)
+}
+
+// FormatError is an error in what's in a file, e.g. an image that isn't a PGM or isn't the size of the world.
+type FormatError struct {
+	Path    string
+	Problem string
+}
+
+func (e FormatError) Error() string {
+	return fmt.Sprintf("%v %v", e.Path, e.Problem)
+}
@@ -0,0 +1,115 @@
+package gol
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/rule"
+)
+
+// placeholder matches the placeholders of an output template, e.g. {turn}.
+var placeholder = regexp.MustCompile(`{[^{}]*}`)
+
+// placeholders are the placeholders OutputName fills in.
+var placeholders = []string{"{turn}", "{width}", "{height}", "{depth}", "{rule}", "{time}"}
+
+// OutputName gets the name a board of the run is output under after a number of turns, without the directory and
+// extension. It's filled in from the output template in the params, or is the height, width and turn, e.g.
+// 512x512x100, if there isn't one.
+func OutputName(p Params, turn int) string {
+	if p.OutputTemplate == "" {
+		return fmt.Sprintf("%vx%v", imageName(p), turn)
+	}
+	depth := p.ImageDepth
+	if depth < 1 {
+		depth = 1
+	}
+	ruleName := rule.Life.String()
+	if r, err := rule.Parse(p.Rule); err == nil {
+		ruleName = r.String()
+	}
+	return strings.NewReplacer(
+		"{turn}", strconv.Itoa(turn),
+		"{width}", strconv.Itoa(p.ImageWidth),
+		"{height}", strconv.Itoa(p.ImageHeight),
+		"{depth}", strconv.Itoa(depth),
+		"{rule}", fileSafe(ruleName),
+		"{time}", time.Now().Format("20060102-150405"),
+	).Replace(p.OutputTemplate)
+}
+
+// CheckTemplate checks an output template only has the placeholders OutputName fills in, and names files rather than
+// directories.
+func CheckTemplate(template string) error {
+	for _, found := range placeholder.FindAllString(template, -1) {
+		known := false
+		for _, name := range placeholders {
+			known = known || found == name
+		}
+		if !known {
+			return fmt.Errorf("%v is not a placeholder, which are %v", found, strings.Join(placeholders, ", "))
+		}
+	}
+	if strings.ContainsAny(template, `/\`) {
+		return fmt.Errorf("the output template %q names a directory, which is given as the output directory", template)
+	}
+	return nil
+}
+
+// fileSafe replaces the characters of a rule that can't be in filenames, e.g. B3/S23 becomes B3_S23.
+func fileSafe(name string) string {
+	return strings.Map(func(c rune) rune {
+		if strings.ContainsRune(`/\:*?"<>| `, c) {
+			return '_'
+		}
+		return c
+	}, name)
+}
+
+// OutputDir gets the directory output is written to, which is out if there isn't one in the params, making it if it
+// isn't there yet.
+func OutputDir(p Params) string {
+	dir := p.OutDir
+	if dir == "" {
+		dir = "out"
+	}
+	_ = os.MkdirAll(dir, os.ModePerm)
+	return dir
+}
+
+// ImageSize reads the width and height of a PGM image from its header, so they don't have to be given as well.
+// Comments in the header are skipped.
+func ImageSize(path string) (width, height int, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	var fields []string
+	for len(fields) < 3 {
+		line, err := reader.ReadString('\n')
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields = append(fields, strings.Fields(line)...)
+		if err != nil && len(fields) < 3 {
+			return 0, 0, fmt.Errorf("%v has no PGM header", path)
+		}
+	}
+	if fields[0] != "P5" {
+		return 0, 0, fmt.Errorf("%v is not a binary PGM image", path)
+	}
+	if width, err = strconv.Atoi(fields[1]); err == nil {
+		height, err = strconv.Atoi(fields[2])
+	}
+	if err != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("%v doesn't have the size of an image in its header", path)
+	}
+	return width, height, nil
+}
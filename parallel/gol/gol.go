@@ -1,5 +1,7 @@
 package gol
 
+import "uk.ac.bris.cs/gameoflife/util"
+
 // Test comment by Anton
 // Test comment by ly
 // Git is finally working :)
@@ -10,29 +12,104 @@ type Params struct {
 	Threads     int
 	ImageWidth  int
 	ImageHeight int
+	StepTurns   int
+
+	// HistoryBudget is the number of bytes used to keep recent turns for rewinding. History is disabled if it's 0.
+	HistoryBudget int
+
+	// StopOnCycle stops the run early once the board settles into a still life or an oscillator.
+	StopOnCycle bool
+
+	// StatsFile is where the metrics of every turn are written, as JSON Lines if it ends in .jsonl and as CSV otherwise.
+	// Nothing is written if it's empty.
+	StatsFile string
+
+	// StatsRegions is the number of regions along each side of the grid that the density is measured in, defaulting to 4.
+	StatsRegions int
+
+	// Rule is the rule to run in B/S/C notation, e.g. B2/S/C3 for Brian's Brain or B2n3/S23-q with Hensel's non-totalistic
+	// letters, in LtL notation for Larger than Life rules, e.g. R5,C0,M1,S34..58,B34..45,NM, or the name of another
+	// cellular automaton, e.g. Wireworld, 3d:4555 for a 3D rule in Bays' notation or stochastic:B3/S23,birth=0.9,update=0.5
+	// for a rule where births, survivals and updates only happen with a chance. It's Life if empty.
+	Rule string
+
+	// Topology is the lattice the cells are laid out on: square, hex or triangular. Hex worlds are stored in PGM images
+	// row by row like square ones, with the odd rows standing for the rows shifted half a cell to the right. It's
+	// square if empty, and only totalistic life-like rules run on the other lattices.
+	Topology string
+
+	// ImageDepth is the number of slices of a volume for 3D rules, which are stored one after the other in images and
+	// events, so a volume is ImageWidth by ImageHeight*ImageDepth cells with slice z starting at row z*ImageHeight. The
+	// world is flat if it's 0 or 1.
+	ImageDepth int
+
+	// Seed is what stochastic rules draw their random numbers from, so runs with the same seed give the same results
+	// whatever the number of threads. It's picked at random and printed if it's 0.
+	Seed int64
+
+	// HeatMap counts how often each cell is alive and flips, so heat maps can be exported with 'h' and at the end of the run.
+	HeatMap bool
+
+	// Brush is an RLE or .cells pattern file the SDL window stamps onto the board with the right mouse button. It's a
+	// glider if empty.
+	Brush string
+
+	// Theme is the colour scheme the SDL window shows cells in and exports them in: classic, ocean, fire or paper.
+	// It's classic if empty, which shows the states of a rule in the rule's own colours.
+	Theme string
+
+	// Ages colours cells in the SDL window by the number of turns since they last changed state instead of by state.
+	Ages bool
+
+	// Session is a session file saved with 'e' that the run carries on from, on the turn it was saved on, instead of
+	// starting from an image. The rest of the params have to be the ones it was saved with, which LoadSession gets.
+	Session string
+
+	// Input is the PGM image the board is read from. It's images/<width>x<height>.pgm if empty.
+	Input string
+
+	// OutDir is the directory images, heat maps and sessions are written to. It's out if empty.
+	OutDir string
+
+	// OutputTemplate is the name boards are output under, without the extension, where {turn}, {width}, {height},
+	// {depth}, {rule} and {time} are filled in, e.g. {rule}-{width}x{height}-t{turn}. It's <height>x<width>x<turn> if
+	// empty, with the depth after the width for volumes.
+	OutputTemplate string
 }
 
+// SetCells sets cells of the board to grey levels while the game is paused, e.g. cells drawn in the SDL window. The
+// levels are snapped onto the states of the rule, and a CellFlipped event is sent for each cell that changes.
+type SetCells map[util.Cell]uint8
+
 // Run starts the processing of Game of Life. It should initialise channels and goroutines.
 func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
+	RunEditable(p, events, keyPresses, nil)
+}
+
+// RunEditable starts the processing of Game of Life like Run, taking edits of the board while it's paused as well.
+func RunEditable(p Params, events chan<- Event, keyPresses <-chan rune, edits <-chan SetCells) {
 	ioFileName := make(chan string)
 	iOInput := make(chan uint8)
 	iOOutput := make(chan uint8)
 	ioCommand := make(chan ioCommand)
 	ioIdle := make(chan bool)
+	ioError := make(chan error)
 
 	distributorChannels := distributorChannels{
 		events,
 		ioCommand,
 		ioIdle,
+		ioError,
 		ioFileName,
 		iOInput,
 		iOOutput,
 	}
-	go distributor(p, distributorChannels, keyPresses)
+	go distributor(p, distributorChannels, keyPresses, edits)
 
 	ioChannels := ioChannels{
 		command:  ioCommand,
 		idle:     ioIdle,
+		errors:   ioError,
 		filename: ioFileName,
 		output:   iOOutput,
 		input:    iOInput,
@@ -0,0 +1,130 @@
+// Package heatmap counts how often each cell is alive and how often it flips over a run, so the places where activity
+// happens can be exported as a normalised greyscale PGM or a false-colour PNG.
+package heatmap
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+const alive = 255
+
+// Counters holds, for every cell, the number of turns it was alive after and the number of turns it flipped on.
+type Counters struct {
+	Alive [][]uint32
+	Flips [][]uint32
+}
+
+// New creates counters for a board of the given size, with every count at 0.
+func New(height, width int) Counters {
+	c := Counters{Alive: make([][]uint32, height), Flips: make([][]uint32, height)}
+	for y := 0; y < height; y++ {
+		c.Alive[y] = make([]uint32, width)
+		c.Flips[y] = make([]uint32, width)
+	}
+	return c
+}
+
+// Add counts a turn, given the board before and after it.
+func (c Counters) Add(before, after [][]byte) {
+	for y := range after {
+		for x, cell := range after[y] {
+			if cell == alive {
+				c.Alive[y][x]++
+			}
+			if cell != before[y][x] {
+				c.Flips[y][x]++
+			}
+		}
+	}
+}
+
+// Remove takes a turn back out of the counts, given the board before and after it, e.g. once the run has been rewound
+// past it. Counts are never taken below 0.
+func (c Counters) Remove(before, after [][]byte) {
+	for y := range after {
+		for x, cell := range after[y] {
+			if cell == alive && c.Alive[y][x] > 0 {
+				c.Alive[y][x]--
+			}
+			if cell != before[y][x] && c.Flips[y][x] > 0 {
+				c.Flips[y][x]--
+			}
+		}
+	}
+}
+
+// Normalise scales counts into 8-bit values, so the highest count becomes 255.
+func Normalise(counts [][]uint32) [][]byte {
+	var max uint32
+	for y := range counts {
+		for _, n := range counts[y] {
+			if n > max {
+				max = n
+			}
+		}
+	}
+	grey := make([][]byte, len(counts))
+	for y := range counts {
+		grey[y] = make([]byte, len(counts[y]))
+		if max == 0 {
+			continue
+		}
+		for x, n := range counts[y] {
+			grey[y][x] = byte(uint64(n) * 255 / uint64(max))
+		}
+	}
+	return grey
+}
+
+// palette maps normalised values onto colours, from black for no activity through blue, red and yellow to white.
+var palette = []color.RGBA{
+	{0, 0, 0, 255},
+	{0, 0, 160, 255},
+	{200, 0, 120, 255},
+	{255, 60, 0, 255},
+	{255, 220, 0, 255},
+	{255, 255, 255, 255},
+}
+
+// Colour gets the false colour of a normalised value, interpolating between the colours of the palette.
+func Colour(v byte) color.RGBA {
+	steps := len(palette) - 1
+	pos := int(v) * steps
+	i, frac := pos/255, pos%255
+	if i >= steps {
+		return palette[steps]
+	}
+	from, to := palette[i], palette[i+1]
+	mix := func(a, b uint8) uint8 {
+		return uint8((int(a)*(255-frac) + int(b)*frac) / 255)
+	}
+	return color.RGBA{mix(from.R, to.R), mix(from.G, to.G), mix(from.B, to.B), 255}
+}
+
+// WritePGM writes normalised values as an 8-bit PGM image.
+func WritePGM(w io.Writer, grey [][]byte) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "P5\n%d %d\n255\n", len(grey[0]), len(grey))
+	for y := range grey {
+		if _, err := out.Write(grey[y]); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// WritePNG writes normalised values as a false-colour PNG image.
+func WritePNG(w io.Writer, grey [][]byte) error {
+	img := image.NewRGBA(image.Rect(0, 0, len(grey[0]), len(grey)))
+	for y := range grey {
+		for x, v := range grey[y] {
+			img.SetRGBA(x, y, Colour(v))
+		}
+	}
+	return png.Encode(w, img)
+}
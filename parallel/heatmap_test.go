@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/heatmap"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestHeatMap runs the 16x16 glider with heat maps and checks the exported PGMs against counters built up from the
+// CellFlipped events, as well as the colours of the PNGs.
+func TestHeatMap(t *testing.T) {
+	p := gol.Params{
+		Turns:       50,
+		Threads:     4,
+		ImageWidth:  16,
+		ImageHeight: 16,
+		HeatMap:     true,
+	}
+	events := make(chan gol.Event)
+	gol.Run(p, events, nil)
+
+	expected := heatmap.New(p.ImageHeight, p.ImageWidth)
+	board := make(map[util.Cell]bool)
+	var filenames []string
+	for event := range events {
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			board[e.Cell] = !board[e.Cell]
+			if e.CompletedTurns != 0 {
+				expected.Flips[e.Cell.Y][e.Cell.X]++
+			}
+		case gol.TurnComplete:
+			for cell, alive := range board {
+				if alive {
+					expected.Alive[cell.Y][cell.X]++
+				}
+			}
+		case gol.HeatMapOutputComplete:
+			if e.CompletedTurns != p.Turns {
+				t.Errorf("Expected heat maps of turn %v, got turn %v", p.Turns, e.CompletedTurns)
+			}
+			filenames = e.Filenames
+		}
+	}
+
+	if len(filenames) != 2 {
+		t.Fatalf("Expected alive and flips heat maps, got %v", filenames)
+	}
+	for i, counts := range [][][]uint32{expected.Alive, expected.Flips} {
+		grey := heatmap.Normalise(counts)
+		data, err := ioutil.ReadFile("out/" + filenames[i] + ".pgm")
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := fmt.Sprintf("P5\n%d %d\n255\n", p.ImageWidth, p.ImageHeight)
+		if !bytes.HasPrefix(data, []byte(header)) {
+			t.Fatalf("%v.pgm doesn't start with the header %q", filenames[i], header)
+		}
+		data = data[len(header):]
+		for y := 0; y < p.ImageHeight; y++ {
+			if !bytes.Equal(data[y*p.ImageWidth:(y+1)*p.ImageWidth], grey[y]) {
+				t.Fatalf("Row %v of %v.pgm is %v, expected %v", y, filenames[i], data[y*p.ImageWidth:(y+1)*p.ImageWidth], grey[y])
+			}
+		}
+
+		file, err := os.Open("out/" + filenames[i] + ".png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for y := 0; y < p.ImageHeight; y++ {
+			for x := 0; x < p.ImageWidth; x++ {
+				r, g, b, _ := img.At(x, y).RGBA()
+				c := heatmap.Colour(grey[y][x])
+				if uint8(r>>8) != c.R || uint8(g>>8) != c.G || uint8(b>>8) != c.B {
+					t.Fatalf("Pixel (%v, %v) of %v.png is not the colour of %v", x, y, filenames[i], grey[y][x])
+				}
+			}
+		}
+	}
+}
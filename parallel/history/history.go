@@ -0,0 +1,282 @@
+// Package history keeps a bounded record of recent generations so a run can be rewound.
+// Every keyframeInterval turns the whole board is stored, and the turns in between are stored
+// as the XOR of their board with the previous turn's board. Boards are packed into as few bits
+// per cell as the states of the rule need, e.g. 1 for Life and 2 for Wireworld.
+package history
+
+import "sort"
+
+const (
+	alive = 255
+	dead  = 0
+)
+
+// entry is one recorded turn. Keyframes hold the packed board, other entries hold the
+// positions and values of the packed bytes that differ from the previous turn.
+type entry struct {
+	turn      int
+	keyframe  []byte
+	positions []uint32
+	values    []byte
+}
+
+func (e entry) size() int {
+	return len(e.keyframe) + 4*len(e.positions) + len(e.values)
+}
+
+// History is a bounded history of boards, oldest first.
+type History struct {
+	budget           int
+	keyframeInterval int
+	width, height    int
+	entries          []entry
+	size             int
+	last             []byte
+
+	levels []byte    // grey level of each state of the rule
+	states [256]byte // state of each grey level
+	bits   int       // bits each cell is packed into
+}
+
+// New creates a history that uses at most roughly budget bytes, storing a full board every keyframeInterval turns.
+// Levels are the grey levels of the states of the rule, starting with the state empty cells are in, and are alive
+// and dead if there are fewer than two. Cells in any other level are stored as empty.
+func New(budget, keyframeInterval int, levels []byte) *History {
+	if keyframeInterval < 1 {
+		keyframeInterval = 1
+	}
+	if len(levels) < 2 {
+		levels = []byte{dead, alive}
+	}
+	h := &History{budget: budget, keyframeInterval: keyframeInterval, levels: levels, bits: 1}
+	for 1<<uint(h.bits) < len(levels) {
+		h.bits++
+	}
+	for state, level := range levels {
+		h.states[level] = byte(state)
+	}
+	return h
+}
+
+// pack packs a board into h.bits bits per cell holding the state of the cell, row by row.
+func (h *History) pack(world [][]byte) []byte {
+	height := len(world)
+	width := len(world[0])
+	packed := make([]byte, (width*height*h.bits+7)/8)
+	i := 0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			h.set(packed, i, h.states[world[y][x]])
+			i++
+		}
+	}
+	return packed
+}
+
+// unpack turns a packed board back into a world.
+func (h *History) unpack(packed []byte) [][]byte {
+	world := make([][]byte, h.height)
+	i := 0
+	for y := range world {
+		world[y] = make([]byte, h.width)
+		for x := range world[y] {
+			world[y][x] = h.levels[h.get(packed, i)]
+			i++
+		}
+	}
+	return world
+}
+
+// set stores the state of the ith cell in a packed board, most significant bit first.
+func (h *History) set(packed []byte, i int, state byte) {
+	bit := i * h.bits
+	for b := h.bits - 1; b >= 0; b-- {
+		mask := byte(0x80) >> uint(bit%8)
+		if state>>uint(b)&1 != 0 {
+			packed[bit/8] |= mask
+		} else {
+			packed[bit/8] &^= mask
+		}
+		bit++
+	}
+}
+
+// get gets the state of the ith cell in a packed board. States past the last one, which can only come from a
+// corrupted board, are read as empty.
+func (h *History) get(packed []byte, i int) byte {
+	bit := i * h.bits
+	var state byte
+	for b := 0; b < h.bits; b++ {
+		state <<= 1
+		if packed[bit/8]&(0x80>>uint(bit%8)) != 0 {
+			state |= 1
+		}
+		bit++
+	}
+	if int(state) >= len(h.levels) {
+		return 0
+	}
+	return state
+}
+
+// Cell is a cell of the board and the grey level it's in.
+type Cell struct {
+	X, Y  int
+	Level byte
+}
+
+// due reports whether the board after the given turn has to be recorded as a whole with Record, because it's
+// stored as a keyframe or doesn't follow on from the newest retained turn, rather than as the cells that changed
+// with RecordChanges.
+func (h *History) due(turn int) bool {
+	if len(h.entries) == 0 || h.last == nil || turn != h.Newest()+1 {
+		return true
+	}
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		if h.entries[i].keyframe != nil {
+			return turn-h.entries[i].turn >= h.keyframeInterval
+		}
+	}
+	return true
+}
+
+// Record adds the board after the given turn. Turns must be recorded in order; recording a turn that is
+// not newer than the newest retained turn discards everything from that turn onwards first.
+func (h *History) Record(turn int, world [][]byte) {
+	if len(world) == 0 {
+		return
+	}
+	if len(h.entries) > 0 && turn <= h.Newest() {
+		h.Truncate(turn - 1)
+	}
+	packed := h.pack(world)
+	if h.due(turn) || len(h.last) != len(packed) {
+		h.width = len(world[0])
+		h.height = len(world)
+		h.add(entry{turn: turn, keyframe: packed}, packed)
+	} else {
+		h.addDiff(turn, packed)
+	}
+}
+
+// RecordChanges adds the board after the given turn from the cells that changed since the newest retained
+// turn, so the whole board doesn't have to be collected every turn. It returns false without recording
+// anything if the board has to be recorded with Record instead, because a keyframe is due or the turn
+// doesn't follow on from the newest retained turn, or if a cell is off the board.
+func (h *History) RecordChanges(turn int, changes []Cell) bool {
+	if h.due(turn) {
+		return false
+	}
+	packed := make([]byte, len(h.last))
+	copy(packed, h.last)
+	for _, c := range changes {
+		if c.X < 0 || c.X >= h.width || c.Y < 0 || c.Y >= h.height {
+			return false
+		}
+		h.set(packed, c.Y*h.width+c.X, h.states[c.Level])
+	}
+	h.addDiff(turn, packed)
+	return true
+}
+
+// addDiff adds the packed board after the given turn as the bytes that differ from the newest retained turn.
+func (h *History) addDiff(turn int, packed []byte) {
+	e := entry{turn: turn}
+	for i := range packed {
+		if diff := packed[i] ^ h.last[i]; diff != 0 {
+			e.positions = append(e.positions, uint32(i))
+			e.values = append(e.values, diff)
+		}
+	}
+	h.add(e, packed)
+}
+
+// add appends an entry, whose packed board is packed, and evicts old turns if the history has outgrown its budget.
+func (h *History) add(e entry, packed []byte) {
+	h.entries = append(h.entries, e)
+	h.size += e.size()
+	h.last = packed
+	h.evict()
+}
+
+// evict drops the oldest keyframe and the diffs depending on it until the history fits in its budget.
+// The newest keyframe and its diffs are always kept.
+func (h *History) evict() {
+	for h.size > h.budget {
+		next := -1
+		for i := 1; i < len(h.entries); i++ {
+			if h.entries[i].keyframe != nil {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			return
+		}
+		for _, e := range h.entries[:next] {
+			h.size -= e.size()
+		}
+		h.entries = h.entries[next:]
+	}
+}
+
+// Truncate discards every turn after the given turn, e.g. after rewinding so the run can be recomputed from there.
+func (h *History) Truncate(turn int) {
+	for len(h.entries) > 0 && h.entries[len(h.entries)-1].turn > turn {
+		h.size -= h.entries[len(h.entries)-1].size()
+		h.entries = h.entries[:len(h.entries)-1]
+	}
+	h.last = nil
+	if len(h.entries) > 0 {
+		h.last = h.packed(len(h.entries) - 1)
+	}
+}
+
+// packed rebuilds the packed board of the entry at index i from the keyframe before it.
+func (h *History) packed(i int) []byte {
+	start := i
+	for h.entries[start].keyframe == nil {
+		start--
+	}
+	packed := make([]byte, len(h.entries[start].keyframe))
+	copy(packed, h.entries[start].keyframe)
+	for _, e := range h.entries[start+1 : i+1] {
+		for j, position := range e.positions {
+			packed[position] ^= e.values[j]
+		}
+	}
+	return packed
+}
+
+// Get returns the board after the given turn, if it is still retained.
+func (h *History) Get(turn int) ([][]byte, bool) {
+	if len(h.entries) == 0 || turn < h.Oldest() || turn > h.Newest() {
+		return nil, false
+	}
+	i := sort.Search(len(h.entries), func(i int) bool { return h.entries[i].turn >= turn })
+	if h.entries[i].turn != turn {
+		return nil, false
+	}
+	return h.unpack(h.packed(i)), true
+}
+
+// Oldest returns the oldest retained turn, or -1 if nothing has been recorded.
+func (h *History) Oldest() int {
+	if len(h.entries) == 0 {
+		return -1
+	}
+	return h.entries[0].turn
+}
+
+// Newest returns the newest retained turn, or -1 if nothing has been recorded.
+func (h *History) Newest() int {
+	if len(h.entries) == 0 {
+		return -1
+	}
+	return h.entries[len(h.entries)-1].turn
+}
+
+// Size returns the number of bytes used by the retained turns.
+func (h *History) Size() int {
+	return h.size
+}
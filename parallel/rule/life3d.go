@@ -0,0 +1,146 @@
+package rule
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Life3D is a life-like rule on a volume of cubic cells, which have the 26 cells round them as neighbours. It's
+// written in Bays' notation, e.g. 4555 for cells surviving with 4 to 5 alive neighbours and being born with 5 to 5,
+// or with commas between the numbers, e.g. 5,7,6,6, for numbers above 9.
+//
+// The volume is stored as its slices one after the other, Height rows each, so it runs on strips of whole slices
+// with one slice above and below them as halos, and wraps round along every axis.
+type Life3D struct {
+	SurviveMin, SurviveMax int
+	BirthMin, BirthMax     int
+
+	// Height is the number of rows in each slice, which InVolume sets.
+	Height int
+}
+
+// ParseLife3D parses a 3D rule in Bays' notation.
+func ParseLife3D(notation string) (Life3D, error) {
+	parts := strings.Split(notation, ",")
+	if len(parts) == 1 && len(notation) == 4 {
+		parts = strings.Split(notation, "")
+	}
+	if len(parts) != 4 {
+		return Life3D{}, fmt.Errorf("%q is not a 3D rule, e.g. 4555 or 5,7,6,6", notation)
+	}
+	var n [4]int
+	for i, part := range parts {
+		var err error
+		if n[i], err = strconv.Atoi(strings.TrimSpace(part)); err != nil || n[i] < 0 || n[i] > 26 {
+			return Life3D{}, fmt.Errorf("%q is not a number of neighbours from 0 to 26", part)
+		}
+	}
+	if n[0] > n[1] || n[2] > n[3] {
+		return Life3D{}, fmt.Errorf("%q has a range of neighbours the wrong way round", notation)
+	}
+	return Life3D{SurviveMin: n[0], SurviveMax: n[1], BirthMin: n[2], BirthMax: n[3]}, nil
+}
+
+// InVolume gets a rule that runs on a volume of depth slices with height rows each, or on flat worlds if the depth
+// is 0 or 1. Only 3D rules run on volumes, and they only run on volumes.
+func InVolume(r Rule, height, depth int) (Rule, error) {
+	l, ok := r.(Life3D)
+	switch {
+	case depth <= 1 && !ok:
+		return r, nil
+	case depth <= 1:
+		return nil, fmt.Errorf("%v only runs on a volume, which needs a depth of 2 or more", r)
+	case !ok:
+		return nil, fmt.Errorf("%v can't run on a volume, only 3D rules can", r)
+	}
+	l.Height = height
+	return l, nil
+}
+
+// Slice gets the number of rows in each slice of the world, which strips of it have to be made of whole ones of.
+// It's a single row unless the rule runs on a volume.
+func Slice(r Rule) int {
+	if l, ok := r.(Life3D); ok && l.Height > 0 {
+		return l.Height
+	}
+	return 1
+}
+
+// Spec gets the name of the rule and its numbers.
+func (l Life3D) Spec() Spec {
+	return Spec{Name: "3d", Params: l.numbers()}
+}
+
+// Levels gets the grey levels of dead and alive cells.
+func (Life3D) Levels() []byte {
+	return []byte{dead, alive}
+}
+
+// Quantise snaps a grey level onto dead or alive, whichever is nearer.
+func (Life3D) Quantise(level byte) byte {
+	if level >= alive/2+1 {
+		return alive
+	}
+	return dead
+}
+
+// Halo is a whole slice, as cells look at the slices above and below them.
+func (l Life3D) Halo() int {
+	return l.Height
+}
+
+// Step computes a turn of the slices of a strip between its halo slices.
+func (l Life3D) Step(strip [][]byte, top, turn int) [][]byte {
+	height, width := l.Height, len(strip[0])
+	next := make([][]byte, len(strip)-2*height)
+	for y := range next {
+		next[y] = make([]byte, width)
+		z, row := y/height+1, y%height // the slice of the strip the cell is on, after the halo slice
+		for x := range next[y] {
+			n := 0
+			for dz := -1; dz <= 1; dz++ {
+				for dy := -1; dy <= 1; dy++ {
+					cells := strip[(z+dz)*height+((row+dy)%height+height)%height]
+					for dx := -1; dx <= 1; dx++ {
+						if cells[((x+dx)%width+width)%width] == alive {
+							n++
+						}
+					}
+				}
+			}
+			cell := strip[z*height+row][x]
+			if cell == alive {
+				n--
+			}
+			if cell == alive && n >= l.SurviveMin && n <= l.SurviveMax || cell != alive && n >= l.BirthMin && n <= l.BirthMax {
+				next[y][x] = alive
+			}
+		}
+	}
+	return next
+}
+
+// Colour shows alive cells in white on black.
+func (Life3D) Colour(level byte) color.RGBA {
+	return color.RGBA{level, level, level, 255}
+}
+
+func (l Life3D) String() string {
+	return "3d:" + l.numbers()
+}
+
+// numbers writes the rule in Bays' notation, with commas if any of the numbers is above 9.
+func (l Life3D) numbers() string {
+	n := []int{l.SurviveMin, l.SurviveMax, l.BirthMin, l.BirthMax}
+	digits := make([]string, len(n))
+	separator := ""
+	for i := range n {
+		digits[i] = strconv.Itoa(n[i])
+		if n[i] > 9 {
+			separator = ","
+		}
+	}
+	return strings.Join(digits, separator)
+}
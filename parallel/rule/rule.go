@@ -0,0 +1,105 @@
+// Package rule holds the cellular automata the game can run, from life-like rules in B/S/C notation and Larger than
+// Life rules in LtL notation to Wireworld, 3D and stochastic rules, and applies them to cells stored as PGM grey
+// levels, one level for each state. For life-like rules cells are dead (0), alive (255) or, for rules with more than
+// two states, in one of the refractory states in between, which they decay through one turn at a time without
+// counting as neighbours.
+package rule
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strings"
+)
+
+const (
+	dead  = 0
+	alive = 255
+)
+
+// Rule is a cellular automaton: the states its cells can be in, how far their neighbourhood reaches and how they
+// change from one turn to the next.
+type Rule interface {
+	// Spec gets the name and parameters the rule can be built again from with New, e.g. on the other end of an RPC call.
+	Spec() Spec
+
+	// Levels gets the grey level of each state, starting with the state empty cells are in.
+	Levels() []byte
+
+	// Quantise snaps a grey level onto the level of the nearest state, so images from elsewhere can be read in.
+	Quantise(level byte) byte
+
+	// Halo gets the number of rows a strip of the world needs above and below it to compute a turn.
+	Halo() int
+
+	// Step computes a turn of the rows of a strip between its halo rows, and returns the new rows. The strip wraps
+	// round at its left and right edges, top is the row of the world the first row after the halo rows is, and turn is
+	// the number of turns computed before this one, which stochastic rules draw their random numbers for.
+	Step(strip [][]byte, top, turn int) [][]byte
+
+	// Colour gets the colour cells with the grey level are shown in.
+	Colour(level byte) color.RGBA
+
+	// String writes the rule in the notation Parse reads.
+	String() string
+}
+
+// Spec names a rule and gives its parameters, so it can be sent over RPC. The zero Spec is Life.
+type Spec struct {
+	Name   string
+	Params string
+}
+
+// builders builds each rule from its parameters, by name.
+var builders = map[string]func(params string) (Rule, error){
+	"generations": func(params string) (Rule, error) { return ParseGenerations(params) },
+	"3d":          func(params string) (Rule, error) { return ParseLife3D(params) },
+	"stochastic":  func(params string) (Rule, error) { return ParseStochastic(params) },
+	"wireworld": func(params string) (Rule, error) {
+		if params != "" {
+			return nil, fmt.Errorf("wireworld takes no parameters")
+		}
+		return Wireworld{}, nil
+	},
+}
+
+// Register adds a rule New can build by name, so other cellular automata can be plugged in. It has to be registered
+// in every process that runs the rule, i.e. in the workers as well as the engine in the distributed version.
+func Register(name string, build func(params string) (Rule, error)) {
+	builders[strings.ToLower(name)] = build
+}
+
+// Names gets the names of the rules New can build.
+func Names() []string {
+	var names []string
+	for name := range builders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New builds a rule from its name and parameters.
+func New(spec Spec) (Rule, error) {
+	if spec.Name == "" {
+		return Life, nil
+	}
+	build, ok := builders[strings.ToLower(spec.Name)]
+	if !ok {
+		return nil, fmt.Errorf("there's no rule called %q, the rules are %v", spec.Name, Names())
+	}
+	return build(spec.Params)
+}
+
+// Parse parses a rule from the name of a rule, e.g. Wireworld, a name followed by a colon and its parameters, e.g.
+// 3d:4555 for a 3D rule or stochastic:B3/S23,birth=0.9 for a stochastic one, or otherwise a life-like or Larger than
+// Life rule as ParseGenerations reads them. An empty rule is Life.
+func Parse(notation string) (Rule, error) {
+	if name := strings.SplitN(notation, ":", 2); len(name) == 2 {
+		return New(Spec{Name: name[0], Params: name[1]})
+	}
+	if _, ok := builders[strings.ToLower(notation)]; ok {
+		return New(Spec{Name: notation})
+	}
+	return ParseGenerations(notation)
+}
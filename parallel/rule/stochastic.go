@@ -0,0 +1,121 @@
+package rule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Stochastic is a life-like rule where births and survivals only happen with a chance, and where cells only update with
+// a chance each turn, keeping their state otherwise. It's written as the rule followed by the chances that aren't 1,
+// e.g. B3/S23,birth=0.9,survive=0.95,update=0.5.
+//
+// The random numbers are drawn from the seed, the turn and the position of the cell in the world, so each cell has its
+// own stream of them and a run with the same seed gives the same results however the world is split up.
+type Stochastic struct {
+	Generations
+	BirthChance, SurviveChance, UpdateChance float64
+
+	// Seed is what the random numbers are drawn from, which Seeded sets.
+	Seed int64
+}
+
+// ParseStochastic parses a stochastic rule, as a rule ParseGenerations reads followed by the chances of births,
+// survivals and updates, which are 1 if they're left out.
+func ParseStochastic(notation string) (Stochastic, error) {
+	s := Stochastic{BirthChance: 1, SurviveChance: 1, UpdateChance: 1}
+	parts := strings.Split(notation, ",")
+	end := len(parts)
+	for end > 0 && strings.Contains(parts[end-1], "=") {
+		end--
+	}
+	for _, part := range parts[end:] {
+		option := strings.SplitN(part, "=", 2)
+		chance, err := strconv.ParseFloat(option[1], 64)
+		if err != nil || chance < 0 || chance > 1 {
+			return s, fmt.Errorf("%q is not a chance from 0 to 1", option[1])
+		}
+		switch strings.ToLower(option[0]) {
+		case "birth":
+			s.BirthChance = chance
+		case "survive":
+			s.SurviveChance = chance
+		case "update":
+			s.UpdateChance = chance
+		default:
+			return s, fmt.Errorf("%q is not a chance, they're birth, survive and update", option[0])
+		}
+	}
+	var err error
+	s.Generations, err = ParseGenerations(strings.Join(parts[:end], ","))
+	return s, err
+}
+
+// Seeded gets a rule that draws its random numbers from the seed, if it draws any.
+func Seeded(r Rule, seed int64) Rule {
+	if s, ok := r.(Stochastic); ok {
+		s.Seed = seed
+		return s
+	}
+	return r
+}
+
+// Spec gets the name of the rule and the rule and chances it's made of, but not the seed.
+func (s Stochastic) Spec() Spec {
+	return Spec{Name: "stochastic", Params: s.params()}
+}
+
+// Step computes a turn of the rule the chances are applied to, then keeps cells as they were where they don't update
+// or where a birth doesn't happen, and kills alive cells where a survival doesn't happen.
+func (s Stochastic) Step(strip [][]byte, top, turn int) [][]byte {
+	halo := s.Halo()
+	next := s.Generations.Step(strip, top, turn)
+	for y := range next {
+		for x := range next[y] {
+			level := strip[y+halo][x]
+			switch {
+			case s.UpdateChance < 1 && s.chance(turn, x, top+y, 0) >= s.UpdateChance:
+				next[y][x] = level
+			case level != alive && next[y][x] == alive && s.chance(turn, x, top+y, 1) >= s.BirthChance:
+				next[y][x] = level
+			case level == alive && next[y][x] == alive && s.chance(turn, x, top+y, 1) >= s.SurviveChance:
+				next[y][x] = s.next(alive, false)
+			}
+		}
+	}
+	return next
+}
+
+// chance gets a random number from 0 up to 1 for the cell at (x, y) of the world on a turn, where draw picks one of
+// the numbers the cell draws that turn.
+func (s Stochastic) chance(turn, x, y, draw int) float64 {
+	h := mix(mix(mix(mix(uint64(s.Seed))^uint64(turn))^uint64(x))^uint64(y)) ^ uint64(draw)
+	return float64(mix(h)>>11) / (1 << 53)
+}
+
+// mix scrambles the bits of a number, as the finaliser of SplitMix64 does.
+func mix(z uint64) uint64 {
+	z += 0x9e3779b97f4a7c15
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return z ^ z>>31
+}
+
+func (s Stochastic) String() string {
+	return "stochastic:" + s.params()
+}
+
+// params writes the rule followed by the chances that aren't 1.
+func (s Stochastic) params() string {
+	var b strings.Builder
+	b.WriteString(s.Generations.String())
+	for _, option := range []struct {
+		name   string
+		chance float64
+	}{{"birth", s.BirthChance}, {"survive", s.SurviveChance}, {"update", s.UpdateChance}} {
+		if option.chance != 1 {
+			fmt.Fprintf(&b, ",%v=%v", option.name, strconv.FormatFloat(option.chance, 'g', -1, 64))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,87 @@
+package rule
+
+import (
+	"image/color"
+	"math/bits"
+)
+
+// Grey levels of the states of Wireworld. Electron heads are alive as far as counting cells goes.
+const (
+	empty     = 0
+	head      = alive
+	tail      = 170
+	conductor = 85
+)
+
+// Wireworld simulates electronic circuits: electron heads become tails, tails become conductors again, and conductors
+// become heads if one or two of the 8 cells round them are heads. Empty cells stay empty.
+type Wireworld struct{}
+
+// Spec gets the name of the rule, which has no parameters.
+func (Wireworld) Spec() Spec {
+	return Spec{Name: "wireworld"}
+}
+
+// Levels gets the grey levels of empty cells, heads, tails and conductors.
+func (Wireworld) Levels() []byte {
+	return []byte{empty, head, tail, conductor}
+}
+
+// Quantise snaps a grey level onto the level of the nearest state.
+func (w Wireworld) Quantise(level byte) byte {
+	nearest := byte(empty)
+	for _, l := range w.Levels() {
+		if distance(level, l) < distance(level, nearest) {
+			nearest = l
+		}
+	}
+	return nearest
+}
+
+func distance(a, b byte) int {
+	return abs(int(a) - int(b))
+}
+
+// Halo is 1, as cells only look at the 8 cells round them.
+func (Wireworld) Halo() int {
+	return 1
+}
+
+// Step computes a turn of the rows of a strip between its halo rows.
+func (Wireworld) Step(strip [][]byte, top, turn int) [][]byte {
+	next := make([][]byte, len(strip)-2)
+	for y := range next {
+		next[y] = make([]byte, len(strip[0]))
+		for x := range next[y] {
+			switch strip[y+1][x] {
+			case head:
+				next[y][x] = tail
+			case tail:
+				next[y][x] = conductor
+			case conductor:
+				next[y][x] = conductor
+				if heads := bits.OnesCount(uint(Neighbourhood(strip, x, y+1))); heads == 1 || heads == 2 {
+					next[y][x] = head
+				}
+			}
+		}
+	}
+	return next
+}
+
+// Colour shows heads in blue, tails in red and conductors in yellow, on black.
+func (Wireworld) Colour(level byte) color.RGBA {
+	switch level {
+	case head:
+		return color.RGBA{40, 120, 255, 255}
+	case tail:
+		return color.RGBA{255, 60, 0, 255}
+	case conductor:
+		return color.RGBA{255, 200, 0, 255}
+	}
+	return color.RGBA{0, 0, 0, 255}
+}
+
+func (Wireworld) String() string {
+	return "Wireworld"
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestGenerations runs a Brian's Brain spaceship on the 8x8 image, which moves one cell to the right every turn,
+// and checks the output images after a number of turns against the starting image shifted along. The CellFlipped
+// events are followed as well, to check they carry the state each cell changes to.
+func TestGenerations(t *testing.T) {
+	initial := readPgm(t, "images/8x8.pgm")
+	for _, turns := range []int{0, 1, 3, 8, 13} {
+		for _, threads := range []int{1, 2, 3} {
+			p := gol.Params{
+				Turns:       turns,
+				Threads:     threads,
+				ImageWidth:  8,
+				ImageHeight: 8,
+				Rule:        "B2/S/C3",
+			}
+			t.Run(fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads), func(t *testing.T) {
+				events := make(chan gol.Event)
+				gol.Run(p, events, nil)
+				board := make([][]byte, p.ImageHeight)
+				for y := range board {
+					board[y] = make([]byte, p.ImageWidth)
+				}
+				for event := range events {
+					if e, ok := event.(gol.CellFlipped); ok {
+						board[e.Cell.Y][e.Cell.X] = e.State
+					}
+				}
+
+				output := readPgm(t, fmt.Sprintf("out/%dx%dx%d.pgm", p.ImageWidth, p.ImageHeight, p.Turns))
+				for y := range initial {
+					expected := make([]byte, p.ImageWidth)
+					for x := range expected {
+						expected[(x+turns)%p.ImageWidth] = initial[y][x]
+					}
+					if !bytes.Equal(output[y], expected) {
+						t.Fatalf("Row %v after %v turns is %v, expected %v", y, turns, output[y], expected)
+					}
+					if !bytes.Equal(board[y], expected) {
+						t.Fatalf("Row %v after %v turns is %v going by the CellFlipped events, expected %v", y, turns, board[y], expected)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestLargerThanLife checks Life written in LtL notation against the check images, then runs Larger than Life rules
+// with Moore and von Neumann neighbourhoods of range 5 and 3 and checks the output images against counting every
+// neighbour one by one. The strips need as many halo rows as the range of the rule, so it runs with strips thinner than the range as well.
+func TestLargerThanLife(t *testing.T) {
+	tests := []struct {
+		rule  string
+		turns int
+		ltl   ltl
+	}{
+		{"R1,C0,M0,S2..3,B3,NM", 100, ltl{}},
+		{"R5,C0,M1,S34..58,B34..45,NM", 10, ltl{r: 5, middle: true, sMin: 34, sMax: 58, bMin: 34, bMax: 45}},
+		{"r3,c2,m0,s3..8,b4..6,nn", 10, ltl{r: 3, vonNeumann: true, sMin: 3, sMax: 8, bMin: 4, bMax: 6}},
+	}
+	for _, test := range tests {
+		for _, threads := range []int{1, 3, 8, 16} {
+			p := gol.Params{
+				Turns:       test.turns,
+				Threads:     threads,
+				ImageWidth:  64,
+				ImageHeight: 64,
+				Rule:        test.rule,
+			}
+			t.Run(fmt.Sprintf("%v-%v_threads", test.rule, threads), func(t *testing.T) {
+				events := make(chan gol.Event)
+				gol.Run(p, events, nil)
+				for range events {
+				}
+
+				var expected [][]byte
+				if test.ltl.r == 0 {
+					expected = readPgm(t, fmt.Sprintf("check/images/%dx%dx%d.pgm", p.ImageWidth, p.ImageHeight, p.Turns))
+				} else {
+					expected = readPgm(t, fmt.Sprintf("images/%dx%d.pgm", p.ImageWidth, p.ImageHeight))
+					for turn := 0; turn < p.Turns; turn++ {
+						expected = test.ltl.next(expected)
+					}
+				}
+				output := readPgm(t, fmt.Sprintf("out/%dx%dx%d.pgm", p.ImageWidth, p.ImageHeight, p.Turns))
+				for y := range expected {
+					if !bytes.Equal(output[y], expected[y]) {
+						t.Fatalf("Row %v after %v turns is %v, expected %v", y, p.Turns, output[y], expected[y])
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestHensel checks Life with every letter of Hensel's notation written out against the check images, then runs
+// well-known isotropic non-totalistic rules and checks the output images against a rule worked out from pictures of
+// the neighbourhoods each letter stands for.
+func TestHensel(t *testing.T) {
+	tests := []struct {
+		rule           string
+		turns          int
+		birth, survive []string
+	}{
+		{"B3aceijknqry/S2aceikn3aceijknqry", 100, nil, nil},
+		{"B2n3/S23-q", 30, []string{"2n", "3"}, []string{"2", "3c", "3e", "3a", "3i", "3k", "3n", "3j", "3r", "3y"}},
+		{"B3/S2-i34q", 30, []string{"3"}, []string{"2c", "2e", "2a", "2k", "2n", "3", "4q"}},
+		{"B2-a/S12", 30, []string{"2c", "2e", "2i", "2k", "2n"}, []string{"1", "2"}},
+	}
+	for _, test := range tests {
+		for _, threads := range []int{1, 3, 16} {
+			p := gol.Params{
+				Turns:       test.turns,
+				Threads:     threads,
+				ImageWidth:  64,
+				ImageHeight: 64,
+				Rule:        test.rule,
+			}
+			t.Run(fmt.Sprintf("%v-%v_threads", test.rule, threads), func(t *testing.T) {
+				events := make(chan gol.Event)
+				gol.Run(p, events, nil)
+				for range events {
+				}
+
+				var expected [][]byte
+				if test.birth == nil {
+					expected = readPgm(t, fmt.Sprintf("check/images/%dx%dx%d.pgm", p.ImageWidth, p.ImageHeight, p.Turns))
+				} else {
+					expected = readPgm(t, fmt.Sprintf("images/%dx%d.pgm", p.ImageWidth, p.ImageHeight))
+					for turn := 0; turn < p.Turns; turn++ {
+						expected = henselNext(expected, test.birth, test.survive)
+					}
+				}
+				output := readPgm(t, fmt.Sprintf("out/%dx%dx%d.pgm", p.ImageWidth, p.ImageHeight, p.Turns))
+				for y := range expected {
+					if !bytes.Equal(output[y], expected[y]) {
+						t.Fatalf("Row %v after %v turns is %v, expected %v", y, p.Turns, output[y], expected[y])
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestWireworld runs a Wireworld circuit on the 32x32 image, with an electron going round a wire across the board, a
+// clock loop sending electrons down a wire and a wire splitting in two, and checks the output images against
+// stepping through the rules of Wireworld cell by cell.
+func TestWireworld(t *testing.T) {
+	for _, turns := range []int{0, 1, 7, 30} {
+		for _, threads := range []int{1, 2, 3, 16} {
+			p := gol.Params{
+				Turns:       turns,
+				Threads:     threads,
+				ImageWidth:  32,
+				ImageHeight: 32,
+				Rule:        "Wireworld",
+			}
+			t.Run(fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads), func(t *testing.T) {
+				events := make(chan gol.Event)
+				gol.Run(p, events, nil)
+				for range events {
+				}
+
+				expected := readPgm(t, fmt.Sprintf("images/%dx%d.pgm", p.ImageWidth, p.ImageHeight))
+				for turn := 0; turn < p.Turns; turn++ {
+					expected = wireworldNext(expected)
+				}
+				output := readPgm(t, fmt.Sprintf("out/%dx%dx%d.pgm", p.ImageWidth, p.ImageHeight, p.Turns))
+				for y := range expected {
+					if !bytes.Equal(output[y], expected[y]) {
+						t.Fatalf("Row %v after %v turns is %v, expected %v", y, p.Turns, output[y], expected[y])
+					}
+				}
+			})
+		}
+	}
+}
+
+// wireworldNext computes a turn of Wireworld, with electron heads at 255, tails at 170 and conductors at 85, on a board
+// that wraps round at the edges.
+func wireworldNext(world [][]byte) [][]byte {
+	height, width := len(world), len(world[0])
+	next := make([][]byte, height)
+	for y := range world {
+		next[y] = make([]byte, width)
+		for x := range world[y] {
+			switch world[y][x] {
+			case 255:
+				next[y][x] = 170
+			case 170:
+				next[y][x] = 85
+			case 85:
+				heads := 0
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						if world[(y+dy+height)%height][(x+dx+width)%width] == 255 {
+							heads++
+						}
+					}
+				}
+				next[y][x] = 85
+				if heads == 1 || heads == 2 {
+					next[y][x] = 255
+				}
+			}
+		}
+	}
+	return next
+}
+
+// TestLife3D runs the 3D rules 4555 and 5766 on the 16x16x16 volume, stored as its 16 slices one after the other, and
+// checks the output images against counting the 26 neighbours of each cell one by one. Threads work on whole slices, so
+// it runs with uneven splits of the slices and with a thread for each slice as well as a single thread.
+func TestLife3D(t *testing.T) {
+	tests := []struct {
+		rule  string
+		turns []int
+		l     life3d
+	}{
+		{"3d:4555", []int{0, 1, 10, 30}, life3d{4, 5, 5, 5}},
+		{"3d:5,7,6,6", []int{1, 10}, life3d{5, 7, 6, 6}},
+	}
+	for _, test := range tests {
+		for _, turns := range test.turns {
+			for _, threads := range []int{1, 3, 16} {
+				p := gol.Params{
+					Turns:       turns,
+					Threads:     threads,
+					ImageWidth:  16,
+					ImageHeight: 16,
+					ImageDepth:  16,
+					Rule:        test.rule,
+				}
+				t.Run(fmt.Sprintf("%v-%dx%dx%dx%d-%d", p.Rule, p.ImageWidth, p.ImageHeight, p.ImageDepth, p.Turns, p.Threads), func(t *testing.T) {
+					events := make(chan gol.Event)
+					gol.Run(p, events, nil)
+					for range events {
+					}
+
+					expected := readPgm(t, fmt.Sprintf("images/%dx%dx%d.pgm", p.ImageWidth, p.ImageHeight, p.ImageDepth))
+					for turn := 0; turn < p.Turns; turn++ {
+						expected = test.l.next(expected, p.ImageHeight)
+					}
+					output := readPgm(t, fmt.Sprintf("out/%dx%dx%dx%d.pgm", p.ImageWidth, p.ImageHeight, p.ImageDepth, p.Turns))
+					for y := range expected {
+						if !bytes.Equal(output[y], expected[y]) {
+							t.Fatalf("Row %v of slice %v after %v turns is %v, expected %v", y%p.ImageHeight, y/p.ImageHeight, p.Turns, output[y], expected[y])
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+// life3d is a 3D rule in Bays' notation, to work out the expected volumes the slow way.
+type life3d struct {
+	sMin, sMax, bMin, bMax int
+}
+
+// next computes a turn of the rule on a volume stored as slices of height rows one after the other, which wraps round
+// along every axis.
+func (l life3d) next(volume [][]byte, height int) [][]byte {
+	depth, width := len(volume)/height, len(volume[0])
+	at := func(x, y, z int) byte {
+		return volume[((z+depth)%depth)*height+(y+height)%height][(x+width)%width]
+	}
+	next := make([][]byte, len(volume))
+	for row := range volume {
+		next[row] = make([]byte, width)
+		z, y := row/height, row%height
+		for x := range volume[row] {
+			n := 0
+			for dz := -1; dz <= 1; dz++ {
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						if (dx != 0 || dy != 0 || dz != 0) && at(x+dx, y+dy, z+dz) == 255 {
+							n++
+						}
+					}
+				}
+			}
+			if at(x, y, z) == 255 && n >= l.sMin && n <= l.sMax || at(x, y, z) != 255 && n >= l.bMin && n <= l.bMax {
+				next[row][x] = 255
+			}
+		}
+	}
+	return next
+}
+
+// henselPictures draw a neighbourhood for each letter of up to 4 neighbours, as the rows of the 3x3 block round the
+// cell. Each stands for the neighbourhoods it can be rotated or reflected into, and the neighbourhoods of 5 to 8
+// neighbours take the letter of the cells that are left dead.
+var henselPictures = map[string]string{
+	"1c": "O../.../...", "1e": ".O./.../...",
+	"2c": "O.O/.../...", "2e": ".O./O../...", "2a": "OO./.../...", "2i": ".../O.O/...", "2k": "O../..O/...", "2n": "..O/.../O..",
+	"3c": "O.O/.../O..", "3e": ".O./O.O/...", "3a": "OO./O../...", "3i": "OOO/.../...", "3k": ".O./..O/O..",
+	"3n": "O.O/O../...", "3j": ".OO/O../...", "3q": ".OO/.../O..", "3r": "O../O.O/...", "3y": "O../..O/O..",
+	"4c": "O.O/.../O.O", "4e": ".O./O.O/.O.", "4a": "OOO/O../...", "4i": "O.O/O.O/...", "4k": "OO./..O/O..",
+	"4n": "OOO/.../O..", "4j": ".O./O.O/O..", "4q": ".OO/..O/O..", "4r": "OO./O.O/...", "4y": "O.O/..O/O..",
+	"4t": "O../O.O/O..", "4w": ".OO/O../O..", "4z": "..O/O.O/O..",
+}
+
+// henselLetter gets the number of alive neighbours in a 3x3 block and the letter of their arrangement, by turning
+// and flipping the block until it matches one of the pictures.
+func henselLetter(block [3][3]bool) (int, string) {
+	n := 0
+	for y := range block {
+		for x := range block[y] {
+			if block[y][x] && (x != 1 || y != 1) {
+				n++
+			}
+		}
+	}
+	if n > 4 {
+		for y := range block {
+			for x := range block[y] {
+				block[y][x] = !block[y][x]
+			}
+		}
+		_, letter := henselLetter(block)
+		return n, letter
+	}
+	for name, picture := range henselPictures {
+		if int(name[0]-'0') != n {
+			continue
+		}
+		for turn := 0; turn < 8; turn++ {
+			match := true
+			for y := 0; y < 3 && match; y++ {
+				for x := 0; x < 3; x++ {
+					tx, ty := x-1, y-1
+					for i := 0; i < turn%4; i++ {
+						tx, ty = -ty, tx
+					}
+					if turn >= 4 {
+						tx = -tx
+					}
+					if x == 1 && y == 1 {
+						continue
+					}
+					if (picture[(ty+1)*4+tx+1] == 'O') != block[y][x] {
+						match = false
+						break
+					}
+				}
+			}
+			if match {
+				return n, name[1:]
+			}
+		}
+	}
+	return n, ""
+}
+
+// henselNext computes a turn of a rule given as the numbers of neighbours, with or without a letter, that cells are
+// born and survive with, on a board that wraps round at the edges.
+func henselNext(world [][]byte, birth, survive []string) [][]byte {
+	height, width := len(world), len(world[0])
+	next := make([][]byte, height)
+	for y := range world {
+		next[y] = make([]byte, width)
+		for x := range world[y] {
+			var block [3][3]bool
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					block[dy+1][dx+1] = world[(y+dy+height)%height][(x+dx+width)%width] == 255
+				}
+			}
+			n, letter := henselLetter(block)
+			conditions := birth
+			if world[y][x] == 255 {
+				conditions = survive
+			}
+			for _, c := range conditions {
+				if c == fmt.Sprint(n) || c == fmt.Sprint(n)+letter {
+					next[y][x] = 255
+				}
+			}
+		}
+	}
+	return next
+}
+
+// ltl is a two state Larger than Life rule, to work out the expected boards the slow way.
+type ltl struct {
+	r                      int
+	vonNeumann, middle     bool
+	sMin, sMax, bMin, bMax int
+}
+
+// next computes a turn of the rule on a board that wraps round at the edges, counting each neighbour in turn.
+func (l ltl) next(world [][]byte) [][]byte {
+	height, width := len(world), len(world[0])
+	next := make([][]byte, height)
+	for y := range world {
+		next[y] = make([]byte, width)
+		for x := range world[y] {
+			n := 0
+			for dy := -l.r; dy <= l.r; dy++ {
+				for dx := -l.r; dx <= l.r; dx++ {
+					if l.vonNeumann && abs(dx)+abs(dy) > l.r || !l.middle && dx == 0 && dy == 0 {
+						continue
+					}
+					if world[((y+dy)%height+height)%height][((x+dx)%width+width)%width] == 255 {
+						n++
+					}
+				}
+			}
+			if world[y][x] == 255 && n >= l.sMin && n <= l.sMax || world[y][x] != 255 && n >= l.bMin && n <= l.bMax {
+				next[y][x] = 255
+			}
+		}
+	}
+	return next
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// readPgm reads the grey levels of every cell of a PGM image.
+func readPgm(t *testing.T, path string) [][]byte {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var width, height, maxval int
+	var header string
+	if _, err := fmt.Sscanf(string(data), "P5\n%d %d\n%d\n", &width, &height, &maxval); err != nil {
+		t.Fatal(err)
+	}
+	header = fmt.Sprintf("P5\n%d %d\n%d\n", width, height, maxval)
+	pixels := data[len(header):]
+	world := make([][]byte, height)
+	for y := range world {
+		world[y] = pixels[y*width : (y+1)*width]
+	}
+	return world
+}
@@ -0,0 +1,330 @@
+package sdl
+
+import (
+	"image/color"
+)
+
+const (
+	// maxWindowSide is the most pixels along each side of the window, so boards larger than the screen are shown
+	// zoomed out or in part
+	maxWindowSide = 1024
+
+	// minimapSide is the most pixels along each side of the minimap
+	minimapSide = 160
+
+	// maxZoom is the zoom with the most pixels along each side of a cell, 2^maxZoom
+	maxZoom = 5
+)
+
+// Viewport maps the cells of the board onto the pixels of the window. Zoomed in, each cell is drawn as a square of
+// pixels, with the odd rows of a hex board shifted half a cell to the right. Zoomed out, each pixel shows the average
+// colour of a square of cells, which is kept up to date as cells change so only the pixels of the cells that change
+// are worked out again. A minimap of the whole board is kept the same way, for when only part of it is shown.
+type Viewport struct {
+	Width, Height int // size of the window in pixels
+
+	boardWidth, boardHeight int
+	hex                     bool
+	colour                  func(x, y int) color.RGBA // colour of a cell of the board
+
+	zoom      int // pixels along each side of a cell are 2^zoom, or cells along each side of a pixel 2^-zoom if negative
+	minZoom   int // zoom the whole board fits in the window at
+	left, top int // cell at the top left corner of the window
+
+	view    shrunk // average colours of the pixels of the window while zoomed out
+	minimap shrunk // average colours of the pixels of the minimap
+}
+
+// NewViewport creates a viewport onto a board of the given size, with a window as large as the board up to
+// maxWindowSide pixels along each side. The board is zoomed out until the whole of it fits, and hex boards are zoomed
+// in so each cell is 2 pixels wide and can be shifted half a cell.
+func NewViewport(boardWidth, boardHeight int, hex bool, colour func(x, y int) color.RGBA) *Viewport {
+	v := &Viewport{boardWidth: boardWidth, boardHeight: boardHeight, hex: hex, colour: colour}
+	for boardWidth>>uint(-v.minZoom) > maxWindowSide || boardHeight>>uint(-v.minZoom) > maxWindowSide {
+		v.minZoom--
+	}
+	v.zoom = v.minZoom
+	if hex && v.zoom == 0 && 2*boardWidth+1 <= maxWindowSide && 2*boardHeight <= maxWindowSide {
+		v.zoom = 1
+	}
+	v.Width, v.Height = v.pixels(boardWidth), v.pixels(boardHeight)
+	if hex && v.zoom > 0 {
+		v.Width = min(v.Width+v.shift(1), maxWindowSide)
+	}
+	k := 1
+	for (boardWidth+k-1)/k > minimapSide || (boardHeight+k-1)/k > minimapSide {
+		k *= 2
+	}
+	v.minimap = newShrunk(k, 0, 0, (boardWidth+k-1)/k, (boardHeight+k-1)/k)
+	return v
+}
+
+// pixels gets the number of pixels n cells take up at the current zoom, up to maxWindowSide.
+func (v *Viewport) pixels(n int) int {
+	if v.zoom >= 0 {
+		n <<= uint(v.zoom)
+	} else {
+		k := 1 << uint(-v.zoom)
+		n = (n + k - 1) / k
+	}
+	return min(n, maxWindowSide)
+}
+
+// cells gets the number of cells n pixels show at the current zoom, counting a cell that's only partly shown.
+func (v *Viewport) cells(n int) int {
+	if v.zoom >= 0 {
+		size := 1 << uint(v.zoom)
+		return (n + size - 1) / size
+	}
+	return n << uint(-v.zoom)
+}
+
+// shift gets the number of pixels row y is shifted right by, which is half a cell on the odd rows of a hex board.
+func (v *Viewport) shift(y int) int {
+	if !v.hex || v.zoom <= 0 || y%2 == 0 {
+		return 0
+	}
+	return 1 << uint(v.zoom-1)
+}
+
+// Shown checks whether the whole board is shown in the window.
+func (v *Viewport) Shown() bool {
+	return v.left == 0 && v.top == 0 && v.cells(v.Width) >= v.boardWidth && v.cells(v.Height) >= v.boardHeight
+}
+
+// CellAt gets the cell drawn at a pixel of the window, if there's one there. Zoomed out, it's the cell at the top
+// left of the square of cells the pixel shows.
+func (v *Viewport) CellAt(px, py int) (x, y int, ok bool) {
+	if v.zoom >= 0 {
+		y = v.top + py>>uint(v.zoom)
+		offset := px - v.shift(y)
+		if offset < 0 {
+			return 0, 0, false
+		}
+		x = v.left + offset>>uint(v.zoom)
+	} else {
+		x, y = v.left+px<<uint(-v.zoom), v.top+py<<uint(-v.zoom)
+	}
+	return x, y, x >= 0 && x < v.boardWidth && y >= 0 && y < v.boardHeight
+}
+
+// Zoom zooms in by the given number of steps, or out if it's negative, keeping the cell under a pixel of the window
+// where it is. Each step doubles or halves the size of the cells, from the zoom the whole board fits at up to
+// maxZoom. It returns whether anything changed, in which case the window has to be drawn again.
+func (v *Viewport) Zoom(steps, px, py int) bool {
+	zoom := v.zoom + steps
+	if zoom < v.minZoom {
+		zoom = v.minZoom
+	}
+	if zoom > maxZoom {
+		zoom = maxZoom
+	}
+	if zoom == v.zoom {
+		return false
+	}
+	x, y, _ := v.CellAt(px, py)
+	v.zoom = zoom
+	v.left, v.top = 0, 0
+	ox, oy, _ := v.CellAt(px, py)
+	v.Pan(x-ox, y-oy)
+	return true
+}
+
+// Pan moves the window the given number of cells across the board, stopping at its edges. It returns whether anything
+// changed, in which case the window has to be drawn again.
+func (v *Viewport) Pan(dx, dy int) bool {
+	left := clamp(v.left+dx, 0, v.boardWidth-v.cells(v.Width))
+	top := clamp(v.top+dy, 0, v.boardHeight-v.cells(v.Height))
+	if v.hex && top%2 == 1 {
+		// Hex boards are panned a whole number of row pairs, so the odd rows stay shifted the same way
+		top--
+	}
+	changed := left != v.left || top != v.top
+	v.left, v.top = left, top
+	return changed
+}
+
+// PanPixels moves the window by a distance in pixels, e.g. the distance the mouse was dragged, which is at least a
+// cell if it's any distance at all.
+func (v *Viewport) PanPixels(dx, dy int) bool {
+	cells := func(d int) int {
+		if d == 0 {
+			return 0
+		}
+		n := d >> uint(max(v.zoom, 0)) << uint(max(-v.zoom, 0))
+		if n == 0 && d > 0 {
+			return 1
+		} else if n == 0 {
+			return -1
+		}
+		return n
+	}
+	return v.Pan(cells(dx), cells(dy))
+}
+
+// Centre moves the window so it's centred on a cell, as near as it can get without going past the edges.
+func (v *Viewport) Centre(x, y int) bool {
+	return v.Pan(x-v.cells(v.Width)/2-v.left, y-v.cells(v.Height)/2-v.top)
+}
+
+// Reset zooms out so the whole board is shown again.
+func (v *Viewport) Reset() bool {
+	zoomed := v.zoom != v.minZoom
+	v.zoom = v.minZoom
+	return v.Pan(-v.left, -v.top) || zoomed
+}
+
+// Draw draws every pixel of the window from the cells of the board, e.g. after zooming or panning, and marks the part
+// of the board that's in the window on the minimap.
+func (v *Viewport) Draw(w *Window) {
+	w.ClearPixels()
+	if v.zoom >= 0 {
+		for y := v.top; y < min(v.top+v.cells(v.Height), v.boardHeight); y++ {
+			for x := v.left; x < min(v.left+v.cells(v.Width), v.boardWidth); x++ {
+				v.drawCell(w, x, y, v.colour(x, y))
+			}
+		}
+	} else {
+		v.view = newShrunk(1<<uint(-v.zoom), v.left, v.top, v.Width, v.Height)
+		v.view.add(v.boardWidth, v.boardHeight, v.colour)
+		for i := range v.view.counts {
+			w.SetPixelColour(i%v.Width, i/v.Width, v.view.average(i))
+		}
+	}
+	v.frameMinimap(w)
+}
+
+// DrawMinimap draws every pixel of the minimap from the cells of the board, e.g. when another slice of a volume is
+// shown or every cell has changed colour. It's kept up to date by Change after that.
+func (v *Viewport) DrawMinimap(w *Window) {
+	v.minimap = newShrunk(v.minimap.k, 0, 0, v.minimap.width, v.minimap.height)
+	v.minimap.add(v.boardWidth, v.boardHeight, v.colour)
+	for i := range v.minimap.counts {
+		w.SetMinimapColour(i%v.minimap.width, i/v.minimap.width, v.minimap.average(i))
+	}
+}
+
+// MinimapSize gets the number of pixels along each side of the minimap.
+func (v *Viewport) MinimapSize() (width, height int) {
+	return v.minimap.width, v.minimap.height
+}
+
+// Change redraws a cell that's changed colour, only working out the pixels it's drawn on if it's in the window.
+func (v *Viewport) Change(w *Window, x, y int, before, after color.RGBA) {
+	if i, ok := v.minimap.change(x, y, before, after); ok {
+		w.SetMinimapColour(i%v.minimap.width, i/v.minimap.width, v.minimap.average(i))
+	}
+	if v.zoom >= 0 {
+		if x >= v.left && x < v.left+v.cells(v.Width) && y >= v.top && y < v.top+v.cells(v.Height) {
+			v.drawCell(w, x, y, after)
+		}
+	} else if i, ok := v.view.change(x, y, before, after); ok {
+		w.SetPixelColour(i%v.Width, i/v.Width, v.view.average(i))
+	}
+}
+
+// drawCell draws a cell as a square of pixels while zoomed in, leaving out any part of it that's outside the window.
+func (v *Viewport) drawCell(w *Window, x, y int, c color.RGBA) {
+	size := 1 << uint(v.zoom)
+	left, top := (x-v.left)*size+v.shift(y), (y-v.top)*size
+	for py := top; py < min(top+size, v.Height); py++ {
+		for px := left; px < min(left+size, v.Width); px++ {
+			w.SetPixelColour(px, py, c)
+		}
+	}
+}
+
+// frameMinimap shows the minimap with the part of the board that's in the window marked on it, or hides it if the
+// whole board is shown.
+func (v *Viewport) frameMinimap(w *Window) {
+	if v.Shown() {
+		w.HideMinimap()
+		return
+	}
+	k := v.minimap.k
+	w.ShowMinimap(v.left/k, v.top/k,
+		max((min(v.cells(v.Width), v.boardWidth)+k-1)/k, 1), max((min(v.cells(v.Height), v.boardHeight)+k-1)/k, 1))
+}
+
+// MinimapCell gets the cell of the board at a pixel of the window that's on the minimap, if it's shown there.
+func (v *Viewport) MinimapCell(w *Window, px, py int) (x, y int, ok bool) {
+	mx, my, ok := w.MinimapPixel(px, py)
+	return mx * v.minimap.k, my * v.minimap.k, ok
+}
+
+// shrunk holds the summed colours of each square of k by k cells of part of the board, so it can be drawn with each
+// pixel showing the average colour of a square. The sums are kept up to date as cells change, so the board doesn't
+// have to be gone over again.
+type shrunk struct {
+	k             int
+	left, top     int      // cell at the top left of the first square
+	width, height int      // number of squares along each side
+	sums          []uint32 // red, green and blue of the cells of each square added up, row by row
+	counts        []uint32 // number of cells of the board in each square, which is less than k*k at its edges
+}
+
+func newShrunk(k, left, top, width, height int) shrunk {
+	return shrunk{k, left, top, width, height, make([]uint32, 3*width*height), make([]uint32, width*height)}
+}
+
+// square gets the index of the square a cell is in, if it's in one.
+func (s *shrunk) square(x, y int) (int, bool) {
+	x, y = x-s.left, y-s.top
+	if x < 0 || y < 0 || x/s.k >= s.width || y/s.k >= s.height {
+		return 0, false
+	}
+	return y/s.k*s.width + x/s.k, true
+}
+
+// add adds up the colours of every cell of a board of the given size in the squares.
+func (s *shrunk) add(width, height int, colour func(x, y int) color.RGBA) {
+	for y := max(s.top, 0); y < min(s.top+s.height*s.k, height); y++ {
+		for x := max(s.left, 0); x < min(s.left+s.width*s.k, width); x++ {
+			i, _ := s.square(x, y)
+			c := colour(x, y)
+			s.sums[3*i] += uint32(c.R)
+			s.sums[3*i+1] += uint32(c.G)
+			s.sums[3*i+2] += uint32(c.B)
+			s.counts[i]++
+		}
+	}
+}
+
+// change swaps the colour of a cell in the sums, returning the square it's in if it's in one.
+func (s *shrunk) change(x, y int, from, to color.RGBA) (int, bool) {
+	i, ok := s.square(x, y)
+	if ok {
+		s.sums[3*i] += uint32(to.R) - uint32(from.R)
+		s.sums[3*i+1] += uint32(to.G) - uint32(from.G)
+		s.sums[3*i+2] += uint32(to.B) - uint32(from.B)
+	}
+	return i, ok
+}
+
+// average gets the average colour of the cells in a square, which is black if it's off the board.
+func (s *shrunk) average(i int) color.RGBA {
+	n := s.counts[i]
+	if n == 0 {
+		return color.RGBA{A: 0xFF}
+	}
+	return color.RGBA{uint8(s.sums[3*i] / n), uint8(s.sums[3*i+1] / n), uint8(s.sums[3*i+2] / n), 0xFF}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// clamp gets n moved into the range from lo to hi, or lo if the range is empty.
+func clamp(n, lo, hi int) int {
+	return max(min(n, hi), lo)
+}
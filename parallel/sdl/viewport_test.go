@@ -0,0 +1,244 @@
+package sdl
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+)
+
+var (
+	black = color.RGBA{A: 0xFF}
+	white = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+)
+
+// testBoard is a board of colours, all black to begin with, for a viewport to be drawn from.
+type testBoard struct {
+	width  int
+	cells  []color.RGBA
+	colour func(x, y int) color.RGBA
+}
+
+// newTestBoard creates a black board of the given size.
+func newTestBoard(width, height int) *testBoard {
+	b := &testBoard{width: width, cells: make([]color.RGBA, width*height)}
+	for i := range b.cells {
+		b.cells[i] = black
+	}
+	b.colour = func(x, y int) color.RGBA { return b.cells[y*b.width+x] }
+	return b
+}
+
+// set changes the colour of a cell of the board, returning the colour it was.
+func (b *testBoard) set(x, y int, c color.RGBA) color.RGBA {
+	before := b.cells[y*b.width+x]
+	b.cells[y*b.width+x] = c
+	return before
+}
+
+// newTestWindow creates a window for a viewport without opening it, so it can be drawn on without SDL.
+func newTestWindow(v *Viewport) *Window {
+	width, height := v.MinimapSize()
+	return &Window{
+		Width:   int32(v.Width),
+		Height:  int32(v.Height),
+		pixels:  make([]byte, v.Width*v.Height*4),
+		minimap: &minimap{width: int32(width), height: int32(height), pixels: make([]byte, width*height*4)},
+	}
+}
+
+// grey gets the red part of the colour of a pixel, which is the whole of it for shades of grey.
+func grey(pixels []byte, width, x, y int) byte {
+	return pixels[4*(y*width+x)+2]
+}
+
+// TestCellAt checks the cell drawn at a pixel zoomed in, zoomed out and on the shifted rows of a hex board.
+func TestCellAt(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		width, height int
+		hex           bool
+		px, py        int
+		x, y          int
+		ok            bool
+	}{
+		{"1:1", 100, 100, false, 5, 7, 5, 7, true},
+		{"off the board", 100, 100, false, 100, 7, 100, 7, false},
+		{"zoomed out", 4096, 8, false, 3, 1, 12, 4, true},
+		{"hex even row", 10, 10, true, 1, 1, 0, 0, true},
+		{"hex odd row", 10, 10, true, 2, 2, 0, 1, true},
+		{"hex odd row before its shift", 10, 10, true, 0, 2, 0, 0, false},
+		{"hex past the edge", 10, 10, true, 20, 0, 10, 0, false},
+	} {
+		v := NewViewport(test.width, test.height, test.hex, newTestBoard(test.width, test.height).colour)
+		x, y, ok := v.CellAt(test.px, test.py)
+		if ok != test.ok || ok && (x != test.x || y != test.y) {
+			t.Errorf("%v: pixel (%v, %v) is cell (%v, %v) %v, expected (%v, %v) %v",
+				test.name, test.px, test.py, x, y, ok, test.x, test.y, test.ok)
+		}
+	}
+}
+
+// TestZoom checks zooming keeps the cell under the mouse where it is, and stops at the zoom the board fits at and at
+// maxZoom.
+func TestZoom(t *testing.T) {
+	v := NewViewport(100, 100, false, newTestBoard(100, 100).colour)
+	if !v.Shown() || v.Zoom(-1, 0, 0) {
+		t.Fatalf("The viewport of a board as small as the window zoomed out past 1:1")
+	}
+	if !v.Zoom(2, 40, 60) {
+		t.Fatalf("The viewport didn't zoom in")
+	}
+	if x, y, _ := v.CellAt(40, 60); x != 40 || y != 60 {
+		t.Errorf("Zooming in at (40, 60) moved the cell there to (%v, %v)", x, y)
+	}
+	if x, y, _ := v.CellAt(0, 0); x != 30 || y != 45 {
+		t.Errorf("The window starts at cell (%v, %v) zoomed in 4 times, expected (30, 45)", x, y)
+	}
+	if v.Shown() {
+		t.Errorf("The whole board is shown zoomed in")
+	}
+	if !v.Zoom(10, 40, 60) || v.zoom != maxZoom || v.Zoom(1, 40, 60) {
+		t.Errorf("Zooming in stopped at %v, expected %v", v.zoom, maxZoom)
+	}
+	if !v.Zoom(-10, 40, 60) || !v.Shown() {
+		t.Errorf("Zooming out all the way doesn't show the whole board")
+	}
+
+	big := NewViewport(4096, 8, false, newTestBoard(4096, 8).colour)
+	if big.zoom != -2 || big.Width != maxWindowSide || big.Height != 2 {
+		t.Fatalf("A 4096x8 board fits the window at zoom %v, %vx%v pixels, expected -2, 1024x2", big.zoom, big.Width, big.Height)
+	}
+	if !big.Zoom(1, 512, 0) || big.Shown() {
+		t.Fatalf("The whole board is still shown after zooming in")
+	}
+	if x, _, _ := big.CellAt(512, 0); x != 2048 {
+		t.Errorf("Zooming in at the middle of the window moved the cell there from 2048 to %v", x)
+	}
+}
+
+// TestPan checks panning stops at the edges of the board, and only moves a hex board whole pairs of rows.
+func TestPan(t *testing.T) {
+	v := NewViewport(100, 100, false, newTestBoard(100, 100).colour)
+	if v.Pan(5, 5) {
+		t.Errorf("The window moved across a board that's shown whole")
+	}
+	v.Zoom(2, 0, 0)
+	if v.Pan(-5, -5) {
+		t.Errorf("The window moved past the top left of the board")
+	}
+	if !v.Pan(1000, 10) || v.left != 75 || v.top != 10 {
+		t.Errorf("The window was panned to (%v, %v), expected (75, 10)", v.left, v.top)
+	}
+	if !v.PanPixels(-1, 0) || v.left != 74 {
+		t.Errorf("Dragging by a pixel panned the window to %v, expected a cell to 74", v.left)
+	}
+	if !v.Centre(50, 50) || v.left != 38 || v.top != 38 {
+		t.Errorf("Centring on (50, 50) panned the window to (%v, %v), expected (38, 38)", v.left, v.top)
+	}
+	if !v.Reset() || !v.Shown() {
+		t.Errorf("The whole board isn't shown after resetting")
+	}
+
+	hex := NewViewport(100, 100, true, newTestBoard(100, 100).colour)
+	hex.Zoom(2, 0, 0)
+	if !hex.Pan(0, 3) || hex.top != 2 {
+		t.Errorf("A hex board was panned to row %v, expected 2", hex.top)
+	}
+}
+
+// TestShrunk checks the squares average the cells of the board that are in them, with fewer cells in the squares at
+// its edges, and that changing a cell changes only the average of its square.
+func TestShrunk(t *testing.T) {
+	b := newTestBoard(3, 3)
+	b.set(0, 0, white)
+	b.set(2, 0, color.RGBA{0x40, 0x80, 0xC0, 0xFF})
+	b.set(2, 2, white)
+	s := newShrunk(2, 0, 0, 2, 2)
+	s.add(3, 3, b.colour)
+	for i, expected := range []color.RGBA{
+		{0x3F, 0x3F, 0x3F, 0xFF},
+		{0x20, 0x40, 0x60, 0xFF},
+		black,
+		white,
+	} {
+		if average := s.average(i); average != expected {
+			t.Errorf("Square %v averages %v, expected %v", i, average, expected)
+		}
+	}
+
+	if i, ok := s.change(1, 1, black, white); !ok || i != 0 {
+		t.Fatalf("Cell (1, 1) is in square %v %v, expected 0", i, ok)
+	}
+	if average := s.average(0); average != (color.RGBA{0x7F, 0x7F, 0x7F, 0xFF}) {
+		t.Errorf("Square 0 averages %v after a cell changed, expected half white", average)
+	}
+	if i, ok := s.change(0, 0, white, black); !ok || i != 0 || s.average(0) != (color.RGBA{0x3F, 0x3F, 0x3F, 0xFF}) {
+		t.Errorf("Square 0 averages %v after a cell changed back, expected a quarter white", s.average(0))
+	}
+	if _, ok := s.change(4, 0, black, white); ok {
+		t.Errorf("A cell off the board was found in a square")
+	}
+
+	// A square that's off the board has no cells in it, and is black
+	off := newShrunk(2, 2, 2, 2, 2)
+	off.add(3, 3, b.colour)
+	if off.counts[0] != 1 || off.counts[1] != 0 || off.average(1) != black {
+		t.Errorf("The squares at the edge of the board hold %v cells, expected 1 and none past it", off.counts)
+	}
+}
+
+// TestChange checks a changed cell is drawn in the window and on the minimap, zoomed in and out, but only works out
+// the pixels of the window if the cell is in the part of the board that's shown.
+func TestChange(t *testing.T) {
+	b := newTestBoard(400, 400)
+	v := NewViewport(400, 400, false, b.colour)
+	w := newTestWindow(v)
+	v.Zoom(2, 0, 0)
+	v.Pan(50, 50)
+	v.Draw(w)
+	v.DrawMinimap(w)
+
+	drawn := append([]byte(nil), w.pixels...)
+	v.Change(w, 200, 200, b.set(200, 200, white), white)
+	v.Change(w, 10, 60, b.set(10, 60, white), white)
+	if !bytes.Equal(w.pixels, drawn) {
+		t.Errorf("Cells outside the window were drawn in it")
+	}
+	if level := grey(w.minimap.pixels, 100, 50, 50); level != 0xFF/16 {
+		t.Errorf("The minimap shows the changed cell as %v, expected %v", level, 0xFF/16)
+	}
+	v.Change(w, 60, 60, b.set(60, 60, white), white)
+	for _, p := range [][2]int{{40, 40}, {43, 43}, {39, 40}, {44, 43}} {
+		inside := p[0] >= 40 && p[0] < 44
+		if level := grey(w.pixels, v.Width, p[0], p[1]); (level == 0xFF) != inside {
+			t.Errorf("Pixel %v is %v after cell (60, 60) changed in the window zoomed in 4 times", p, level)
+		}
+	}
+
+	// Zoomed out, each pixel is the average of a square of cells, and only the squares in the window are kept
+	b = newTestBoard(4096, 8)
+	v = NewViewport(4096, 8, false, b.colour)
+	w = newTestWindow(v)
+	b.set(0, 0, white)
+	v.Draw(w)
+	if level := grey(w.pixels, v.Width, 0, 0); level != 0xFF/16 {
+		t.Errorf("A pixel showing a white cell of 16 is %v, expected %v", level, 0xFF/16)
+	}
+	v.Change(w, 1, 1, b.set(1, 1, white), white)
+	if level := grey(w.pixels, v.Width, 0, 0); level != 2*0xFF/16 {
+		t.Errorf("A pixel showing 2 white cells of 16 is %v, expected %v", level, 2*0xFF/16)
+	}
+
+	v.Zoom(1, 0, 0)
+	v.Draw(w)
+	drawn = append(drawn[:0], w.pixels...)
+	v.Change(w, 3000, 0, b.set(3000, 0, white), white)
+	v.Change(w, 0, 5, b.set(0, 5, white), white)
+	if !bytes.Equal(w.pixels, drawn) {
+		t.Errorf("Cells outside the window were drawn in it")
+	}
+	v.Change(w, 3, 0, b.set(3, 0, white), white)
+	if level := grey(w.pixels, v.Width, 1, 0); level != 0xFF/4 {
+		t.Errorf("A pixel showing a white cell of 4 is %v, expected %v", level, 0xFF/4)
+	}
+}
@@ -0,0 +1,187 @@
+// Package session saves whole runs to a file and loads them back, so a run can be carried on exactly where it left
+// off. A session file is a gzipped tar archive holding a JSON manifest, with the turn, the rule, the topology, the seed
+// random numbers are drawn from and the params of the run, and the board as a PGM image.
+package session
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/rule"
+)
+
+// Version is the version of the format sessions are saved in, which is checked when they're loaded.
+const Version = 1
+
+const (
+	manifestName = "manifest.json"
+	boardName    = "board.pgm"
+)
+
+// Manifest describes a saved run.
+type Manifest struct {
+	Version int `json:"version"`
+
+	// Turn is the number of turns completed when the run was saved.
+	Turn int `json:"turn"`
+
+	// Width and Height are the size of the board, where the height counts every slice of a volume.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	Rule     rule.Spec `json:"rule"`
+	Topology string    `json:"topology"`
+
+	// Seed is the seed stochastic rules draw their random numbers from. They're drawn for each cell and turn, so the
+	// seed and the turn are all there is to the state of the random numbers.
+	Seed int64 `json:"seed"`
+
+	// Params are the params of the run, as JSON so this package doesn't depend on the game.
+	Params json.RawMessage `json:"params"`
+}
+
+// Session is a saved run: its manifest and the board on the turn it was saved.
+type Session struct {
+	Manifest
+	World [][]byte
+}
+
+// Save saves a session to a file.
+func Save(path string, s Session) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = Write(file, s); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Load loads a session from a file. The error is an *os.PathError if the file can't be opened, and says what's wrong
+// with the session otherwise.
+func Load(path string) (Session, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Session{}, err
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// Write writes a session as a gzipped tar archive.
+func Write(w io.Writer, s Session) error {
+	s.Version = Version
+	s.Height = len(s.World)
+	if s.Height > 0 {
+		s.Width = len(s.World[0])
+	}
+	manifest, err := json.MarshalIndent(s.Manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	var board bytes.Buffer
+	fmt.Fprintf(&board, "P5\n%d %d\n255\n", s.Width, s.Height)
+	for _, row := range s.World {
+		board.Write(row)
+	}
+
+	compressed := gzip.NewWriter(w)
+	archive := tar.NewWriter(compressed)
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{{manifestName, manifest}, {boardName, board.Bytes()}} {
+		header := &tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.data)), ModTime: time.Now()}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := archive.Write(file.data); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
+
+// Read reads a session from a gzipped tar archive, checking the board is the size the manifest says.
+func Read(r io.Reader) (Session, error) {
+	compressed, err := gzip.NewReader(r)
+	if err != nil {
+		return Session{}, err
+	}
+	archive := tar.NewReader(compressed)
+	files := map[string][]byte{}
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Session{}, err
+		}
+		if files[header.Name], err = ioutil.ReadAll(archive); err != nil {
+			return Session{}, err
+		}
+	}
+
+	var s Session
+	manifest, ok := files[manifestName]
+	if !ok {
+		return Session{}, fmt.Errorf("there's no %v", manifestName)
+	}
+	if err := json.Unmarshal(manifest, &s.Manifest); err != nil {
+		return Session{}, err
+	}
+	if s.Version != Version {
+		return Session{}, fmt.Errorf("it's version %v of the format, expected version %v", s.Version, Version)
+	}
+	board, ok := files[boardName]
+	if !ok {
+		return Session{}, fmt.Errorf("there's no %v", boardName)
+	}
+	if s.World, err = readPGM(board, s.Width, s.Height); err != nil {
+		return Session{}, err
+	}
+	return s, nil
+}
+
+// readPGM reads the board from a binary PGM image of the given size.
+func readPGM(data []byte, width, height int) ([][]byte, error) {
+	reader := bufio.NewReader(bytes.NewReader(data))
+	var magic string
+	var w, h, maxval int
+	if _, err := fmt.Fscan(reader, &magic, &w, &h, &maxval); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(magic) != "P5" || maxval != 255 {
+		return nil, fmt.Errorf("the board isn't an 8-bit binary PGM")
+	}
+	if w != width || h != height {
+		return nil, fmt.Errorf("the board is %vx%v, but the manifest says %vx%v", w, h, width, height)
+	}
+	// A single whitespace character separates the header from the pixels
+	if _, err := reader.ReadByte(); err != nil {
+		return nil, err
+	}
+	world := make([][]byte, height)
+	for y := range world {
+		world[y] = make([]byte, width)
+		if _, err := io.ReadFull(reader, world[y]); err != nil {
+			return nil, fmt.Errorf("the board is cut short: %v", err)
+		}
+	}
+	return world, nil
+}
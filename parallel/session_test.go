@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/session"
+)
+
+// TestSession pauses a stochastic run with a random seed, saves its session with 'e' and lets it finish. Carrying the
+// session on with LoadSession, on a different number of threads, has to end on the same board, which takes the seed
+// the run picked as well as the turn and the board it was saved on.
+func TestSession(t *testing.T) {
+	p := gol.Params{
+		Turns:       1000,
+		Threads:     4,
+		ImageWidth:  64,
+		ImageHeight: 64,
+		Rule:        "stochastic:B3/S23,update=0.5",
+	}
+	events := make(chan gol.Event, 1000)
+	keyPresses := make(chan rune, 10)
+	gol.Run(p, events, keyPresses)
+
+	keyPresses <- 'p'
+	pausedOn := awaitEvent(t, events, func(e gol.Event) bool {
+		stateChange, ok := e.(gol.StateChange)
+		return ok && stateChange.NewState == gol.Paused
+	}).GetCompletedTurns()
+	keyPresses <- 'e'
+	saved := awaitEvent(t, events, func(e gol.Event) bool {
+		_, ok := e.(gol.SessionOutputComplete)
+		return ok
+	}).(gol.SessionOutputComplete)
+	if saved.CompletedTurns != pausedOn {
+		t.Fatalf("Saved the session on turn %v after pausing on turn %v", saved.CompletedTurns, pausedOn)
+	}
+	keyPresses <- 'p'
+	for range events {
+	}
+	filename := fmt.Sprintf("out/%dx%dx%d.pgm", p.ImageWidth, p.ImageHeight, p.Turns)
+	expected := readPgm(t, filename)
+
+	s, err := session.Load(saved.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Turn != pausedOn || s.Width != p.ImageWidth || s.Height != p.ImageHeight || s.Rule.Name != "stochastic" || s.Seed == 0 {
+		t.Fatalf("The manifest of %v is %+v", saved.Filename, s.Manifest)
+	}
+	loaded, err := gol.LoadSession(saved.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Rule != p.Rule || loaded.Turns != p.Turns || loaded.Seed != s.Seed || loaded.Session != saved.Filename {
+		t.Fatalf("Loaded the params %+v from %v", loaded, saved.Filename)
+	}
+
+	loaded.Threads = 3
+	events = make(chan gol.Event, 1000)
+	gol.Run(loaded, events, nil)
+	turns := 0
+	for event := range events {
+		switch e := event.(type) {
+		case gol.TurnComplete:
+			turns++
+		case gol.FinalTurnComplete:
+			if e.CompletedTurns != p.Turns {
+				t.Errorf("The session carried on to turn %v, expected %v", e.CompletedTurns, p.Turns)
+			}
+		}
+	}
+	if turns != p.Turns-pausedOn {
+		t.Errorf("Carrying on from turn %v took %v turns, expected %v", pausedOn, turns, p.Turns-pausedOn)
+	}
+	output := readPgm(t, filename)
+	for y := range expected {
+		if !bytes.Equal(output[y], expected[y]) {
+			t.Fatalf("Row %v after carrying on from turn %v is %v, expected %v", y, pausedOn, output[y], expected[y])
+		}
+	}
+}
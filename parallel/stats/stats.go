@@ -0,0 +1,175 @@
+// Package stats measures the population and activity of a board every turn and writes the series out
+// as CSV or JSON Lines. The first two CSV columns match check/alive/*.csv, so the same checks can be run on them.
+package stats
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const alive = 255
+
+// Turn holds the metrics of the board after CompletedTurns turns. The bounding box of the alive cells is -1
+// on every side if there are none, and Regions holds the proportion of alive cells in each region of an
+// evenly split grid, row by row.
+type Turn struct {
+	CompletedTurns int       `json:"completedTurns"`
+	Alive          int       `json:"aliveCells"`
+	Births         int       `json:"births"`
+	Deaths         int       `json:"deaths"`
+	MinX           int       `json:"minX"`
+	MinY           int       `json:"minY"`
+	MaxX           int       `json:"maxX"`
+	MaxY           int       `json:"maxY"`
+	Regions        []float64 `json:"regions"`
+}
+
+// Row holds the metrics of a single row, so they can be measured wherever the row is computed and combined later.
+// Regions holds the number of alive cells in each column of the region grid.
+type Row struct {
+	Alive   int
+	Births  int
+	Deaths  int
+	MinX    int
+	MaxX    int
+	Regions []int
+}
+
+// MeasureRow measures a row after a turn, given the same row before the turn.
+func MeasureRow(before, after []byte, regions int) Row {
+	row := Row{MinX: -1, MaxX: -1, Regions: make([]int, regions)}
+	width := len(after)
+	for x, cell := range after {
+		wasAlive := before[x] == alive
+		if cell == alive {
+			row.Alive++
+			if row.MinX == -1 {
+				row.MinX = x
+			}
+			row.MaxX = x
+			row.Regions[x*regions/width]++
+			if !wasAlive {
+				row.Births++
+			}
+		} else if wasAlive {
+			row.Deaths++
+		}
+	}
+	return row
+}
+
+// Combine combines the metrics of every row of a board, given from top to bottom.
+func Combine(completedTurns int, rows []Row, width, regions int) Turn {
+	t := Turn{CompletedTurns: completedTurns, MinX: -1, MinY: -1, MaxX: -1, MaxY: -1, Regions: make([]float64, regions*regions)}
+	height := len(rows)
+	for y, row := range rows {
+		t.Alive += row.Alive
+		t.Births += row.Births
+		t.Deaths += row.Deaths
+		if row.Alive > 0 {
+			if t.MinY == -1 {
+				t.MinY = y
+			}
+			t.MaxY = y
+			if t.MinX == -1 || row.MinX < t.MinX {
+				t.MinX = row.MinX
+			}
+			if row.MaxX > t.MaxX {
+				t.MaxX = row.MaxX
+			}
+		}
+		for i, count := range row.Regions {
+			t.Regions[(y*regions/height)*regions+i] += float64(count)
+		}
+	}
+	for i := range t.Regions {
+		if area := span(i/regions, height, regions) * span(i%regions, width, regions); area > 0 {
+			t.Regions[i] /= float64(area)
+		}
+	}
+	return t
+}
+
+// span returns the number of rows or columns out of size that fall into the given region.
+func span(region, size, regions int) int {
+	n := 0
+	for i := 0; i < size; i++ {
+		if i*regions/size == region {
+			n++
+		}
+	}
+	return n
+}
+
+// Measure measures a whole board after a turn, given the board before the turn.
+func Measure(completedTurns int, before, after [][]byte, regions int) Turn {
+	rows := make([]Row, len(after))
+	for y := range after {
+		rows[y] = MeasureRow(before[y], after[y], regions)
+	}
+	return Combine(completedTurns, rows, len(after[0]), regions)
+}
+
+// Writer writes a series of turns as CSV, or as JSON Lines with one turn per line.
+type Writer struct {
+	out       *bufio.Writer
+	csv       *csv.Writer
+	regions   int
+	wroteHead bool
+}
+
+// NewWriter creates a writer for turns measured with the given number of regions along each side.
+func NewWriter(w io.Writer, jsonLines bool, regions int) *Writer {
+	writer := &Writer{out: bufio.NewWriter(w), regions: regions}
+	if !jsonLines {
+		writer.csv = csv.NewWriter(writer.out)
+	}
+	return writer
+}
+
+// Write writes a turn, preceded by the CSV header if it's the first one.
+func (w *Writer) Write(t Turn) error {
+	if w.csv == nil {
+		line, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		_, err = w.out.Write(append(line, '\n'))
+		return err
+	}
+	if !w.wroteHead {
+		header := []string{"completed_turns", "alive_cells", "births", "deaths", "min_x", "min_y", "max_x", "max_y"}
+		for y := 0; y < w.regions; y++ {
+			for x := 0; x < w.regions; x++ {
+				header = append(header, fmt.Sprintf("region_%d_%d", y, x))
+			}
+		}
+		if err := w.csv.Write(header); err != nil {
+			return err
+		}
+		w.wroteHead = true
+	}
+	record := []string{
+		strconv.Itoa(t.CompletedTurns), strconv.Itoa(t.Alive), strconv.Itoa(t.Births), strconv.Itoa(t.Deaths),
+		strconv.Itoa(t.MinX), strconv.Itoa(t.MinY), strconv.Itoa(t.MaxX), strconv.Itoa(t.MaxY),
+	}
+	for _, density := range t.Regions {
+		record = append(record, strconv.FormatFloat(density, 'f', 4, 64))
+	}
+	return w.csv.Write(record)
+}
+
+// Flush writes any buffered turns to the underlying writer.
+func (w *Writer) Flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return w.out.Flush()
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestStep pauses the distributor, steps one turn and then p.StepTurns turns, checking the alive cells after each step.
+func TestStep(t *testing.T) {
+	p := gol.Params{
+		Turns:       1000,
+		Threads:     4,
+		ImageWidth:  64,
+		ImageHeight: 64,
+		StepTurns:   10,
+	}
+	alive := readAliveCounts(p.ImageWidth, p.ImageHeight)
+	events := make(chan gol.Event, 1000)
+	keyPresses := make(chan rune, 10)
+	gol.Run(p, events, keyPresses)
+
+	keyPresses <- 'p'
+	pausedOn := awaitEvent(t, events, func(e gol.Event) bool {
+		stateChange, ok := e.(gol.StateChange)
+		return ok && stateChange.NewState == gol.Paused
+	}).GetCompletedTurns()
+
+	for _, step := range []struct {
+		key   rune
+		turns int
+	}{{'n', 1}, {'m', p.StepTurns}, {'n', 1}} {
+		keyPresses <- step.key
+		count := awaitEvent(t, events, func(e gol.Event) bool {
+			count, ok := e.(gol.AliveCellsCount)
+			return ok && count.CompletedTurns > pausedOn
+		}).(gol.AliveCellsCount)
+		if count.CompletedTurns != pausedOn+step.turns {
+			t.Fatalf("Stepping %v turns from turn %v ended on turn %v", step.turns, pausedOn, count.CompletedTurns)
+		}
+		if count.CellsCount != alive[count.CompletedTurns] {
+			t.Fatalf("At turn %v expected %v alive cells, got %v instead", count.CompletedTurns, alive[count.CompletedTurns], count.CellsCount)
+		}
+		pausedOn = count.CompletedTurns
+	}
+
+	keyPresses <- 'p'
+	awaitEvent(t, events, func(e gol.Event) bool {
+		stateChange, ok := e.(gol.StateChange)
+		return ok && stateChange.NewState == gol.Executing
+	})
+	for range events {
+	}
+}
+
+// TestRewind pauses the distributor, steps forward and rewinds with 'b', 'v' and 'g', checking the alive cells of every retained turn it goes back to.
+func TestRewind(t *testing.T) {
+	p := gol.Params{
+		Turns:         1000,
+		Threads:       4,
+		ImageWidth:    64,
+		ImageHeight:   64,
+		StepTurns:     10,
+		HistoryBudget: 1024 * 1024,
+	}
+	alive := readAliveCounts(p.ImageWidth, p.ImageHeight)
+	events := make(chan gol.Event, 1000)
+	keyPresses := make(chan rune, 10)
+	gol.Run(p, events, keyPresses)
+
+	keyPresses <- 'p'
+	pausedOn := awaitEvent(t, events, func(e gol.Event) bool {
+		stateChange, ok := e.(gol.StateChange)
+		return ok && stateChange.NewState == gol.Paused
+	}).GetCompletedTurns()
+
+	// Make sure there are enough turns to go back through
+	keyPresses <- 'm'
+	awaitEvent(t, events, func(e gol.Event) bool {
+		count, ok := e.(gol.AliveCellsCount)
+		return ok && count.CompletedTurns == pausedOn+p.StepTurns
+	})
+	pausedOn += p.StepTurns
+
+	for _, rewind := range []struct {
+		key   rune
+		turns int
+	}{{'b', 1}, {'v', p.StepTurns}, {'n', -1}, {'b', 1}, {'g', pausedOn}} {
+		keyPresses <- rewind.key
+		target := pausedOn - rewind.turns
+		if rewind.key == 'g' || target < 0 {
+			target = 0 // nothing is evicted from the history, so everything back to the initial turn is retained
+		}
+		count := awaitEvent(t, events, func(e gol.Event) bool {
+			count, ok := e.(gol.AliveCellsCount)
+			return ok && count.CompletedTurns == target
+		}).(gol.AliveCellsCount)
+		if expected, ok := alive[count.CompletedTurns]; ok && count.CellsCount != expected {
+			t.Fatalf("Rewound to turn %v and expected %v alive cells, got %v instead", count.CompletedTurns, expected, count.CellsCount)
+		}
+		pausedOn = count.CompletedTurns
+	}
+
+	keyPresses <- 'p'
+	for event := range events {
+		switch e := event.(type) {
+		case gol.FinalTurnComplete:
+			if len(e.Alive) != alive[e.CompletedTurns] {
+				t.Fatalf("After rewinding, expected %v alive cells on the final turn %v, got %v instead", alive[e.CompletedTurns], e.CompletedTurns, len(e.Alive))
+			}
+		}
+	}
+}
+
+// TestRewindGenerations rewinds a Brian's Brain spaceship on the 8x8 image, which moves one cell to the right every
+// turn, and checks the board going by the CellFlipped events is the starting image shifted along, dying cells and all.
+func TestRewindGenerations(t *testing.T) {
+	p := gol.Params{
+		Turns:         100000,
+		Threads:       3,
+		ImageWidth:    8,
+		ImageHeight:   8,
+		Rule:          "B2/S/C3",
+		StepTurns:     10,
+		HistoryBudget: 1024 * 1024,
+	}
+	initial := readPgm(t, "images/8x8.pgm")
+	board := make([][]byte, p.ImageHeight)
+	for y := range board {
+		board[y] = make([]byte, p.ImageWidth)
+	}
+	flipped := func(e gol.Event) {
+		if flip, ok := e.(gol.CellFlipped); ok {
+			board[flip.Cell.Y][flip.Cell.X] = flip.State
+		}
+	}
+	events := make(chan gol.Event, 1000)
+	keyPresses := make(chan rune, 10)
+	gol.Run(p, events, keyPresses)
+
+	keyPresses <- 'p'
+	pausedOn := awaitEvent(t, events, func(e gol.Event) bool {
+		flipped(e)
+		stateChange, ok := e.(gol.StateChange)
+		return ok && stateChange.NewState == gol.Paused
+	}).GetCompletedTurns()
+	keyPresses <- 'm'
+	awaitEvent(t, events, func(e gol.Event) bool {
+		flipped(e)
+		count, ok := e.(gol.AliveCellsCount)
+		return ok && count.CompletedTurns == pausedOn+p.StepTurns
+	})
+	keyPresses <- 'v'
+	awaitEvent(t, events, func(e gol.Event) bool {
+		flipped(e)
+		count, ok := e.(gol.AliveCellsCount)
+		return ok && count.CompletedTurns == pausedOn
+	})
+	for y := range initial {
+		expected := make([]byte, p.ImageWidth)
+		for x := range expected {
+			expected[(x+pausedOn)%p.ImageWidth] = initial[y][x]
+		}
+		if !bytes.Equal(board[y], expected) {
+			t.Errorf("Row %v after rewinding to turn %v is %v, expected %v", y, pausedOn, board[y], expected)
+		}
+	}
+	keyPresses <- 'p'
+	for range events {
+	}
+}
+
+// TestRewindStatsHeatMap steps forward and rewinds with metrics and heat maps, and checks both end up the same as those
+// of a run that was never rewound, as the turns rewound past are taken back out of them.
+func TestRewindStatsHeatMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rewind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	run := func(rewind bool) ([]byte, [][]byte) {
+		p := gol.Params{
+			Turns:         1000,
+			Threads:       4,
+			ImageWidth:    64,
+			ImageHeight:   64,
+			StepTurns:     10,
+			HistoryBudget: 1024 * 1024,
+			StatsFile:     filepath.Join(dir, "stats.csv"),
+			HeatMap:       true,
+		}
+		events := make(chan gol.Event, 1000)
+		keyPresses := make(chan rune, 10)
+		gol.Run(p, events, keyPresses)
+		if rewind {
+			keyPresses <- 'p'
+			pausedOn := awaitEvent(t, events, func(e gol.Event) bool {
+				stateChange, ok := e.(gol.StateChange)
+				return ok && stateChange.NewState == gol.Paused
+			}).GetCompletedTurns()
+			for _, step := range []struct {
+				key   rune
+				turns int
+			}{{'m', p.StepTurns}, {'n', 1}, {'v', -p.StepTurns}, {'b', -1}} {
+				keyPresses <- step.key
+				target := pausedOn + step.turns
+				if target < 0 {
+					target = 0
+				}
+				awaitEvent(t, events, func(e gol.Event) bool {
+					count, ok := e.(gol.AliveCellsCount)
+					return ok && count.CompletedTurns == target
+				})
+				pausedOn = target
+			}
+			keyPresses <- 'p'
+		}
+		var heat [][]byte
+		for event := range events {
+			if e, ok := event.(gol.HeatMapOutputComplete); ok {
+				for _, filename := range e.Filenames {
+					data, err := ioutil.ReadFile("out/" + filename + ".pgm")
+					if err != nil {
+						t.Fatal(err)
+					}
+					heat = append(heat, data)
+				}
+			}
+		}
+		metrics, err := ioutil.ReadFile(p.StatsFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return metrics, heat
+	}
+
+	rewoundStats, rewoundHeat := run(true)
+	expectedStats, expectedHeat := run(false)
+	if !bytes.Equal(rewoundStats, expectedStats) {
+		t.Errorf("The metrics of the rewound run differ from those of a run that wasn't rewound")
+	}
+	if len(rewoundHeat) != 2 || len(expectedHeat) != 2 {
+		t.Fatalf("Expected alive and flips heat maps, got %v and %v", len(rewoundHeat), len(expectedHeat))
+	}
+	for i := range rewoundHeat {
+		if !bytes.Equal(rewoundHeat[i], expectedHeat[i]) {
+			t.Errorf("Heat map %v of the rewound run differs from that of a run that wasn't rewound", i)
+		}
+	}
+}
+
+// awaitEvent returns the first event matching the condition, failing if none is received within 10 seconds.
+func awaitEvent(t *testing.T, events <-chan gol.Event, condition func(gol.Event) bool) gol.Event {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatal("events channel closed before the expected event was received")
+			}
+			if condition(event) {
+				return event
+			}
+		case <-timeout:
+			t.Fatal("expected event not received in 10 seconds")
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Package theme colours the cells of a board, in the SDL window and in the PNG and GIF images exported from it, either
+// by their state in one of a few colour schemes or by their age, the number of turns since they last changed state.
+package theme
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/rule"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+const alive = 255
+
+// oldAge is the age cells fade all the way to the colour of old cells at. Ages are faded along a log scale, so the
+// changes in the first few turns stand out.
+const oldAge = 1024
+
+// Theme is a colour scheme for the cells of a board. Cells are shown in their state between Dead and Alive, or by age
+// with alive cells fading from Born to Old and dead cells from Died to Dead.
+type Theme struct {
+	Name        string
+	Dead, Alive color.RGBA
+	Born, Old   color.RGBA
+	Died        color.RGBA
+}
+
+// Classic is white cells on black, and shows the states of other rules in the rule's own colours.
+var Classic = Theme{
+	Name:  "classic",
+	Dead:  color.RGBA{0, 0, 0, 255},
+	Alive: color.RGBA{255, 255, 255, 255},
+	Born:  color.RGBA{255, 255, 255, 255},
+	Old:   color.RGBA{40, 80, 255, 255},
+	Died:  color.RGBA{110, 110, 110, 255},
+}
+
+// themes holds the themes by name.
+var themes = map[string]Theme{
+	"classic": Classic,
+	"ocean": {
+		Name:  "ocean",
+		Dead:  color.RGBA{2, 16, 48, 255},
+		Alive: color.RGBA{64, 224, 255, 255},
+		Born:  color.RGBA{200, 255, 255, 255},
+		Old:   color.RGBA{16, 96, 192, 255},
+		Died:  color.RGBA{16, 64, 112, 255},
+	},
+	"fire": {
+		Name:  "fire",
+		Dead:  color.RGBA{16, 0, 0, 255},
+		Alive: color.RGBA{255, 160, 32, 255},
+		Born:  color.RGBA{255, 255, 128, 255},
+		Old:   color.RGBA{192, 16, 0, 255},
+		Died:  color.RGBA{96, 16, 0, 255},
+	},
+	"paper": {
+		Name:  "paper",
+		Dead:  color.RGBA{255, 255, 255, 255},
+		Alive: color.RGBA{0, 0, 0, 255},
+		Born:  color.RGBA{0, 0, 0, 255},
+		Old:   color.RGBA{64, 64, 160, 255},
+		Died:  color.RGBA{192, 192, 192, 255},
+	},
+}
+
+// Parse gets a theme by name. It's Classic if the name is empty.
+func Parse(name string) (Theme, error) {
+	if name == "" {
+		return Classic, nil
+	}
+	t, ok := themes[strings.ToLower(name)]
+	if !ok {
+		return Theme{}, fmt.Errorf("%q is not a theme, which are %v", name, strings.Join(Names(), ", "))
+	}
+	return t, nil
+}
+
+// Names gets the names of the themes, in order.
+func Names() []string {
+	var names []string
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Next gets the theme after t in order of name, going back to the first after the last, so they can be cycled through.
+func Next(t Theme) Theme {
+	names := Names()
+	for i, name := range names {
+		if name == t.Name {
+			return themes[names[(i+1)%len(names)]]
+		}
+	}
+	return Classic
+}
+
+// Painter colours cells in a theme, either by state or, if Ages is set, by age.
+type Painter struct {
+	Theme Theme
+	Rule  rule.Rule
+	Ages  bool
+}
+
+// Colour gets the colour of a cell with the grey level, which has been in its state for age turns. Cells in the
+// refractory states of a rule are always shown by state, as they change state every turn.
+func (p Painter) Colour(level byte, age int) color.RGBA {
+	if p.Ages && (level == 0 || level == alive) {
+		fade := math.Min(math.Log2(float64(age)+1)/math.Log2(oldAge), 1)
+		if level == alive {
+			return mix(p.Theme.Born, p.Theme.Old, fade)
+		}
+		return mix(p.Theme.Died, p.Theme.Dead, fade)
+	}
+	if p.Theme.Name == Classic.Name {
+		return p.Rule.Colour(level)
+	}
+	return mix(p.Theme.Dead, p.Theme.Alive, float64(level)/alive)
+}
+
+// Image draws a board with a pixel for each cell. The age of each cell is only needed when colouring by age, and can
+// be nil otherwise.
+func (p Painter) Image(board [][]byte, age func(x, y int) int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(board[0]), len(board)))
+	for y := range board {
+		for x, level := range board[y] {
+			a := 0
+			if age != nil {
+				a = age(x, y)
+			}
+			img.SetRGBA(x, y, p.Colour(level, a))
+		}
+	}
+	return img
+}
+
+// mix gets the colour a fraction of the way from one colour to another.
+func mix(from, to color.RGBA, fraction float64) color.RGBA {
+	channel := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*fraction))
+	}
+	return color.RGBA{channel(from.R, to.R), channel(from.G, to.G), channel(from.B, to.B), 255}
+}
+
+// Ages keeps the turn each cell of a board last changed state on, so the age of each cell can be worked out without
+// going over the whole board every turn. Cells are flipped by the CellFlipped events, which come before the
+// TurnComplete event of the turn they changed on, so flips are only given a turn once it's complete.
+type Ages struct {
+	turn    int
+	changed [][]int
+	flipped []util.Cell
+}
+
+// NewAges creates ages for a board of the given size, where every cell last changed state on turn 0.
+func NewAges(height, width int) *Ages {
+	a := &Ages{changed: make([][]int, height)}
+	for y := range a.changed {
+		a.changed[y] = make([]int, width)
+	}
+	return a
+}
+
+// Flip records that a cell changed state on the turn that's being completed.
+func (a *Ages) Flip(x, y int) {
+	a.flipped = append(a.flipped, util.Cell{X: x, Y: y})
+}
+
+// Turn records the turn the board is on once it's complete, which the cells flipped since the last turn changed on.
+func (a *Ages) Turn(turn int) {
+	a.turn = turn
+	for _, cell := range a.flipped {
+		a.changed[cell.Y][cell.X] = turn
+	}
+	a.flipped = a.flipped[:0]
+}
+
+// Age gets the number of turns since a cell last changed state. After rewinding, cells that changed on a later turn
+// and haven't changed back are 0 turns old.
+func (a *Ages) Age(x, y int) int {
+	if age := a.turn - a.changed[y][x]; age > 0 {
+		return age
+	}
+	return 0
+}
+
+// Recording collects images of a board as the frames of an animated GIF.
+type Recording struct {
+	// Delay is the time each frame is shown for, in 100ths of a second.
+	Delay int
+
+	animation gif.GIF
+}
+
+// Add adds an image as the next frame, with its colours snapped onto the nearest of a standard 256 colour palette.
+func (r *Recording) Add(img image.Image) {
+	frame := image.NewPaletted(img.Bounds(), palette.Plan9)
+	draw.Draw(frame, frame.Bounds(), img, img.Bounds().Min, draw.Src)
+	r.animation.Image = append(r.animation.Image, frame)
+	r.animation.Delay = append(r.animation.Delay, r.Delay)
+}
+
+// Frames gets the number of frames added so far.
+func (r *Recording) Frames() int {
+	return len(r.animation.Image)
+}
+
+// Write writes the frames as an animated GIF that loops forever.
+func (r *Recording) Write(w io.Writer) error {
+	if r.Frames() == 0 {
+		return fmt.Errorf("no frames were recorded")
+	}
+	return gif.EncodeAll(w, &r.animation)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/rule"
+	"uk.ac.bris.cs/gameoflife/theme"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestThemes checks every theme can be found by name and cycled through, and that cells are coloured by state between
+// the dead and alive colours, or in the rule's own colours in the classic theme.
+func TestThemes(t *testing.T) {
+	names := theme.Names()
+	seen := map[string]bool{}
+	next := theme.Classic
+	for range names {
+		next = theme.Next(next)
+		seen[next.Name] = true
+	}
+	if len(seen) != len(names) || next.Name != theme.Classic.Name {
+		t.Fatalf("Cycling through %v went through %v", names, seen)
+	}
+	if _, err := theme.Parse("rainbow"); err == nil {
+		t.Fatal("Expected an error for a theme that doesn't exist")
+	}
+
+	for _, name := range names {
+		th, err := theme.Parse(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		painter := theme.Painter{Theme: th, Rule: rule.Wireworld{}}
+		if name == theme.Classic.Name {
+			if painter.Colour(1, 0) != (rule.Wireworld{}).Colour(1) {
+				t.Errorf("The classic theme should show states in the rule's own colours")
+			}
+			continue
+		}
+		if painter.Colour(0, 0) != th.Dead || painter.Colour(255, 0) != th.Alive {
+			t.Errorf("The %v theme shows dead and alive cells as %v and %v, expected %v and %v", name, painter.Colour(0, 0), painter.Colour(255, 0), th.Dead, th.Alive)
+		}
+	}
+}
+
+// TestAges keeps the ages of the cells of the 16x16 glider from the CellFlipped and TurnComplete events, the way the
+// SDL window does, and checks them against ages worked out by stepping the board here. The ages are then exported as a
+// PNG and a GIF, which have to come back in the colours the cells are shown in.
+func TestAges(t *testing.T) {
+	p := gol.Params{
+		Turns:       40,
+		Threads:     3,
+		ImageWidth:  16,
+		ImageHeight: 16,
+	}
+	board := make([][]byte, p.ImageHeight)
+	changed := make([][]int, p.ImageHeight)
+	for y := range board {
+		board[y] = make([]byte, p.ImageWidth)
+		changed[y] = make([]int, p.ImageWidth)
+	}
+	for _, cell := range util.ReadAliveCells("images/16x16.pgm", p.ImageWidth, p.ImageHeight) {
+		board[cell.Y][cell.X] = 255
+	}
+	// The TurnComplete event of each turn carries the number of turns completed before it, so the last turn is
+	// p.Turns-1 and cells that flipped on it are 0 turns old
+	life := ltl{r: 1, sMin: 2, sMax: 3, bMin: 3, bMax: 3}
+	last := p.Turns - 1
+	for turn := 0; turn < p.Turns; turn++ {
+		next := life.next(board)
+		for y := range next {
+			for x := range next[y] {
+				if next[y][x] != board[y][x] {
+					changed[y][x] = turn
+				}
+			}
+		}
+		board = next
+	}
+
+	painter := theme.Painter{Theme: theme.Classic, Rule: rule.Life, Ages: true}
+	ages := theme.NewAges(p.ImageHeight, p.ImageWidth)
+	cells := make([][]byte, p.ImageHeight)
+	for y := range cells {
+		cells[y] = make([]byte, p.ImageWidth)
+	}
+	recording := &theme.Recording{Delay: 5}
+	events := make(chan gol.Event, 1000)
+	gol.Run(p, events, nil)
+	for event := range events {
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			cells[e.Cell.Y][e.Cell.X] = e.State
+			ages.Flip(e.Cell.X, e.Cell.Y)
+		case gol.TurnComplete:
+			ages.Turn(e.CompletedTurns)
+			recording.Add(painter.Image(cells, ages.Age))
+		}
+	}
+
+	for y := range board {
+		if !bytes.Equal(cells[y], board[y]) {
+			t.Fatalf("Row %v after %v turns is %v, expected %v", y, p.Turns, cells[y], board[y])
+		}
+		for x := range board[y] {
+			if age := ages.Age(x, y); age != last-changed[y][x] {
+				t.Fatalf("Cell (%v, %v) is %v turns old, expected %v", x, y, age, last-changed[y][x])
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, painter.Image(cells, ages.Age)); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := range board {
+		for x := range board[y] {
+			expected := painter.Colour(board[y][x], last-changed[y][x])
+			if got := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA); got != expected {
+				t.Fatalf("Cell (%v, %v) of the PNG is %v, expected %v", x, y, got, expected)
+			}
+		}
+	}
+	if young, old := painter.Colour(255, 1), painter.Colour(255, 1000); young == old {
+		t.Errorf("Cells alive for 1 and 1000 turns are both shown as %v", young)
+	}
+
+	out.Reset()
+	if err := recording.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	animation, err := gif.DecodeAll(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(animation.Image) != p.Turns {
+		t.Fatalf("Expected a frame for each of the %v turns, got %v", p.Turns, len(animation.Image))
+	}
+	if bounds := animation.Image[0].Bounds(); bounds.Dx() != p.ImageWidth || bounds.Dy() != p.ImageHeight {
+		t.Fatalf("Frames are %v, expected %vx%v", bounds, p.ImageWidth, p.ImageHeight)
+	}
+}